@@ -0,0 +1,403 @@
+// Package app wires the configured stores, caches and handlers into an
+// HTTP server and owns their lifecycle.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"recipes-api/cache"
+	"recipes-api/config"
+	"recipes-api/handlers"
+	"recipes-api/media"
+	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/recipeio"
+	"recipes-api/store"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	redisStore "github.com/gin-contrib/sessions/redis"
+	"github.com/go-redis/redis"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type App struct {
+	cfg         config.Config
+	mongoClient *mongo.Client
+	redisClient *redis.Client
+	// sessionStore is nil in jwt mode.
+	sessionStore sessions.Store
+	server       *http.Server
+
+	recipesHandler *handlers.RecipesHandler
+	authHandler    *handlers.AuthHandler
+}
+
+// New connects to the backing services named by cfg and builds the HTTP
+// server. ctx bounds the connection attempts only. On error every
+// connection opened so far is closed again.
+func New(ctx context.Context, cfg config.Config) (app *App, err error) {
+	app = &App{cfg: cfg}
+	defer func() {
+		if err != nil {
+			app.Close(context.Background())
+		}
+	}()
+
+	// Handlers outlive the startup context; they only stop when the
+	// server is shut down.
+	handlerCtx := context.Background()
+
+	var recipeStore store.RecipeStore
+	var revisionStore store.RevisionStore
+	var reviewStore store.ReviewStore
+	var collectionStore store.CollectionStore
+	var mealPlanStore store.MealPlanStore
+	var userStore store.UserStore
+	var responseCache cache.Cache
+	var imageStorage media.Storage
+	// Sessions are only kept in session mode, the only mode validation
+	// requires a session secret for.
+	sessionMode := handlers.AuthMode(cfg.Auth.Mode) == handlers.AuthModeSession
+
+	if cfg.Store == config.StoreMemory {
+		log.Println("Using in-memory store")
+		memoryRecipes := store.NewMemoryRecipeStore()
+		recipeStore = memoryRecipes
+		revisionStore = store.NewMemoryRevisionStore()
+		reviewStore = store.NewMemoryReviewStore(memoryRecipes)
+		collectionStore = store.NewMemoryCollectionStore()
+		mealPlanStore = store.NewMemoryMealPlanStore()
+		userStore = store.NewMemoryUserStore()
+		responseCache = cache.NewMemoryCache()
+		if sessionMode {
+			app.sessionStore = cookie.NewStore([]byte(cfg.Session.Secret))
+		}
+		imageStorage, err = media.NewFileStorage(cfg.Images.Dir)
+		if err != nil {
+			return app, err
+		}
+	} else {
+		if err := app.connectMongo(ctx); err != nil {
+			return app, err
+		}
+		database := app.mongoClient.Database(cfg.Mongo.Database)
+		mongoRecipes := store.NewMongoRecipeStore(database.Collection("recipes"))
+		if err := mongoRecipes.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating recipe indexes: %w", err)
+		}
+		recipeStore = mongoRecipes
+		mongoRevisions := store.NewMongoRevisionStore(database.Collection("recipe_revisions"))
+		if err := mongoRevisions.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating revision indexes: %w", err)
+		}
+		revisionStore = mongoRevisions
+		mongoReviews := store.NewMongoReviewStore(database.Collection("reviews"), database.Collection("recipes"))
+		if err := mongoReviews.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating review indexes: %w", err)
+		}
+		reviewStore = mongoReviews
+		mongoCollections := store.NewMongoCollectionStore(database.Collection("collections"))
+		if err := mongoCollections.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating collection indexes: %w", err)
+		}
+		collectionStore = mongoCollections
+		mongoMealPlans := store.NewMongoMealPlanStore(database.Collection("meal_plans"))
+		if err := mongoMealPlans.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating meal plan indexes: %w", err)
+		}
+		mealPlanStore = mongoMealPlans
+		mongoUsers := store.NewMongoUserStore(database.Collection("users"))
+		if err := mongoUsers.EnsureIndexes(ctx); err != nil {
+			return app, fmt.Errorf("creating user indexes: %w", err)
+		}
+		userStore = mongoUsers
+
+		if cfg.Images.Storage == config.ImagesGridFS {
+			imageStorage = media.NewGridFSStorage(database, "images")
+		} else if imageStorage, err = media.NewFileStorage(cfg.Images.Dir); err != nil {
+			return app, err
+		}
+
+		if err := app.connectRedis(ctx); err != nil {
+			return app, err
+		}
+		responseCache = cache.NewRedisCache(app.redisClient)
+		if sessionMode {
+			app.sessionStore, err = redisStore.NewStoreWithDB(10, "tcp", cfg.Redis.Addr, cfg.Redis.Password,
+				strconv.Itoa(cfg.Redis.DB), []byte(cfg.Session.Secret))
+			if err != nil {
+				return app, fmt.Errorf("creating session store: %w", err)
+			}
+		}
+	}
+	if app.sessionStore != nil {
+		app.sessionStore.Options(sessionOptions(cfg.Session))
+	}
+
+	hasher, err := password.New(cfg.Auth.PasswordHasher)
+	if err != nil {
+		return app, err
+	}
+	// Accounts created before roles existed were treated as authors.
+	granted, err := userStore.GrantMissingRoles(ctx, models.DefaultRoles)
+	if err != nil {
+		return app, fmt.Errorf("granting roles to accounts without any: %w", err)
+	}
+	if granted > 0 {
+		log.Printf("Granted %v to %d accounts without roles", models.DefaultRoles, granted)
+	}
+	if err := bootstrapAdmin(ctx, userStore, hasher, cfg.Auth); err != nil {
+		return app, fmt.Errorf("bootstrapping admin account: %w", err)
+	}
+
+	images := handlers.ImageSettings{Storage: imageStorage, MaxSize: int64(cfg.Images.MaxSize)}
+	app.recipesHandler = handlers.NewRecipesHandler(handlerCtx, recipeStore, revisionStore, reviewStore, collectionStore, mealPlanStore, images, responseCache, handlers.CacheTTLs{
+		Recipe: time.Duration(cfg.Cache.RecipeTTL),
+		List:   time.Duration(cfg.Cache.ListTTL),
+		Search: time.Duration(cfg.Cache.SearchTTL),
+		Stale:  time.Duration(cfg.Cache.StaleTTL),
+	})
+	app.authHandler = handlers.NewAuthHandler(handlerCtx, userStore, hasher, handlers.AuthMode(cfg.Auth.Mode), []byte(cfg.Auth.JWTSecret))
+
+	app.server = &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           app.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return app, nil
+}
+
+func (app *App) connectMongo(ctx context.Context) error {
+	timeout := time.Duration(app.cfg.ConnectTimeout)
+	clientOptions := options.Client().
+		ApplyURI(app.cfg.Mongo.URI).
+		SetConnectTimeout(timeout).
+		SetServerSelectionTimeout(timeout)
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return fmt.Errorf("configuring MongoDB client: %w", err)
+	}
+	app.mongoClient = client
+
+	err = retry(ctx, "MongoDB", app.cfg.ConnectAttempts, timeout, func(ctx context.Context) error {
+		return client.Ping(ctx, readpref.Primary())
+	})
+	if err != nil {
+		return err
+	}
+	log.Println("Connected to MongoDB")
+	return nil
+}
+
+func (app *App) connectRedis(ctx context.Context) error {
+	timeout := time.Duration(app.cfg.ConnectTimeout)
+	app.redisClient = redis.NewClient(&redis.Options{
+		Addr:        app.cfg.Redis.Addr,
+		Password:    app.cfg.Redis.Password,
+		DB:          app.cfg.Redis.DB,
+		DialTimeout: timeout,
+	})
+
+	err := retry(ctx, "Redis", app.cfg.ConnectAttempts, timeout, func(context.Context) error {
+		return app.redisClient.Ping().Err()
+	})
+	if err != nil {
+		return err
+	}
+	log.Println("Connected to Redis")
+	return nil
+}
+
+// retry calls attempt up to attempts times, giving each call its own
+// timeout and backing off exponentially between failures.
+func retry(ctx context.Context, name string, attempts int, timeout time.Duration, attempt func(context.Context) error) error {
+	backoff := 500 * time.Millisecond
+	var err error
+	for i := 1; i <= attempts; i++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		err = attempt(attemptCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Connecting to %s failed (attempt %d/%d): %v", name, i, attempts, err)
+		if i == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("connecting to %s: %w", name, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("connecting to %s: %w", name, err)
+}
+
+// Run serves HTTP until ctx is cancelled, then stops accepting connections
+// and waits up to the configured shutdown timeout for in-flight requests.
+// Expired recipes are purged from the trash and scheduled recipes are
+// published in the background meanwhile.
+func (app *App) Run(ctx context.Context) error {
+	if app.cfg.Trash.Retention > 0 {
+		go app.purgeTrash(ctx)
+	}
+	go app.publishScheduled(ctx)
+
+	errs := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", app.cfg.Listen)
+		if app.cfg.TLS.Enabled() {
+			errs <- app.server.ListenAndServeTLS(app.cfg.TLS.CertFile, app.cfg.TLS.KeyFile)
+		} else {
+			errs <- app.server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(app.cfg.ShutdownTimeout))
+	defer cancel()
+	if err := app.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down HTTP server: %w", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// purgeTrash removes expired recipes from the trash every purge interval
+// until ctx is cancelled.
+func (app *App) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(app.cfg.Trash.PurgeInterval))
+	defer ticker.Stop()
+	for {
+		count, err := app.recipesHandler.PurgeExpired(ctx, time.Duration(app.cfg.Trash.Retention))
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Purging the trash failed: %v", err)
+		} else if count > 0 {
+			log.Printf("Purged %d recipes from the trash", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishScheduled publishes the recipes whose scheduled time has come
+// every publish interval until ctx is cancelled.
+func (app *App) publishScheduled(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(app.cfg.Publish.Interval))
+	defer ticker.Stop()
+	for {
+		count, err := app.recipesHandler.PublishScheduled(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Publishing scheduled recipes failed: %v", err)
+		} else if count > 0 {
+			log.Printf("Published %d scheduled recipes", count)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close releases the session store and the Redis and MongoDB clients. It is
+// safe to call on a partially constructed App.
+func (app *App) Close(ctx context.Context) error {
+	var errs []error
+	if app.sessionStore != nil {
+		if err, rediStore := redisStore.GetRedisStore(app.sessionStore); err == nil {
+			if err := rediStore.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing session store: %w", err))
+			}
+		}
+	}
+	if app.redisClient != nil {
+		if err := app.redisClient.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing Redis client: %w", err))
+		}
+	}
+	if app.mongoClient != nil {
+		if err := app.mongoClient.Disconnect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("disconnecting MongoDB: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Import loads recipes from r into the store, as POST /recipes/import does.
+func (app *App) Import(ctx context.Context, r io.Reader, opts recipeio.ImportOptions) (recipeio.Report, error) {
+	return app.recipesHandler.Import(ctx, r, opts)
+}
+
+// Export writes every recipe to w, of every status, as GET /recipes/export
+// does for editors.
+func (app *App) Export(ctx context.Context, w io.Writer, format string) error {
+	return app.recipesHandler.Export(ctx, w, format, nil)
+}
+
+// RemoveDuplicateUsers deletes the duplicate user documents that releases
+// seeding users on every boot left behind, which keep the unique username
+// index from being built. It only connects to MongoDB, so it can run
+// before New succeeds.
+func RemoveDuplicateUsers(ctx context.Context, cfg config.Config) (removed int64, err error) {
+	if cfg.Store == config.StoreMemory {
+		return 0, errors.New("dedupe-users needs the MongoDB store")
+	}
+	app := &App{cfg: cfg}
+	defer func() {
+		err = errors.Join(err, app.Close(context.Background()))
+	}()
+	if err := app.connectMongo(ctx); err != nil {
+		return 0, err
+	}
+	users := store.NewMongoUserStore(app.mongoClient.Database(cfg.Mongo.Database).Collection("users"))
+	return users.RemoveDuplicateUsers(ctx)
+}
+
+// RehashLegacyPasswords hashes the passwords still stored in the legacy
+// SHA-256 scheme with the configured hasher. Those are otherwise only
+// upgraded when their user signs in. Like RemoveDuplicateUsers it only
+// connects to MongoDB.
+func RehashLegacyPasswords(ctx context.Context, cfg config.Config) (rehashed int, err error) {
+	if cfg.Store == config.StoreMemory {
+		return 0, errors.New("rehash-passwords needs the MongoDB store")
+	}
+	hasher, err := password.New(cfg.Auth.PasswordHasher)
+	if err != nil {
+		return 0, err
+	}
+	app := &App{cfg: cfg}
+	defer func() {
+		err = errors.Join(err, app.Close(context.Background()))
+	}()
+	if err := app.connectMongo(ctx); err != nil {
+		return 0, err
+	}
+	users := store.NewMongoUserStore(app.mongoClient.Database(cfg.Mongo.Database).Collection("users"))
+	return rehashLegacyPasswords(ctx, users, hasher)
+}
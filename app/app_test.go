@@ -0,0 +1,223 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"recipes-api/config"
+	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/store"
+)
+
+func TestRetry(t *testing.T) {
+	failure := errors.New("refused")
+	tests := []struct {
+		name      string
+		attempts  int
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"first attempt", 3, 0, 1, false},
+		{"after a failure", 3, 1, 2, false},
+		{"every attempt fails", 2, 5, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retry(context.Background(), "test", tt.attempts, time.Second, func(ctx context.Context) error {
+				calls++
+				if _, ok := ctx.Deadline(); !ok {
+					t.Error("attempt without a deadline")
+				}
+				if calls <= tt.failures {
+					return failure
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr || tt.wantErr && !errors.Is(err, failure) {
+				t.Errorf("retry = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// retry gives up as soon as its context ends instead of backing off.
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retry(ctx, "test", 5, time.Second, func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("refused")
+	})
+	if calls != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("retry = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+// memoryConfig returns settings for an App on memory stores listening on
+// a free local port.
+func memoryConfig(t *testing.T) config.Config {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cfg := config.Default()
+	cfg.Store = config.StoreMemory
+	cfg.Listen = addr
+	cfg.Session.Secret = strings.Repeat("s", 32)
+	cfg.Images.Dir = t.TempDir()
+	cfg.ShutdownTimeout = config.Duration(5 * time.Second)
+	return cfg
+}
+
+func TestRunShutsDownWhenCancelled(t *testing.T) {
+	cfg := memoryConfig(t)
+	app, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer app.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+
+	url := "http://" + cfg.Listen + "/recipes"
+	var res *http.Response
+	for attempt := 0; attempt < 50; attempt++ {
+		if res, err = http.Get(url); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("GET /recipes: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("GET /recipes: status %d", res.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v, want nil after cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+}
+
+func TestRunReportsListenErrors(t *testing.T) {
+	cfg := memoryConfig(t)
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	app, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer app.Close(context.Background())
+	if err := app.Run(context.Background()); err == nil {
+		t.Error("Run on a taken address = nil, want an error")
+	}
+}
+
+// In jwt mode no session store is built, so the session secret is not
+// needed, and the auth routes work without sessions.
+func TestNewInJWTMode(t *testing.T) {
+	cfg := memoryConfig(t)
+	cfg.Auth.Mode = "jwt"
+	cfg.Auth.JWTSecret = strings.Repeat("j", 32)
+	cfg.Session.Secret = ""
+	app, err := New(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer app.Close(context.Background())
+	if app.sessionStore != nil {
+		t.Error("session store built in jwt mode")
+	}
+
+	router := app.routes()
+	for _, path := range []string{"/signin", "/refresh", "/signout"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"username":"nobody","password":"secret"}`)))
+		if rec.Code >= http.StatusInternalServerError {
+			t.Errorf("POST %s: status %d: %s", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestRehashLegacyPasswords(t *testing.T) {
+	ctx := context.Background()
+	hasher := password.NewBcryptHasher(4)
+	modern, err := hasher.Hash("modern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := func(plaintext string) string {
+		return plaintext + string(sha256.New().Sum(nil))
+	}
+	users := store.NewMemoryUserStore()
+	for _, user := range []models.User{
+		{Username: "alice", Password: legacy("alice password")},
+		{Username: "bob", Password: legacy("bob password")},
+		{Username: "erin", Password: modern},
+	} {
+		if err := users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rehashed, err := rehashLegacyPasswords(ctx, users, hasher)
+	if err != nil || rehashed != 2 {
+		t.Fatalf("rehashLegacyPasswords = %d, %v, want 2", rehashed, err)
+	}
+	tests := []struct {
+		username, password string
+	}{
+		{"alice", "alice password"},
+		{"bob", "bob password"},
+		{"erin", "modern"},
+	}
+	for _, tt := range tests {
+		user, err := users.GetUser(ctx, tt.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, isLegacy := password.LegacyPassword(user.Password); isLegacy {
+			t.Errorf("%s still has a legacy hash", tt.username)
+		}
+		if ok, err := hasher.Verify(user.Password, tt.password); !ok || err != nil {
+			t.Errorf("%s: password no longer verifies: %v", tt.username, err)
+		}
+	}
+	if rehashed, _ := rehashLegacyPasswords(ctx, users, hasher); rehashed != 0 {
+		t.Errorf("second run rehashed %d passwords, want 0", rehashed)
+	}
+}
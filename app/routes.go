@@ -0,0 +1,88 @@
+package app
+
+import (
+	"recipes-api/handlers"
+	"recipes-api/models"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+func (app *App) routes() *gin.Engine {
+	recipesHandler := app.recipesHandler
+	authHandler := app.authHandler
+
+	router := gin.New()
+	router.Use(gin.Logger(), handlers.Recovery())
+	router.NoRoute(handlers.NotFound)
+	router.Use(cors.New(corsConfig(app.cfg.CORS.AllowOrigins)))
+	if app.sessionStore != nil {
+		router.Use(sessions.Sessions(app.cfg.Session.CookieName, app.sessionStore))
+	}
+
+	// Unpublished recipes are shown to their author and to editors, so the
+	// public recipe routes identify callers that are signed in.
+	public := router.Group("/")
+	public.Use(authHandler.OptionalAuthMiddleware())
+	public.GET("/recipes", recipesHandler.ListRecipesHandler)
+	public.GET("/recipes/:id", recipesHandler.GetRecipeHandler)
+	public.GET("/recipes/:id/reviews", recipesHandler.ListReviewsHandler)
+	public.GET("/images/:name", recipesHandler.ServeImageHandler)
+	router.GET("/collections/:id", recipesHandler.GetSharedCollectionHandler)
+
+	router.POST("/signup", authHandler.SignUpHandler)
+	router.POST("/signin", authHandler.SignInHandler)
+	router.POST("/refresh", authHandler.RefreshHandler)
+	router.POST("/signout", authHandler.SignOutHandler)
+
+	authorized := router.Group("/")
+	authorized.Use(authHandler.AuthMiddleware())
+
+	authorized.POST("/recipes", authHandler.RequireRole(models.RoleAuthor), recipesHandler.CreateRecipeHandler)
+	authorized.POST("/recipes/import", authHandler.RequireRole(models.RoleEditor), recipesHandler.ImportRecipesHandler)
+	authorized.GET("/recipes/export", recipesHandler.ExportRecipesHandler)
+	authorized.PUT("/recipes/:id", recipesHandler.UpdateRecipesHandler)
+	authorized.PATCH("/recipes/:id", recipesHandler.PatchRecipeHandler)
+	authorized.GET("/recipes/:id/revisions", recipesHandler.ListRevisionsHandler)
+	authorized.GET("/recipes/:id/revisions/:rev", recipesHandler.GetRevisionHandler)
+	authorized.POST("/recipes/:id/revert/:rev", recipesHandler.RevertRecipeHandler)
+	authorized.POST("/recipes/:id/restore", recipesHandler.RestoreRecipeHandler)
+	authorized.POST("/recipes/:id/status", recipesHandler.ChangeStatusHandler)
+	authorized.POST("/recipes/:id/reviews", recipesHandler.PostReviewHandler)
+	authorized.POST("/recipes/:id/images", recipesHandler.UploadImageHandler)
+	authorized.DELETE("/recipes/:id/images/:image", recipesHandler.DeleteImageHandler)
+	authorized.GET("/trash", recipesHandler.ListTrashHandler)
+	authorized.DELETE("/recipes/:id", recipesHandler.DeleteRecipeHandler)
+	authorized.GET("/recipes/search", recipesHandler.SearchRecipesHandler)
+	authorized.PUT("/me/password", authHandler.ChangePasswordHandler)
+	authorized.GET("/me/collections", recipesHandler.ListCollectionsHandler)
+	authorized.POST("/me/collections", recipesHandler.CreateCollectionHandler)
+	authorized.GET("/me/collections/:id", recipesHandler.GetCollectionHandler)
+	authorized.PUT("/me/collections/:id", recipesHandler.UpdateCollectionHandler)
+	authorized.DELETE("/me/collections/:id", recipesHandler.DeleteCollectionHandler)
+	authorized.POST("/me/collections/:id/recipes", recipesHandler.AddCollectionRecipeHandler)
+	authorized.PUT("/me/collections/:id/recipes", recipesHandler.ReorderCollectionHandler)
+	authorized.DELETE("/me/collections/:id/recipes/:recipeId", recipesHandler.RemoveCollectionRecipeHandler)
+	authorized.GET("/me/mealplan", recipesHandler.ListMealPlanHandler)
+	authorized.POST("/me/mealplan", recipesHandler.CreateMealPlanEntryHandler)
+	authorized.GET("/me/mealplan/:id", recipesHandler.GetMealPlanEntryHandler)
+	authorized.PUT("/me/mealplan/:id", recipesHandler.UpdateMealPlanEntryHandler)
+	authorized.DELETE("/me/mealplan/:id", recipesHandler.DeleteMealPlanEntryHandler)
+	authorized.GET("/me/shopping-list", recipesHandler.ShoppingListHandler)
+
+	admin := authorized.Group("/admin")
+	admin.Use(authHandler.RequireRole(models.RoleAdmin))
+	admin.GET("/users", authHandler.ListUsersHandler)
+	admin.POST("/users/:username/disable", authHandler.DisableUserHandler)
+	admin.POST("/users/:username/enable", authHandler.EnableUserHandler)
+	admin.PUT("/users/:username/roles", authHandler.SetRolesHandler)
+	admin.DELETE("/users/:username", authHandler.DeleteUserHandler)
+	admin.DELETE("/trash/:id", recipesHandler.PurgeRecipeHandler)
+
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return router
+}
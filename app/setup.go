@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"recipes-api/config"
+	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/store"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func sessionOptions(session config.SessionConfig) sessions.Options {
+	sameSite := http.SameSiteLaxMode
+	switch session.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	}
+	return sessions.Options{
+		Path:     session.Path,
+		Domain:   session.Domain,
+		MaxAge:   session.MaxAge,
+		Secure:   session.Secure,
+		HttpOnly: session.HTTPOnly,
+		SameSite: sameSite,
+	}
+}
+
+func corsConfig(origins []string) cors.Config {
+	corsCfg := cors.DefaultConfig()
+	if len(origins) == 0 || slices.Contains(origins, "*") {
+		corsCfg.AllowAllOrigins = true
+	} else {
+		corsCfg.AllowOrigins = origins
+		corsCfg.AllowCredentials = true
+	}
+	corsCfg.AddAllowHeaders("Authorization", "If-Match", "If-None-Match")
+	corsCfg.AddExposeHeaders("ETag", "Link", "X-Next-Cursor", "X-Total-Count")
+	return corsCfg
+}
+
+// bootstrapAdmin makes sure the account named by ADMIN_USERNAME exists and
+// holds the admin role, creating it with ADMIN_PASSWORD if needed.
+func bootstrapAdmin(ctx context.Context, users store.UserStore, hasher password.Hasher, auth config.AuthConfig) error {
+	username := auth.AdminUsername
+	if username == "" {
+		return nil
+	}
+
+	existing, err := users.GetUser(ctx, username)
+	if err == nil {
+		if existing.HasRole(models.RoleAdmin) {
+			return nil
+		}
+		return users.SetRoles(ctx, username, append(existing.Roles, models.RoleAdmin))
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	plaintext := auth.AdminPassword
+	if plaintext == "" {
+		return errors.New("ADMIN_PASSWORD must be set to create the admin account")
+	}
+	hash, err := hasher.Hash(plaintext)
+	if err != nil {
+		return err
+	}
+	log.Printf("Creating admin account %s", username)
+	err = users.CreateUser(ctx, models.User{
+		ID:        primitive.NewObjectID(),
+		Username:  username,
+		Password:  hash,
+		CreatedAt: time.Now(),
+		Roles:     []string{models.RoleAdmin},
+	})
+	if errors.Is(err, store.ErrDuplicate) {
+		return fmt.Errorf("%s belongs to a deleted account, choose another ADMIN_USERNAME", username)
+	}
+	return err
+}
+
+// rehashLegacyPasswords replaces every password stored in the legacy
+// scheme, which holds it in the clear, by a hash from hasher. Passwords
+// changed meanwhile are left alone.
+func rehashLegacyPasswords(ctx context.Context, users store.UserStore, hasher password.Hasher) (int, error) {
+	accounts, err := users.ListUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rehashed := 0
+	for _, user := range accounts {
+		plaintext, ok := password.LegacyPassword(user.Password)
+		if !ok {
+			continue
+		}
+		hash, err := hasher.Hash(plaintext)
+		if err != nil {
+			return rehashed, err
+		}
+		err = users.ReplacePassword(ctx, user.Username, user.Password, hash)
+		if errors.Is(err, store.ErrConflict) || errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return rehashed, fmt.Errorf("rehashing the password of %s: %w", user.Username, err)
+		}
+		rehashed++
+	}
+	return rehashed, nil
+}
@@ -0,0 +1,35 @@
+// Package cache stores rendered API responses under string keys. Every
+// entry can carry tags; invalidating a tag removes every entry stored with
+// it, which lets writers drop exactly the responses that depend on the
+// data they changed.
+//
+// A value computed while one of its tags is invalidated may already be out
+// of date. Writers therefore read the Generation before computing a value
+// and pass it to Set, which drops the value if any of its tags has been
+// invalidated since.
+package cache
+
+import "time"
+
+// generationTTL is how long a cache remembers when a tag was last
+// invalidated. A value whose computation takes longer than this may be
+// stored even though one of its tags was invalidated meanwhile.
+const generationTTL = time.Hour
+
+type Cache interface {
+	// Get returns the value stored under key. ok is false on a miss.
+	Get(key string) (value []byte, ok bool, err error)
+	// Generation returns the current invalidation generation, which every
+	// call to Invalidate advances.
+	Generation() (int64, error)
+	// Set stores value under key for ttl and records it under every tag,
+	// unless one of the tags was invalidated after generation was read.
+	// stored reports whether the value was written.
+	Set(key string, value []byte, ttl time.Duration, generation int64, tags ...string) (stored bool, err error)
+	// Invalidate removes every entry recorded under any of the tags.
+	Invalidate(tags ...string) error
+	// TryLock acquires the lock named key for at most ttl without waiting.
+	// ok is false when the lock is held elsewhere. unlock releases the lock
+	// if it is still held by the caller.
+	TryLock(key string, ttl time.Duration) (unlock func(), ok bool, err error)
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultLockTTL  = 10 * time.Second
+	defaultLockWait = 2 * time.Second
+	pollInterval    = 50 * time.Millisecond
+)
+
+// LoadFunc computes the value of a missing or stale entry together with the
+// tags to store it under.
+type LoadFunc func() (value []byte, tags []string, err error)
+
+// Fetcher reads through a Cache while protecting the backing store from
+// cache stampedes:
+//
+//   - concurrent misses for the same key within the process share a single
+//     load;
+//   - across processes, only the holder of a lock on the key rebuilds it
+//     while the others wait briefly for the result;
+//   - with a non-zero stale TTL, an expired entry is still served for that
+//     long while it is refreshed in the background.
+//
+// Entries written by a Fetcher carry the time they stop being fresh, so
+// they must only be read back through a Fetcher.
+type Fetcher struct {
+	cache    Cache
+	group    singleflight.Group
+	staleTTL time.Duration
+	lockTTL  time.Duration
+	lockWait time.Duration
+}
+
+// NewFetcher returns a Fetcher over c. A staleTTL of zero disables
+// stale-while-revalidate.
+func NewFetcher(c Cache, staleTTL time.Duration) *Fetcher {
+	return &Fetcher{
+		cache:    c,
+		staleTTL: staleTTL,
+		lockTTL:  defaultLockTTL,
+		lockWait: defaultLockWait,
+	}
+}
+
+// Fetch returns the value of key, calling load when it is not cached. ttl
+// is how long the loaded value stays fresh.
+//
+// Misses share a load only with misses of the same cache generation. A
+// load that started before an invalidation may return data the
+// invalidating write has since changed, so a miss after the write starts a
+// load of its own instead of joining it.
+func (f *Fetcher) Fetch(key string, ttl time.Duration, load LoadFunc) ([]byte, error) {
+	if value, fresh, ok := f.read(key); ok {
+		if !fresh {
+			f.refreshInBackground(key, ttl, load)
+		}
+		return value, nil
+	}
+
+	generation, err := f.cache.Generation()
+	if err != nil {
+		// Without a generation the value can be neither shared nor cached
+		// safely.
+		log.Printf("Reading the cache generation for %s failed: %v", key, err)
+		value, _, err := load()
+		return value, err
+	}
+	value, err, _ := f.group.Do(loadKey(key, generation), func() (interface{}, error) {
+		return f.rebuild(key, ttl, generation, load)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]byte), nil
+}
+
+// loadKey names the load of key in one cache generation, both for
+// sharing it within the process and for locking it across processes.
+func loadKey(key string, generation int64) string {
+	return fmt.Sprintf("%s@%d", key, generation)
+}
+
+// read returns the cached value and whether it is still fresh. Cache
+// errors are logged and treated as a miss.
+func (f *Fetcher) read(key string) (value []byte, fresh, ok bool) {
+	data, ok, err := f.cache.Get(key)
+	if err != nil {
+		log.Printf("Reading %s from cache failed: %v", key, err)
+		return nil, false, false
+	}
+	if !ok || len(data) < 8 {
+		return nil, false, false
+	}
+	freshUntil := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+	return data[8:], time.Now().Before(freshUntil), true
+}
+
+// loadAndStore calls load and caches its value, unless one of the tags of
+// the value was invalidated after generation: the value may then predate
+// the change that caused the invalidation.
+func (f *Fetcher) loadAndStore(key string, ttl time.Duration, generation int64, load LoadFunc) ([]byte, error) {
+	value, tags, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	data := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(time.Now().Add(ttl).UnixNano()))
+	copy(data[8:], value)
+	if _, err := f.cache.Set(key, data, ttl+f.staleTTL, generation, tags...); err != nil {
+		log.Printf("Writing %s to cache failed: %v", key, err)
+	}
+	return value, nil
+}
+
+// rebuild loads the value if this process wins the lock on loading key in
+// generation. Otherwise it waits for the winner to publish the value, and
+// loads it itself only if that takes too long.
+func (f *Fetcher) rebuild(key string, ttl time.Duration, generation int64, load LoadFunc) ([]byte, error) {
+	unlock, locked, err := f.cache.TryLock(loadKey(key, generation), f.lockTTL)
+	if err != nil {
+		log.Printf("Locking %s failed: %v", key, err)
+	}
+	if locked {
+		defer unlock()
+		// The previous holder may have published the value meanwhile.
+		if value, fresh, ok := f.read(key); ok && fresh {
+			return value, nil
+		}
+	} else if err == nil {
+		if value, ok := f.waitFor(key); ok {
+			return value, nil
+		}
+	}
+
+	return f.loadAndStore(key, ttl, generation, load)
+}
+
+func (f *Fetcher) waitFor(key string) ([]byte, bool) {
+	deadline := time.Now().Add(f.lockWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(pollInterval)
+		if value, fresh, ok := f.read(key); ok && fresh {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// refreshInBackground reloads a stale entry unless another process is
+// already doing so.
+func (f *Fetcher) refreshInBackground(key string, ttl time.Duration, load LoadFunc) {
+	go f.group.Do("refresh:"+key, func() (interface{}, error) {
+		unlock, locked, err := f.cache.TryLock(key, f.lockTTL)
+		if err != nil || !locked {
+			return nil, err
+		}
+		defer unlock()
+		generation, err := f.cache.Generation()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.loadAndStore(key, ttl, generation, load); err != nil {
+			log.Printf("Refreshing %s failed: %v", key, err)
+			return nil, err
+		}
+		return nil, nil
+	})
+}
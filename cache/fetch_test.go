@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcherLoadsOnce(t *testing.T) {
+	f := NewFetcher(NewMemoryCache(), 0)
+	var loads int32
+	load := func() ([]byte, []string, error) {
+		atomic.AddInt32(&loads, 1)
+		return []byte("value"), []string{"recipe:1"}, nil
+	}
+	for i := 0; i < 3; i++ {
+		value, err := f.Fetch("key", time.Minute, load)
+		if err != nil || string(value) != "value" {
+			t.Fatalf("Fetch = %q, %v", value, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1", loads)
+	}
+}
+
+func TestFetcherLoadError(t *testing.T) {
+	f := NewFetcher(NewMemoryCache(), 0)
+	failure := errors.New("store down")
+	_, err := f.Fetch("key", time.Minute, func() ([]byte, []string, error) {
+		return nil, nil, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Fetch error = %v, want %v", err, failure)
+	}
+	if _, ok, _ := f.cache.Get("key"); ok {
+		t.Errorf("a failed load was cached")
+	}
+}
+
+// A value loaded while one of its tags is invalidated is returned to the
+// caller but not cached, as it may predate the write that invalidated it.
+func TestFetcherSkipsValueInvalidatedDuringLoad(t *testing.T) {
+	c := NewMemoryCache()
+	f := NewFetcher(c, 0)
+	tests := []struct {
+		name       string
+		invalidate string
+		cached     bool
+	}{
+		{"own tag", "recipe:1", false},
+		{"unrelated tag", "recipe:2", true},
+	}
+	for _, tt := range tests {
+		key := "key:" + tt.invalidate
+		value, err := f.Fetch(key, time.Minute, func() ([]byte, []string, error) {
+			c.Invalidate(tt.invalidate)
+			return []byte("stale"), []string{"recipe:1"}, nil
+		})
+		if err != nil || string(value) != "stale" {
+			t.Fatalf("%s: Fetch = %q, %v", tt.name, value, err)
+		}
+		if _, ok, _ := c.Get(key); ok != tt.cached {
+			t.Errorf("%s: cached = %v, want %v", tt.name, ok, tt.cached)
+		}
+	}
+}
+
+// A read after a write must not join a load that started before it: that
+// load may return the data the write replaced.
+func TestFetcherDoesNotShareLoadAcrossInvalidation(t *testing.T) {
+	c := NewMemoryCache()
+	f := NewFetcher(c, 0)
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan []byte)
+	go func() {
+		value, _ := f.Fetch("key", time.Minute, func() ([]byte, []string, error) {
+			close(started)
+			<-release
+			return []byte("old"), []string{"recipe:1"}, nil
+		})
+		done <- value
+	}()
+	<-started
+
+	// The write.
+	c.Invalidate("recipe:1")
+
+	next := make(chan []byte)
+	go func() {
+		value, _ := f.Fetch("key", time.Minute, func() ([]byte, []string, error) {
+			return []byte("new"), []string{"recipe:1"}, nil
+		})
+		next <- value
+	}()
+	select {
+	case value := <-next:
+		if string(value) != "new" {
+			t.Errorf("Fetch after the write = %q, want new", value)
+		}
+	case <-time.After(f.lockWait):
+		close(release)
+		t.Fatal("Fetch after the write waited for the earlier load")
+	}
+
+	close(release)
+	if value := <-done; string(value) != "old" {
+		t.Errorf("earlier Fetch = %q, want old", value)
+	}
+	if value, _ := f.Fetch("key", time.Minute, nil); string(value) != "new" {
+		t.Errorf("cached value = %q, want new", value)
+	}
+}
+
+func TestFetcherSharesConcurrentMisses(t *testing.T) {
+	f := NewFetcher(NewMemoryCache(), 0)
+	var loads int32
+	release := make(chan struct{})
+	load := func() ([]byte, []string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("value"), nil, nil
+	}
+
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value, _ := f.Fetch("key", time.Minute, load)
+			values[i] = string(value)
+		}(i)
+	}
+	// Let every miss reach the shared load before it completes.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("load called %d times, want 1", loads)
+	}
+	for i, value := range values {
+		if value != "value" {
+			t.Errorf("Fetch %d = %q, want value", i, value)
+		}
+	}
+}
+
+// Two Fetchers over one cache stand for two replicas: the one that loses
+// the lock waits for the value of the other rather than loading it too,
+// unless the holder takes longer than lockWait.
+func TestFetcherWaitsForLockHolder(t *testing.T) {
+	tests := []struct {
+		name     string
+		hold     time.Duration
+		lockWait time.Duration
+		want     string
+	}{
+		{"holder publishes", 100 * time.Millisecond, 2 * time.Second, "winner"},
+		{"holder too slow", time.Second, 100 * time.Millisecond, "loser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryCache()
+			winner, loser := NewFetcher(c, 0), NewFetcher(c, 0)
+			loser.lockWait = tt.lockWait
+
+			started := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				winner.Fetch("key", time.Minute, func() ([]byte, []string, error) {
+					close(started)
+					time.Sleep(tt.hold)
+					return []byte("winner"), nil, nil
+				})
+			}()
+			<-started
+
+			value, err := loser.Fetch("key", time.Minute, func() ([]byte, []string, error) {
+				return []byte("loser"), nil, nil
+			})
+			if err != nil || string(value) != tt.want {
+				t.Errorf("Fetch of the replica without the lock = %q, %v, want %s", value, err, tt.want)
+			}
+			<-done
+		})
+	}
+}
+
+// An expired entry is returned at once while a single background load
+// refreshes it.
+func TestFetcherServesStaleWhileRefreshing(t *testing.T) {
+	f := NewFetcher(NewMemoryCache(), time.Minute)
+	if _, err := f.Fetch("key", time.Millisecond, func() ([]byte, []string, error) {
+		return []byte("old"), nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	var refreshes int32
+	release := make(chan struct{})
+	refresh := func() ([]byte, []string, error) {
+		atomic.AddInt32(&refreshes, 1)
+		<-release
+		return []byte("new"), nil, nil
+	}
+	for i := 0; i < 3; i++ {
+		returned := make(chan string)
+		go func() {
+			value, _ := f.Fetch("key", time.Minute, refresh)
+			returned <- string(value)
+		}()
+		select {
+		case value := <-returned:
+			if value != "old" {
+				t.Fatalf("stale Fetch = %q, want old", value)
+			}
+		case <-time.After(time.Second):
+			close(release)
+			t.Fatal("stale Fetch waited for the refresh")
+		}
+	}
+	// The stale reads share one refresh while it is running.
+	for i := 0; i < 100 && atomic.LoadInt32(&refreshes) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&refreshes); n != 1 {
+		t.Errorf("refreshed %d times, want 1", n)
+	}
+	close(release)
+
+	// The refresh stores the value after load returns.
+	var value []byte
+	for i := 0; i < 100; i++ {
+		if value, _ = f.Fetch("key", time.Minute, refresh); string(value) == "new" {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if string(value) != "new" {
+		t.Errorf("Fetch after refresh = %q, want new", value)
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Set drops expired entries, locks and
+// invalidation records.
+const sweepInterval = time.Minute
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+	tags    []string
+}
+
+type invalidation struct {
+	generation int64
+	at         time.Time
+}
+
+// MemoryCache is a process local Cache used when Redis is not configured.
+// Expired entries are dropped when read and swept periodically, and keys
+// are removed from their tag sets together with the entry.
+type MemoryCache struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	tags        map[string]map[string]struct{}
+	locks       map[string]*memoryLock
+	generation  int64
+	invalidated map[string]invalidation
+	lastSweep   time.Time
+}
+
+type memoryLock struct {
+	expires time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		entries:     make(map[string]memoryEntry),
+		tags:        make(map[string]map[string]struct{}),
+		locks:       make(map[string]*memoryLock),
+		invalidated: make(map[string]invalidation),
+		lastSweep:   time.Now(),
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(entry.expires) {
+		c.remove(key)
+		return nil, false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (c *MemoryCache) Generation() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation, nil
+}
+
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration, generation int64, tags ...string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		c.sweep(now)
+	}
+	for _, tag := range tags {
+		if c.invalidated[tag].generation > generation {
+			return false, nil
+		}
+	}
+
+	c.remove(key)
+	c.entries[key] = memoryEntry{
+		value:   append([]byte(nil), value...),
+		expires: now.Add(ttl),
+		tags:    append([]string(nil), tags...),
+	}
+	for _, tag := range tags {
+		keys, ok := c.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	return true, nil
+}
+
+func (c *MemoryCache) Invalidate(tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	now := time.Now()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.remove(key)
+		}
+		c.invalidated[tag] = invalidation{generation: c.generation, at: now}
+	}
+	return nil
+}
+
+func (c *MemoryCache) TryLock(key string, ttl time.Duration) (func(), bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if held, ok := c.locks[key]; ok && time.Now().Before(held.expires) {
+		return func() {}, false, nil
+	}
+	lock := &memoryLock{expires: time.Now().Add(ttl)}
+	c.locks[key] = lock
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.locks[key] == lock {
+			delete(c.locks, key)
+		}
+	}, true, nil
+}
+
+// remove deletes the entry under key and its key from every tag set,
+// dropping sets that become empty. The caller holds c.mu.
+func (c *MemoryCache) remove(key string) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	delete(c.entries, key)
+	for _, tag := range entry.tags {
+		keys := c.tags[tag]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// sweep drops expired entries and locks and forgets invalidations older
+// than generationTTL. The caller holds c.mu.
+func (c *MemoryCache) sweep(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			c.remove(key)
+		}
+	}
+	for key, lock := range c.locks {
+		if now.After(lock.expires) {
+			delete(c.locks, key)
+		}
+	}
+	for tag, record := range c.invalidated {
+		if now.Sub(record.at) > generationTTL {
+			delete(c.invalidated, tag)
+		}
+	}
+	c.lastSweep = now
+}
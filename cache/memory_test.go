@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheInvalidate(t *testing.T) {
+	c := NewMemoryCache()
+	generation, _ := c.Generation()
+	c.Set("a", []byte("1"), time.Minute, generation, "recipe:1", "lists")
+	c.Set("b", []byte("2"), time.Minute, generation, "recipe:2", "lists")
+	c.Set("c", []byte("3"), time.Minute, generation, "recipe:1")
+
+	if err := c.Invalidate("recipe:1"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key string
+		ok  bool
+	}{
+		{"a", false},
+		{"b", true},
+		{"c", false},
+	}
+	for _, tt := range tests {
+		if _, ok, _ := c.Get(tt.key); ok != tt.ok {
+			t.Errorf("Get(%s) ok = %v, want %v", tt.key, ok, tt.ok)
+		}
+	}
+	// a is gone, so the lists tag only holds b.
+	if keys := c.tags["lists"]; len(keys) != 1 {
+		t.Errorf("lists tag holds %v, want only b", keys)
+	}
+}
+
+func TestMemoryCacheSetAfterInvalidation(t *testing.T) {
+	c := NewMemoryCache()
+	before, _ := c.Generation()
+	c.Invalidate("recipe:1")
+	after, _ := c.Generation()
+
+	tests := []struct {
+		name       string
+		generation int64
+		tags       []string
+		stored     bool
+	}{
+		{"invalidated tag, old generation", before, []string{"lists", "recipe:1"}, false},
+		{"other tag, old generation", before, []string{"recipe:2"}, true},
+		{"invalidated tag, current generation", after, []string{"recipe:1"}, true},
+	}
+	for _, tt := range tests {
+		stored, err := c.Set("key", []byte("v"), time.Minute, tt.generation, tt.tags...)
+		if err != nil || stored != tt.stored {
+			t.Errorf("%s: Set = %v, %v, want %v", tt.name, stored, err, tt.stored)
+		}
+	}
+}
+
+func TestMemoryCacheSweep(t *testing.T) {
+	c := NewMemoryCache()
+	c.Set("old", []byte("1"), time.Millisecond, 0, "lists")
+	c.Set("new", []byte("2"), time.Minute, 0, "lists", "recipe:1")
+	c.Set("gone", []byte("3"), time.Millisecond, 0, "recipe:2")
+	time.Sleep(5 * time.Millisecond)
+
+	c.mu.Lock()
+	c.sweep(time.Now())
+	c.mu.Unlock()
+
+	if len(c.entries) != 1 {
+		t.Errorf("%d entries left after sweep, want 1", len(c.entries))
+	}
+	if keys := c.tags["lists"]; len(keys) != 1 {
+		t.Errorf("lists tag holds %v, want only new", keys)
+	}
+	if _, ok := c.tags["recipe:2"]; ok {
+		t.Errorf("empty tag set recipe:2 was kept")
+	}
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/rs/xid"
+)
+
+const (
+	keyPrefix         = "cache:"
+	tagPrefix         = "cache-tag:"
+	lockPrefix        = "cache-lock:"
+	invalidatedPrefix = "cache-invalidated:"
+	generationKey     = "cache-generation"
+)
+
+// setScript stores the value and adds its key to every tag set, unless a
+// tag was invalidated after the generation in ARGV[3]. KEYS holds the
+// entry, then the tag sets, then the invalidation records of the same
+// tags. A tag set lives at least as long as the longest lived entry
+// recorded in it.
+var setScript = redis.NewScript(`
+local n = (#KEYS - 1) / 2
+local generation = tonumber(ARGV[3])
+for i = 1, n do
+	local invalidated = redis.call('GET', KEYS[n + 1 + i])
+	if invalidated and tonumber(invalidated) > generation then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+local ttl = tonumber(ARGV[2])
+for i = 2, n + 1 do
+	redis.call('SADD', KEYS[i], KEYS[1])
+	if redis.call('PTTL', KEYS[i]) < ttl then
+		redis.call('PEXPIRE', KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// invalidateScript advances the generation counter in KEYS[1], deletes
+// every member of the tag sets and the sets themselves, and records the
+// new generation for each tag. KEYS holds the counter, then the tag sets,
+// then the invalidation records of the same tags.
+var invalidateScript = redis.NewScript(`
+local generation = redis.call('INCR', KEYS[1])
+local n = (#KEYS - 1) / 2
+for i = 2, n + 1 do
+	local members = redis.call('SMEMBERS', KEYS[i])
+	for j = 1, #members, 1000 do
+		redis.call('DEL', unpack(members, j, math.min(j + 999, #members)))
+	end
+	redis.call('DEL', KEYS[i])
+	redis.call('SET', KEYS[n + i], generation, 'PX', ARGV[1])
+end
+return generation
+`)
+
+// unlockScript deletes the lock only if it still holds the caller's token,
+// so that a lock that expired and was taken over is left alone.
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type RedisCache struct {
+	client *redis.Client
+}
+
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (c *RedisCache) Get(key string) ([]byte, bool, error) {
+	value, err := c.client.Get(keyPrefix + key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Generation() (int64, error) {
+	generation, err := c.client.Get(generationKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return generation, err
+}
+
+func (c *RedisCache) Set(key string, value []byte, ttl time.Duration, generation int64, tags ...string) (bool, error) {
+	keys := make([]string, 0, 2*len(tags)+1)
+	keys = append(keys, keyPrefix+key)
+	keys = appendTagKeys(keys, tags)
+	stored, err := setScript.Run(c.client, keys, value, ttl.Milliseconds(), generation).Int()
+	return stored == 1, err
+}
+
+func (c *RedisCache) Invalidate(tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, 2*len(tags)+1)
+	keys = append(keys, generationKey)
+	keys = appendTagKeys(keys, tags)
+	return invalidateScript.Run(c.client, keys, generationTTL.Milliseconds()).Err()
+}
+
+// appendTagKeys appends the tag sets of tags followed by their
+// invalidation records, the layout both scripts expect.
+func appendTagKeys(keys []string, tags []string) []string {
+	for _, tag := range tags {
+		keys = append(keys, tagPrefix+tag)
+	}
+	for _, tag := range tags {
+		keys = append(keys, invalidatedPrefix+tag)
+	}
+	return keys
+}
+
+func (c *RedisCache) TryLock(key string, ttl time.Duration) (func(), bool, error) {
+	lockKey := lockPrefix + key
+	token := xid.New().String()
+	ok, err := c.client.SetNX(lockKey, token, ttl).Result()
+	if err != nil || !ok {
+		return func() {}, false, err
+	}
+	return func() {
+		unlockScript.Run(c.client, []string{lockKey}, token)
+	}, true, nil
+}
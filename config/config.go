@@ -0,0 +1,239 @@
+// Package config loads the API settings.
+//
+// Settings are resolved in increasing order of precedence from built-in
+// defaults, an optional YAML or TOML file named by CONFIG_FILE, a .env file
+// in the working directory and finally the process environment.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StoreMongo  = "mongo"
+	StoreMemory = "memory"
+)
+
+const (
+	ImagesFilesystem = "filesystem"
+	ImagesGridFS     = "gridfs"
+)
+
+type Config struct {
+	Store   string        `yaml:"store" toml:"store"`
+	Listen  string        `yaml:"listen" toml:"listen"`
+	Mongo   MongoConfig   `yaml:"mongo" toml:"mongo"`
+	Redis   RedisConfig   `yaml:"redis" toml:"redis"`
+	Session SessionConfig `yaml:"session" toml:"session"`
+	Auth    AuthConfig    `yaml:"auth" toml:"auth"`
+	CORS    CORSConfig    `yaml:"cors" toml:"cors"`
+	TLS     TLSConfig     `yaml:"tls" toml:"tls"`
+	Cache   CacheConfig   `yaml:"cache" toml:"cache"`
+	Trash   TrashConfig   `yaml:"trash" toml:"trash"`
+	Publish PublishConfig `yaml:"publish" toml:"publish"`
+	Images  ImagesConfig  `yaml:"images" toml:"images"`
+	// ConnectTimeout bounds each attempt to reach MongoDB or Redis at
+	// startup; ConnectAttempts is the number of attempts before giving up.
+	ConnectTimeout  Duration `yaml:"connectTimeout" toml:"connectTimeout"`
+	ConnectAttempts int      `yaml:"connectAttempts" toml:"connectAttempts"`
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// finish after a termination signal.
+	ShutdownTimeout Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+}
+
+type MongoConfig struct {
+	URI      string `yaml:"uri" toml:"uri"`
+	Database string `yaml:"database" toml:"database"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
+}
+
+type SessionConfig struct {
+	Secret     string `yaml:"secret" toml:"secret"`
+	CookieName string `yaml:"cookieName" toml:"cookieName"`
+	Domain     string `yaml:"domain" toml:"domain"`
+	Path       string `yaml:"path" toml:"path"`
+	// MaxAge is the cookie lifetime in seconds.
+	MaxAge   int    `yaml:"maxAge" toml:"maxAge"`
+	Secure   bool   `yaml:"secure" toml:"secure"`
+	HTTPOnly bool   `yaml:"httpOnly" toml:"httpOnly"`
+	SameSite string `yaml:"sameSite" toml:"sameSite"`
+}
+
+type AuthConfig struct {
+	Mode           string `yaml:"mode" toml:"mode"`
+	JWTSecret      string `yaml:"jwtSecret" toml:"jwtSecret"`
+	PasswordHasher string `yaml:"passwordHasher" toml:"passwordHasher"`
+	AdminUsername  string `yaml:"adminUsername" toml:"adminUsername"`
+	AdminPassword  string `yaml:"adminPassword" toml:"adminPassword"`
+}
+
+type CORSConfig struct {
+	// AllowOrigins lists the permitted origins. Empty allows every origin.
+	AllowOrigins []string `yaml:"allowOrigins" toml:"allowOrigins"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"certFile" toml:"certFile"`
+	KeyFile  string `yaml:"keyFile" toml:"keyFile"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type CacheConfig struct {
+	RecipeTTL Duration `yaml:"recipeTTL" toml:"recipeTTL"`
+	ListTTL   Duration `yaml:"listTTL" toml:"listTTL"`
+	SearchTTL Duration `yaml:"searchTTL" toml:"searchTTL"`
+	// StaleTTL enables stale-while-revalidate: expired entries are served
+	// for this much longer while being refreshed. Zero disables it.
+	StaleTTL Duration `yaml:"staleTTL" toml:"staleTTL"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted recipes stay restorable before they
+	// are purged. Zero keeps them until an admin purges them.
+	Retention Duration `yaml:"retention" toml:"retention"`
+	// PurgeInterval is how often expired recipes are looked for.
+	PurgeInterval Duration `yaml:"purgeInterval" toml:"purgeInterval"`
+}
+
+type PublishConfig struct {
+	// Interval is how often recipes scheduled for publication are looked
+	// for; a recipe is published up to this long after its time.
+	Interval Duration `yaml:"interval" toml:"interval"`
+}
+
+type ImagesConfig struct {
+	// Storage is where uploaded images are kept: filesystem or gridfs.
+	// GridFS requires STORE=mongo.
+	Storage string `yaml:"storage" toml:"storage"`
+	// Dir is the directory images are written to with filesystem storage.
+	Dir string `yaml:"dir" toml:"dir"`
+	// MaxSize is the largest accepted upload in bytes.
+	MaxSize int `yaml:"maxSize" toml:"maxSize"`
+}
+
+// Duration is a time.Duration written as "90s" or "10m" in files and
+// environment variables.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() Config {
+	return Config{
+		Store:  StoreMongo,
+		Listen: ":3000",
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Session: SessionConfig{
+			CookieName: "recipes_api",
+			Path:       "/",
+			MaxAge:     86400 * 30,
+			HTTPOnly:   true,
+			SameSite:   "lax",
+		},
+		Auth: AuthConfig{
+			Mode:           "session",
+			PasswordHasher: "bcrypt",
+		},
+		Cache: CacheConfig{
+			RecipeTTL: Duration(time.Hour),
+			ListTTL:   Duration(10 * time.Minute),
+			SearchTTL: Duration(5 * time.Minute),
+		},
+		Trash: TrashConfig{
+			Retention:     Duration(30 * 24 * time.Hour),
+			PurgeInterval: Duration(time.Hour),
+		},
+		Publish: PublishConfig{
+			Interval: Duration(time.Minute),
+		},
+		Images: ImagesConfig{
+			Storage: ImagesFilesystem,
+			Dir:     "uploads",
+			MaxSize: 5 << 20,
+		},
+		ConnectTimeout:  Duration(5 * time.Second),
+		ConnectAttempts: 5,
+		ShutdownTimeout: Duration(15 * time.Second),
+	}
+}
+
+// Load resolves the configuration and validates it. The returned error
+// lists every missing or invalid setting.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("config: reading .env: %w", err)
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	var problems []string
+	problems = append(problems, applyEnv(&cfg)...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config: unsupported file type %q, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Error reports every problem found while loading the configuration.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
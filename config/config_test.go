@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(yamlFile, []byte("store: memory\nlisten: \":8080\"\ncache:\n  listTTL: 2m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tomlFile := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(tomlFile, []byte("store = \"memory\"\nlisten = \":9090\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"store":"memory"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	secret := strings.Repeat("s", minSecretLength)
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(Config) bool
+		wantErr string
+	}{
+		{
+			name: "yaml file",
+			env:  map[string]string{"CONFIG_FILE": yamlFile, "SESSION_SECRET": secret},
+			check: func(c Config) bool {
+				return c.Store == StoreMemory && c.Listen == ":8080" && c.Cache.ListTTL == Duration(2*time.Minute)
+			},
+		},
+		{
+			name:  "toml file",
+			env:   map[string]string{"CONFIG_FILE": tomlFile, "SESSION_SECRET": secret},
+			check: func(c Config) bool { return c.Store == StoreMemory && c.Listen == ":9090" },
+		},
+		{
+			name: "environment overrides the file",
+			env: map[string]string{"CONFIG_FILE": yamlFile, "SESSION_SECRET": secret, "LISTEN_ADDR": ":7070",
+				"CACHE_LIST_TTL": "30s", "CORS_ALLOW_ORIGINS": "https://a.example, https://b.example"},
+			check: func(c Config) bool {
+				return c.Listen == ":7070" && c.Cache.ListTTL == Duration(30*time.Second) && len(c.CORS.AllowOrigins) == 2
+			},
+		},
+		{
+			name:    "unparsable values",
+			env:     map[string]string{"STORE": StoreMemory, "SESSION_SECRET": secret, "REDIS_DB": "one", "CACHE_LIST_TTL": "soon"},
+			wantErr: `REDIS_DB="one" is not an integer`,
+		},
+		{
+			name:    "unsupported file",
+			env:     map[string]string{"CONFIG_FILE": jsonFile},
+			wantErr: "unsupported file type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cfg, err := Load()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("Load = %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	t.Setenv("STORE", StoreMemory)
+	t.Setenv("SESSION_SECRET", strings.Repeat("s", minSecretLength))
+	t.Setenv("REDIS_DB", "one")
+	t.Setenv("CONNECT_ATTEMPTS", "0")
+
+	_, err := Load()
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 2 {
+		t.Fatalf("Load error = %v, want REDIS_DB and CONNECT_ATTEMPTS", err)
+	}
+}
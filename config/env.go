@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyEnv overrides cfg with the environment variables that are set and
+// returns a description of every value that could not be parsed.
+func applyEnv(cfg *Config) []string {
+	var env envReader
+
+	env.string("STORE", &cfg.Store)
+	env.string("LISTEN_ADDR", &cfg.Listen)
+
+	env.string("MONGO_URI", &cfg.Mongo.URI)
+	env.string("MONGO_DATABASE", &cfg.Mongo.Database)
+
+	env.string("REDIS_ADDR", &cfg.Redis.Addr)
+	env.string("REDIS_PASSWORD", &cfg.Redis.Password)
+	env.int("REDIS_DB", &cfg.Redis.DB)
+
+	env.string("SESSION_SECRET", &cfg.Session.Secret)
+	env.string("SESSION_COOKIE_NAME", &cfg.Session.CookieName)
+	env.string("SESSION_COOKIE_DOMAIN", &cfg.Session.Domain)
+	env.string("SESSION_COOKIE_PATH", &cfg.Session.Path)
+	env.int("SESSION_COOKIE_MAX_AGE", &cfg.Session.MaxAge)
+	env.bool("SESSION_COOKIE_SECURE", &cfg.Session.Secure)
+	env.bool("SESSION_COOKIE_HTTP_ONLY", &cfg.Session.HTTPOnly)
+	env.string("SESSION_COOKIE_SAME_SITE", &cfg.Session.SameSite)
+
+	env.string("AUTH_MODE", &cfg.Auth.Mode)
+	env.string("JWT_SECRET", &cfg.Auth.JWTSecret)
+	env.string("PASSWORD_HASHER", &cfg.Auth.PasswordHasher)
+	env.string("ADMIN_USERNAME", &cfg.Auth.AdminUsername)
+	env.string("ADMIN_PASSWORD", &cfg.Auth.AdminPassword)
+
+	env.list("CORS_ALLOW_ORIGINS", &cfg.CORS.AllowOrigins)
+
+	env.string("TLS_CERT_FILE", &cfg.TLS.CertFile)
+	env.string("TLS_KEY_FILE", &cfg.TLS.KeyFile)
+
+	env.duration("CACHE_RECIPE_TTL", &cfg.Cache.RecipeTTL)
+	env.duration("CACHE_LIST_TTL", &cfg.Cache.ListTTL)
+	env.duration("CACHE_SEARCH_TTL", &cfg.Cache.SearchTTL)
+	env.duration("CACHE_STALE_TTL", &cfg.Cache.StaleTTL)
+	env.duration("TRASH_RETENTION", &cfg.Trash.Retention)
+	env.duration("TRASH_PURGE_INTERVAL", &cfg.Trash.PurgeInterval)
+	env.duration("PUBLISH_INTERVAL", &cfg.Publish.Interval)
+
+	env.string("IMAGES_STORAGE", &cfg.Images.Storage)
+	env.string("IMAGES_DIR", &cfg.Images.Dir)
+	env.int("IMAGES_MAX_SIZE", &cfg.Images.MaxSize)
+
+	env.duration("CONNECT_TIMEOUT", &cfg.ConnectTimeout)
+	env.int("CONNECT_ATTEMPTS", &cfg.ConnectAttempts)
+	env.duration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout)
+
+	return env.problems
+}
+
+type envReader struct {
+	problems []string
+}
+
+func (r *envReader) lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+func (r *envReader) invalid(name, value, expected string) {
+	r.problems = append(r.problems, fmt.Sprintf("%s=%q is not %s", name, value, expected))
+}
+
+func (r *envReader) string(name string, dst *string) {
+	if value, ok := r.lookup(name); ok {
+		*dst = value
+	}
+}
+
+func (r *envReader) int(name string, dst *int) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		r.invalid(name, value, "an integer")
+		return
+	}
+	*dst = parsed
+}
+
+func (r *envReader) bool(name string, dst *bool) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		r.invalid(name, value, "a boolean")
+		return
+	}
+	*dst = parsed
+}
+
+func (r *envReader) duration(name string, dst *Duration) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	if err := dst.UnmarshalText([]byte(value)); err != nil {
+		r.invalid(name, value, "a duration such as 30s or 10m")
+	}
+}
+
+// list reads a comma separated list.
+func (r *envReader) list(name string, dst *[]string) {
+	value, ok := r.lookup(name)
+	if !ok {
+		return
+	}
+	*dst = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*dst = append(*dst, item)
+		}
+	}
+}
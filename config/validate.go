@@ -0,0 +1,149 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+const minSecretLength = 32
+
+// validate returns a description of every missing or invalid setting.
+// Settings are named after their environment variable whichever source
+// they came from.
+func (c Config) validate() []string {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	switch c.Store {
+	case StoreMongo:
+		if c.Mongo.URI == "" {
+			add("MONGO_URI is required when STORE=mongo")
+		}
+		if c.Mongo.Database == "" {
+			add("MONGO_DATABASE is required when STORE=mongo")
+		}
+		if c.Redis.Addr == "" {
+			add("REDIS_ADDR is required when STORE=mongo")
+		}
+	case StoreMemory:
+	default:
+		add("STORE must be %q or %q, got %q", StoreMongo, StoreMemory, c.Store)
+	}
+
+	if c.Listen == "" {
+		add("LISTEN_ADDR must not be empty")
+	}
+	if c.Redis.DB < 0 {
+		add("REDIS_DB must not be negative")
+	}
+
+	switch c.Auth.Mode {
+	case "session":
+		if len(c.Session.Secret) < minSecretLength {
+			add("SESSION_SECRET must be at least %d bytes when AUTH_MODE=session", minSecretLength)
+		}
+	case "jwt":
+		if len(c.Auth.JWTSecret) < minSecretLength {
+			add("JWT_SECRET must be at least %d bytes when AUTH_MODE=jwt", minSecretLength)
+		}
+	default:
+		add("AUTH_MODE must be \"session\" or \"jwt\", got %q", c.Auth.Mode)
+	}
+	switch c.Auth.PasswordHasher {
+	case "bcrypt", "argon2id":
+	default:
+		add("PASSWORD_HASHER must be \"bcrypt\" or \"argon2id\", got %q", c.Auth.PasswordHasher)
+	}
+
+	if c.Session.CookieName == "" {
+		add("SESSION_COOKIE_NAME must not be empty")
+	}
+	if c.Session.MaxAge < 0 {
+		add("SESSION_COOKIE_MAX_AGE must not be negative")
+	}
+	switch c.Session.SameSite {
+	case "", "lax", "strict", "none":
+	default:
+		add("SESSION_COOKIE_SAME_SITE must be lax, strict or none, got %q", c.Session.SameSite)
+	}
+	if c.Session.SameSite == "none" && !c.Session.Secure {
+		add("SESSION_COOKIE_SECURE must be true when SESSION_COOKIE_SAME_SITE=none")
+	}
+
+	for _, origin := range c.CORS.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			add("CORS_ALLOW_ORIGINS entry %q must look like https://example.com", origin)
+		}
+	}
+
+	if c.TLS.Enabled() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			add("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+		}
+		checkFile := func(name, path string) {
+			if path == "" {
+				return
+			}
+			if _, err := os.Stat(path); err != nil {
+				add("%s %q is not readable: %v", name, path, err)
+			}
+		}
+		checkFile("TLS_CERT_FILE", c.TLS.CertFile)
+		checkFile("TLS_KEY_FILE", c.TLS.KeyFile)
+	}
+
+	if time.Duration(c.Cache.RecipeTTL) <= 0 {
+		add("CACHE_RECIPE_TTL must be positive")
+	}
+	if time.Duration(c.Cache.ListTTL) <= 0 {
+		add("CACHE_LIST_TTL must be positive")
+	}
+	if time.Duration(c.Cache.SearchTTL) <= 0 {
+		add("CACHE_SEARCH_TTL must be positive")
+	}
+	if time.Duration(c.Cache.StaleTTL) < 0 {
+		add("CACHE_STALE_TTL must not be negative")
+	}
+	if time.Duration(c.Trash.Retention) < 0 {
+		add("TRASH_RETENTION must not be negative")
+	}
+	if time.Duration(c.Trash.PurgeInterval) <= 0 {
+		add("TRASH_PURGE_INTERVAL must be positive")
+	}
+	if time.Duration(c.Publish.Interval) <= 0 {
+		add("PUBLISH_INTERVAL must be positive")
+	}
+	switch c.Images.Storage {
+	case ImagesFilesystem:
+		if c.Images.Dir == "" {
+			add("IMAGES_DIR is required when IMAGES_STORAGE=filesystem")
+		}
+	case ImagesGridFS:
+		if c.Store != StoreMongo {
+			add("IMAGES_STORAGE=gridfs requires STORE=mongo")
+		}
+	default:
+		add("IMAGES_STORAGE must be %q or %q, got %q", ImagesFilesystem, ImagesGridFS, c.Images.Storage)
+	}
+	if c.Images.MaxSize <= 0 {
+		add("IMAGES_MAX_SIZE must be positive")
+	}
+	if time.Duration(c.ConnectTimeout) <= 0 {
+		add("CONNECT_TIMEOUT must be positive")
+	}
+	if c.ConnectAttempts < 1 {
+		add("CONNECT_ATTEMPTS must be at least 1")
+	}
+	if time.Duration(c.ShutdownTimeout) <= 0 {
+		add("SHUTDOWN_TIMEOUT must be positive")
+	}
+	return problems
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// valid returns settings that pass validation.
+func valid() Config {
+	cfg := Default()
+	cfg.Mongo.URI = "mongodb://localhost:27017"
+	cfg.Mongo.Database = "recipes"
+	cfg.Session.Secret = strings.Repeat("s", minSecretLength)
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Config)
+		want   string
+	}{
+		{"valid", func(*Config) {}, ""},
+		{"memory store needs no mongo", func(c *Config) { c.Store = StoreMemory; c.Mongo = MongoConfig{} }, ""},
+		{"unknown store", func(c *Config) { c.Store = "postgres" }, "STORE must be"},
+		{"mongo without uri", func(c *Config) { c.Mongo.URI = "" }, "MONGO_URI is required"},
+		{"mongo without redis", func(c *Config) { c.Redis.Addr = "" }, "REDIS_ADDR is required"},
+		{"short session secret", func(c *Config) { c.Session.Secret = "secret" }, "SESSION_SECRET must be at least 32 bytes"},
+		{"jwt without secret", func(c *Config) { c.Auth.Mode = "jwt" }, "JWT_SECRET must be at least 32 bytes"},
+		{"unknown auth mode", func(c *Config) { c.Auth.Mode = "basic" }, "AUTH_MODE must be"},
+		{"unknown hasher", func(c *Config) { c.Auth.PasswordHasher = "md5" }, "PASSWORD_HASHER must be"},
+		{"same site none over http", func(c *Config) { c.Session.SameSite = "none" }, "SESSION_COOKIE_SECURE must be true"},
+		{"same site none over https", func(c *Config) { c.Session.SameSite = "none"; c.Session.Secure = true }, ""},
+		{"origin with path", func(c *Config) { c.CORS.AllowOrigins = []string{"https://example.com/app"} }, "CORS_ALLOW_ORIGINS entry"},
+		{"any origin", func(c *Config) { c.CORS.AllowOrigins = []string{"*", "https://example.com"} }, ""},
+		{"certificate without key", func(c *Config) { c.TLS.CertFile = "config.go" }, "must be set together"},
+		{"missing certificate", func(c *Config) { c.TLS = TLSConfig{CertFile: "missing.pem", KeyFile: "config.go"} }, "is not readable"},
+		{"zero cache ttl", func(c *Config) { c.Cache.ListTTL = 0 }, "CACHE_LIST_TTL must be positive"},
+		{"negative stale ttl", func(c *Config) { c.Cache.StaleTTL = Duration(-time.Second) }, "CACHE_STALE_TTL must not be negative"},
+		{"gridfs in memory", func(c *Config) { c.Store = StoreMemory; c.Images.Storage = ImagesGridFS }, "requires STORE=mongo"},
+		{"no connect attempts", func(c *Config) { c.ConnectAttempts = 0 }, "CONNECT_ATTEMPTS must be at least 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(&cfg)
+			problems := cfg.validate()
+			if tt.want == "" {
+				if len(problems) > 0 {
+					t.Errorf("problems = %q, want none", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0], tt.want) {
+				t.Errorf("problems = %q, want one containing %q", problems, tt.want)
+			}
+		})
+	}
+}
+
+// Every problem is reported at once.
+func TestValidateListsEveryProblem(t *testing.T) {
+	cfg := Default()
+	if problems := cfg.validate(); len(problems) != 3 {
+		t.Errorf("problems = %q, want MONGO_URI, MONGO_DATABASE and SESSION_SECRET", problems)
+	}
+}
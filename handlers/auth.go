@@ -2,21 +2,41 @@ package handlers
 
 import (
 	"context"
-	"crypto/sha256"
+	"errors"
+	"log"
 	"net/http"
 	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/store"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/rs/xid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuthMode selects how clients prove their identity.
+type AuthMode string
+
+const (
+	// AuthModeSession keeps a session token in a cookie-backed session.
+	AuthModeSession AuthMode = "session"
+	// AuthModeJWT issues signed tokens sent back in the Authorization header.
+	AuthModeJWT AuthMode = "jwt"
+)
+
+var errRevoked = errors.New("credential revoked")
+
+const (
+	jwtTTL           = 10 * time.Minute
+	jwtRefreshWindow = 30 * time.Second
 )
 
 type Claims struct {
-	Username string `json:"username"`
+	Username     string `json:"username"`
+	TokenVersion int    `json:"ver"`
 	jwt.RegisteredClaims
 }
 
@@ -26,14 +46,28 @@ type JWTOutput struct {
 }
 
 type AuthHandler struct {
-	collection *mongo.Collection
-	ctx        context.Context
+	users     store.UserStore
+	hasher    password.Hasher
+	ctx       context.Context
+	mode      AuthMode
+	jwtSecret []byte
+	// dummyHash is verified against when the user does not exist so that
+	// unknown usernames take as long to reject as wrong passwords.
+	dummyHash string
 }
 
-func NewAuthHandler(ctx context.Context, collection *mongo.Collection) *AuthHandler {
+// NewAuthHandler returns an AuthHandler for the given mode. jwtSecret is
+// only used in AuthModeJWT.
+func NewAuthHandler(ctx context.Context, users store.UserStore, hasher password.Hasher,
+	mode AuthMode, jwtSecret []byte) *AuthHandler {
+	dummyHash, _ := hasher.Hash("dummy password")
 	return &AuthHandler{
-		collection: collection,
-		ctx:        ctx,
+		users:     users,
+		hasher:    hasher,
+		ctx:       ctx,
+		mode:      mode,
+		jwtSecret: jwtSecret,
+		dummyHash: dummyHash,
 	}
 }
 
@@ -44,9 +78,14 @@ func NewAuthHandler(ctx context.Context, collection *mongo.Collection) *AuthHand
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Success		200		{object}	string
+//	@Success		200		{object}	Problem
 //	@Router			/signout [post]
 func (handler *AuthHandler) SignOutHandler(c *gin.Context) {
+	if handler.mode == AuthModeJWT {
+		// Tokens are stateless; the client discards its copy.
+		c.JSON(http.StatusOK, gin.H{"message": "Signed out..."})
+		return
+	}
 	session := sessions.Default(c)
 	session.Clear()
 	session.Save()
@@ -56,27 +95,41 @@ func (handler *AuthHandler) SignOutHandler(c *gin.Context) {
 // SignInHandler godoc
 //
 //	@Summary		Signin
-//	@Description	Sign in user
+//	@Description	Sign in user. Returns a JWTOutput in jwt mode.
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			recipe	body		models.User	true "comment"
-//	@Success		200		{object}	string
+//	@Success		200		{object}	JWTOutput
 //	@Router			/signin [post]
 func (handler *AuthHandler) SignInHandler(c *gin.Context) {
 	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &user) {
+		return
+	}
+	stored, ok := handler.checkPassword(user.Username, user.Password)
+	if !ok {
+		problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+		return
+	}
+	if stored.Disabled {
+		forbidden(c, CodeAccountDisabled, "Account is disabled")
 		return
 	}
-	h := sha256.New()
-	cur := handler.collection.FindOne(handler.ctx, bson.M{
-		"username": user.Username,
-		"password": string(h.Sum([]byte(user.Password))),
-	})
 
-	if cur.Err() != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+	handler.startSession(c, stored, "User signed in")
+}
+
+// startSession signs the user in according to the auth mode and writes the
+// response: a JWTOutput in jwt mode, message otherwise.
+func (handler *AuthHandler) startSession(c *gin.Context, user models.User, message string) {
+	if handler.mode == AuthModeJWT {
+		output, err := handler.issueToken(user)
+		if err != nil {
+			internalError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, output)
 		return
 	}
 
@@ -84,49 +137,235 @@ func (handler *AuthHandler) SignInHandler(c *gin.Context) {
 	session := sessions.Default(c)
 	session.Set("username", user.Username)
 	session.Set("token", sessionToken)
+	session.Set("version", user.TokenVersion)
 	session.Save()
 
-	c.JSON(http.StatusOK, gin.H{"message": "User signed in"})
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// checkPassword verifies the credentials and upgrades the stored hash when
+// it was produced by an outdated scheme.
+func (handler *AuthHandler) checkPassword(username, plaintext string) (models.User, bool) {
+	stored, err := handler.users.GetUser(handler.ctx, username)
+	if err != nil {
+		handler.hasher.Verify(handler.dummyHash, plaintext)
+		return stored, false
+	}
+
+	ok, err := handler.hasher.Verify(stored.Password, plaintext)
+	if err != nil || !ok {
+		return stored, false
+	}
+
+	if handler.hasher.NeedsRehash(stored.Password) {
+		if hash, err := handler.hasher.Hash(plaintext); err == nil {
+			if err := handler.users.UpdatePassword(handler.ctx, username, hash); err != nil {
+				log.Printf("Failed to rehash password for %s: %v", username, err)
+			}
+		}
+	}
+	return stored, true
 }
 
 // RefreshHandler godoc
 //
 //	@Summary		Refresh session
-//	@Description	refresh session for user
+//	@Description	refresh session for user. In jwt mode a new token is only issued within 30 seconds of expiry.
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Success		200		{object}	string
+//	@Success		200		{object}	JWTOutput
 //	@Router			/refresh [post]
 func (handler *AuthHandler) RefreshHandler(c *gin.Context) {
+	if handler.mode == AuthModeJWT {
+		handler.refreshToken(c)
+		return
+	}
 
 	session := sessions.Default(c)
 	sessionToken := session.Get("token")
 	sessionUser := session.Get("username")
 
 	if sessionToken == nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session cookie"})
+		problem(c, http.StatusUnauthorized, CodeNotAuthenticated, "Invalid session cookie")
+		return
+	}
+
+	user, err := handler.activeUser(sessionUser, session.Get("version"))
+	if err != nil {
+		problem(c, http.StatusUnauthorized, CodeNotAuthenticated, "Invalid session cookie")
 		return
 	}
 
 	sessionToken = xid.New().String()
-	session.Set("username", sessionUser.(string))
+	session.Set("username", user.Username)
 	session.Set("token", sessionToken)
+	session.Set("version", user.TokenVersion)
 	session.Save()
 
 	c.JSON(http.StatusOK, gin.H{"message": "New session issued"})
 }
 
+func (handler *AuthHandler) refreshToken(c *gin.Context) {
+	claims, err := handler.parseToken(bearerToken(c))
+	if err != nil {
+		tokenRejected(c, CodeNotAuthenticated, "Invalid token")
+		return
+	}
+
+	if time.Until(claims.ExpiresAt.Time) > jwtRefreshWindow {
+		problem(c, http.StatusBadRequest, CodeTokenNotExpiring, "Token is not expired yet")
+		return
+	}
+
+	user, err := handler.activeUser(claims.Username, claims.TokenVersion)
+	if err != nil {
+		tokenRejected(c, CodeNotAuthenticated, "Invalid token")
+		return
+	}
+
+	output, err := handler.issueToken(user)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, output)
+}
+
+func (handler *AuthHandler) issueToken(user models.User) (JWTOutput, error) {
+	now := time.Now()
+	expires := now.Add(jwtTTL)
+	claims := &Claims{
+		Username:     user.Username,
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        xid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(handler.jwtSecret)
+	if err != nil {
+		return JWTOutput{}, err
+	}
+	return JWTOutput{Token: signed, Expires: expires}, nil
+}
+
+func (handler *AuthHandler) parseToken(tokenValue string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenValue, claims, func(token *jwt.Token) (interface{}, error) {
+		return handler.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// bearerToken extracts the token from an Authorization header using the
+// Bearer scheme. It returns the empty string for any other header.
+func bearerToken(c *gin.Context) string {
+	header := strings.TrimSpace(c.GetHeader("Authorization"))
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// tokenRejected aborts a request in jwt mode with a 401 carrying the
+// WWW-Authenticate challenge of RFC 6750. A token that was sent but is not
+// accepted is flagged as invalid_token.
+func tokenRejected(c *gin.Context, code, detail string) {
+	challenge := "Bearer"
+	if bearerToken(c) != "" {
+		challenge = `Bearer error="invalid_token"`
+	}
+	c.Header("WWW-Authenticate", challenge)
+	problem(c, http.StatusUnauthorized, code, detail)
+}
+
+// activeUser loads the user a session or token was issued to and checks
+// that the account is still enabled and that the credential has not been
+// revoked since.
+func (handler *AuthHandler) activeUser(username, version interface{}) (models.User, error) {
+	name, _ := username.(string)
+	issued, _ := version.(int)
+	user, err := handler.users.GetUser(handler.ctx, name)
+	if err != nil {
+		return user, err
+	}
+	if user.Disabled || user.TokenVersion != issued {
+		return user, errRevoked
+	}
+	return user, nil
+}
+
+// AuthMiddleware rejects unauthenticated requests. The caller's username is
+// stored in the gin context under "username" and the user under "user".
 func (handler *AuthHandler) AuthMiddleware() gin.HandlerFunc {
+	if handler.mode == AuthModeJWT {
+		return func(c *gin.Context) {
+			claims, err := handler.parseToken(bearerToken(c))
+			if err != nil {
+				tokenRejected(c, CodeNotAuthenticated, "Not logged")
+				return
+			}
+			user, err := handler.activeUser(claims.Username, claims.TokenVersion)
+			if err != nil {
+				tokenRejected(c, CodeCredentialRevoked, "Session revoked")
+				return
+			}
+			c.Set("username", user.Username)
+			c.Set("user", user)
+			c.Next()
+		}
+	}
+
 	return func(c *gin.Context) {
 		session := sessions.Default(c)
 		sessionToken := session.Get("token")
 
 		if sessionToken == nil {
-			c.JSON(http.StatusForbidden, gin.H{
-				"message": "Not logged",
-			})
-			c.Abort()
+			problem(c, http.StatusUnauthorized, CodeNotAuthenticated, "Not logged")
+			return
+		}
+		user, err := handler.activeUser(session.Get("username"), session.Get("version"))
+		if err != nil {
+			session.Clear()
+			session.Save()
+			problem(c, http.StatusUnauthorized, CodeCredentialRevoked, "Session revoked")
+			return
+		}
+		c.Set("username", user.Username)
+		c.Set("user", user)
+		c.Next()
+	}
+}
+
+// OptionalAuthMiddleware identifies the caller like AuthMiddleware when
+// valid credentials are sent, and lets the request through anonymously
+// otherwise, for public routes whose response depends on who is asking.
+func (handler *AuthHandler) OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var user models.User
+		var err error
+		if handler.mode == AuthModeJWT {
+			var claims *Claims
+			if claims, err = handler.parseToken(bearerToken(c)); err == nil {
+				user, err = handler.activeUser(claims.Username, claims.TokenVersion)
+			}
+		} else {
+			session := sessions.Default(c)
+			if session.Get("token") == nil {
+				err = errRevoked
+			} else {
+				user, err = handler.activeUser(session.Get("username"), session.Get("version"))
+			}
+		}
+		if err == nil {
+			c.Set("username", user.Username)
+			c.Set("user", user)
 		}
 		c.Next()
 	}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/store"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newJWTAuth returns an AuthHandler in jwt mode and a token issued to a
+// user with the given roles.
+func newJWTAuth(t *testing.T, roles ...string) (*AuthHandler, string) {
+	t.Helper()
+	hasher, err := password.New("bcrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := store.NewMemoryUserStore()
+	user := models.User{Username: "alice", Roles: roles}
+	if err := users.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthHandler(context.Background(), users, hasher, AuthModeJWT, []byte("0123456789abcdef0123456789abcdef"))
+	output, err := auth.issueToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth, output.Token
+}
+
+func TestAuthMiddlewareBearerScheme(t *testing.T) {
+	auth, token := newJWTAuth(t, models.RoleAuthor)
+	router := gin.New()
+	router.GET("/", auth.AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, currentUser(c).Username)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		challenge     string
+	}{
+		{"missing", "", http.StatusUnauthorized, "Bearer"},
+		{"raw token", token, http.StatusUnauthorized, "Bearer"},
+		{"other scheme", "Basic " + token, http.StatusUnauthorized, "Bearer"},
+		{"invalid token", "Bearer not-a-token", http.StatusUnauthorized, `Bearer error="invalid_token"`},
+		{"bearer", "Bearer " + token, http.StatusOK, ""},
+		{"lower case scheme", "bearer " + token, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.challenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"testing"
+)
+
+func TestCreateRecipeSetsAuthor(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodPost, "/recipes", "bob", `{"name":"Stew","ingredients":["1 kg beef"],"author":"alice"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var recipe models.Recipe
+	decode(t, rec, &recipe)
+	if recipe.Author != "bob" || recipe.Status != models.StatusDraft {
+		t.Errorf("author %q in %s, want bob's draft", recipe.Author, recipe.Status)
+	}
+}
+
+// Only the author of a recipe and editors may change it.
+func TestRecipeOwnership(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		suffix  string
+		body    string
+		headers []string
+	}{
+		{"put", http.MethodPut, "", `{"name":"Stew","ingredients":["1 kg beef"]}`, nil},
+		{"patch", http.MethodPatch, "", `{"name":"Stew"}`, []string{"Content-Type", mergePatchType}},
+		{"revert", http.MethodPost, "/revert/1", "", nil},
+		{"archive", http.MethodPost, "/status", `{"status":"archived"}`, nil},
+		{"delete", http.MethodDelete, "", "", nil},
+	}
+	users := []struct {
+		user string
+		want int
+	}{
+		{"bob", http.StatusForbidden},
+		{"alice", http.StatusOK},
+		{"erin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		for _, u := range users {
+			t.Run(tt.name+" as "+u.user, func(t *testing.T) {
+				s := newTestServer(t)
+				recipe := s.create("alice", "Soup")
+				s.publish(recipe)
+				rec := s.do(tt.method, "/recipes/"+recipe.ID.Hex()+tt.suffix, u.user, tt.body, tt.headers...)
+				if rec.Code != u.want {
+					t.Fatalf("status = %d, want %d: %s", rec.Code, u.want, rec.Body)
+				}
+				if u.want == http.StatusForbidden && problemCode(t, rec) != CodeNotRecipeOwner {
+					t.Errorf("code = %s, want %s", problemCode(t, rec), CodeNotRecipeOwner)
+				}
+			})
+		}
+	}
+}
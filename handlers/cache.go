@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"recipes-api/store"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cache tags. Every cached response is tagged with the recipes it contains
+// so that a write to a recipe drops exactly the responses showing it.
+// Writes that can change which recipes a response contains invalidate the
+// broader tags.
+const (
+	// tagLists marks every cached /recipes page.
+	tagLists = "lists"
+	// tagSearches marks every cached /recipes/search page.
+	tagSearches = "searches"
+)
+
+// CacheTTLs configures how long each kind of response stays fresh. When
+// Stale is non-zero, expired responses are served for that much longer
+// while they are refreshed in the background.
+type CacheTTLs struct {
+	Recipe time.Duration
+	List   time.Duration
+	Search time.Duration
+	Stale  time.Duration
+}
+
+func recipeTag(id primitive.ObjectID) string {
+	return "recipe:" + id.Hex()
+}
+
+// listSortTag marks the cached pages of one sort order.
+func listSortTag(field string) string {
+	return "lists:" + field
+}
+
+func recipeCacheKey(id primitive.ObjectID) string {
+	return "recipe:" + id.Hex()
+}
+
+// hashKey keeps keys of arbitrary queries short.
+func hashKey(prefix string, query interface{}) string {
+	data, _ := json.Marshal(query)
+	sum := sha256.Sum256(data)
+	return prefix + hex.EncodeToString(sum[:16])
+}
+
+// fetch decodes the cached value of key into dst, calling load to build
+// and cache it on a miss. load returns the value to cache and its tags.
+func (handler *RecipesHandler) fetch(key string, ttl time.Duration, dst interface{},
+	load func() (interface{}, []string, error)) error {
+	data, err := handler.fetcher.Fetch(key, ttl, func() ([]byte, []string, error) {
+		value, tags, err := load()
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := json.Marshal(value)
+		return data, tags, err
+	})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
+
+func (handler *RecipesHandler) invalidate(tags ...string) {
+	if err := handler.cache.Invalidate(tags...); err != nil {
+		log.Printf("Invalidating cache tags %v failed: %v", tags, err)
+	}
+}
+
+// invalidateCreated drops responses a new recipe may appear in.
+func (handler *RecipesHandler) invalidateCreated() {
+	handler.invalidate(tagLists, tagSearches)
+}
+
+// invalidateRecipe drops the cached recipe, every page containing it and
+// the responses whose membership or order it may affect: searches, which
+// match on content, and lists ordered by name.
+func (handler *RecipesHandler) invalidateRecipe(id primitive.ObjectID) {
+	handler.invalidate(recipeTag(id), tagSearches, listSortTag("name"))
+}
+
+// invalidateStatus drops the cached recipe and every listing and search,
+// as a change of status adds the recipe to or removes it from them.
+func (handler *RecipesHandler) invalidateStatus(id primitive.ObjectID) {
+	handler.invalidate(recipeTag(id), tagLists, tagSearches)
+}
+
+// invalidateEdit drops the responses an edit may affect, which include
+// every listing when the edit moved the recipe from one status to another.
+func (handler *RecipesHandler) invalidateEdit(id primitive.ObjectID, from, to string) {
+	if from != to {
+		handler.invalidateStatus(id)
+		return
+	}
+	handler.invalidateRecipe(id)
+}
+
+// invalidateRating drops the responses showing the rating of a recipe and
+// the lists ordered by it.
+func (handler *RecipesHandler) invalidateRating(id primitive.ObjectID) {
+	handler.invalidate(recipeTag(id), tagSearches, listSortTag(store.SortByRating))
+}
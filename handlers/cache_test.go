@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"slices"
+	"testing"
+)
+
+// summary describes recipes by name and number of reviews, in name order.
+func summary(recipes []models.Recipe) []string {
+	names := make([]string, len(recipes))
+	for i, recipe := range recipes {
+		names[i] = fmt.Sprintf("%s/%d", recipe.Name, recipe.Rating.Count)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// TestWritesInvalidateCachedResponses reads every kind of cached response
+// before and after a write and expects the write to show in all of them.
+func TestWritesInvalidateCachedResponses(t *testing.T) {
+	listings := []string{"/recipes", "/recipes?sort=name", "/recipes?sort=-rating", "/recipes/search?q=soup"}
+	tests := []struct {
+		name  string
+		write func(s *testServer, tomato models.Recipe) int
+		// get is the summary of GET /recipes/{id} for the first recipe,
+		// empty when it is not found.
+		get  string
+		want []string
+	}{
+		{"edit by an editor", func(s *testServer, tomato models.Recipe) int {
+			return s.do(http.MethodPut, "/recipes/"+tomato.ID.Hex(), "erin", `{"name":"Carrot soup","ingredients":["1 carrot"]}`).Code
+		}, "Carrot soup/0", []string{"Carrot soup/0", "Onion soup/0"}},
+		{"edit sent back to review", func(s *testServer, tomato models.Recipe) int {
+			return s.do(http.MethodPut, "/recipes/"+tomato.ID.Hex(), "alice", `{"name":"Carrot soup","ingredients":["1 carrot"]}`).Code
+		}, "", []string{"Onion soup/0"}},
+		{"delete", func(s *testServer, tomato models.Recipe) int {
+			return s.do(http.MethodDelete, "/recipes/"+tomato.ID.Hex(), "alice", "").Code
+		}, "", []string{"Onion soup/0"}},
+		{"archive", func(s *testServer, tomato models.Recipe) int {
+			return s.do(http.MethodPost, "/recipes/"+tomato.ID.Hex()+"/status", "erin", `{"status":"archived"}`).Code
+		}, "", []string{"Onion soup/0"}},
+		{"review", func(s *testServer, tomato models.Recipe) int {
+			return s.do(http.MethodPost, "/recipes/"+tomato.ID.Hex()+"/reviews", "bob", `{"rating":4}`).Code
+		}, "Tomato soup/1", []string{"Onion soup/0", "Tomato soup/1"}},
+		{"publish a new recipe", func(s *testServer, tomato models.Recipe) int {
+			s.publish(s.create("bob", "Pea soup"))
+			return http.StatusOK
+		}, "Tomato soup/0", []string{"Onion soup/0", "Pea soup/0", "Tomato soup/0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			tomato := s.create("alice", "Tomato soup")
+			s.publish(tomato)
+			s.publish(s.create("bob", "Onion soup"))
+
+			read := func() (string, map[string][]string) {
+				var get string
+				if rec := s.do(http.MethodGet, "/recipes/"+tomato.ID.Hex(), "", ""); rec.Code == http.StatusOK {
+					var recipe models.Recipe
+					decode(t, rec, &recipe)
+					get = summary([]models.Recipe{recipe})[0]
+				}
+				pages := make(map[string][]string)
+				for _, path := range listings {
+					var recipes []models.Recipe
+					decode(t, s.do(http.MethodGet, path, "", ""), &recipes)
+					pages[path] = summary(recipes)
+				}
+				return get, pages
+			}
+			read()
+
+			if status := tt.write(s, tomato); status >= 300 {
+				t.Fatalf("write: status %d", status)
+			}
+			get, pages := read()
+			if get != tt.get {
+				t.Errorf("GET /recipes/{id} = %q, want %q", get, tt.get)
+			}
+			for _, path := range listings {
+				if !slices.Equal(pages[path], tt.want) {
+					t.Errorf("GET %s = %v, want %v", path, pages[path], tt.want)
+				}
+			}
+		})
+	}
+}
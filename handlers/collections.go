@@ -0,0 +1,425 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/store"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// favouritesID addresses the Favourites collection of the caller in
+	// place of a collection ID.
+	favouritesID = "favourites"
+	// maxCollectionsPerUser bounds the collections of one user, including
+	// Favourites.
+	maxCollectionsPerUser = 100
+	// maxRecipesPerCollection bounds the recipes in one collection.
+	maxRecipesPerCollection = 1000
+)
+
+// CollectionRecipe adds a recipe to a collection. Without a position the
+// recipe is appended.
+type CollectionRecipe struct {
+	RecipeID primitive.ObjectID `json:"recipeId" binding:"required"`
+	Position *int               `json:"position" binding:"omitempty,min=0"`
+}
+
+// CollectionOrder lists every recipe of a collection in the new order.
+type CollectionOrder struct {
+	RecipeIDs []primitive.ObjectID `json:"recipeIds" binding:"required"`
+}
+
+// ListCollectionsHandler godoc
+//
+//	@Summary		List my collections
+//	@Description	list the collections of the caller, Favourites first
+//	@Tags			collections
+//	@Produce		json
+//	@Success		200	{array}		models.Collection
+//	@Router			/me/collections [get]
+func (handler *RecipesHandler) ListCollectionsHandler(c *gin.Context) {
+	owner := currentUser(c).Username
+	// Favourites is created on first use; listing counts as one.
+	if _, err := handler.collections.Favourites(handler.ctx, owner); err != nil {
+		internalError(c, err)
+		return
+	}
+	collections, err := handler.collections.ListByOwner(handler.ctx, owner)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collections)
+}
+
+// CreateCollectionHandler godoc
+//
+//	@Summary		Create collection
+//	@Description	create an empty collection owned by the caller
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			collection	body		models.Collection	true	"Name, description and sharing"
+//	@Success		201			{object}	models.Collection
+//	@Failure		400			{object}	Problem
+//	@Failure		409			{object}	Problem
+//	@Router			/me/collections [post]
+func (handler *RecipesHandler) CreateCollectionHandler(c *gin.Context) {
+	var input models.Collection
+	if !bindJSON(c, &input) {
+		return
+	}
+	now := time.Now()
+	collection := models.Collection{
+		ID:          primitive.NewObjectID(),
+		Owner:       currentUser(c).Username,
+		Name:        strings.TrimSpace(input.Name),
+		Description: strings.TrimSpace(input.Description),
+		Shared:      input.Shared,
+		RecipeIDs:   make([]primitive.ObjectID, 0),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err := handler.collections.Create(handler.ctx, collection, maxCollectionsPerUser)
+	if errors.Is(err, store.ErrLimitReached) {
+		problem(c, http.StatusConflict, CodeCollectionLimit,
+			fmt.Sprintf("A user can have at most %d collections", maxCollectionsPerUser))
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.Header("Location", "/me/collections/"+collection.ID.Hex())
+	c.JSON(http.StatusCreated, collection)
+}
+
+// GetCollectionHandler godoc
+//
+//	@Summary		Get my collection
+//	@Description	get a collection of the caller with its recipe IDs in order. Use favourites as the ID for the Favourites collection.
+//	@Tags			collections
+//	@Produce		json
+//	@Param			id	path		string	true	"Collection ID or favourites"
+//	@Success		200	{object}	models.Collection
+//	@Failure		404	{object}	Problem
+//	@Router			/me/collections/{id} [get]
+func (handler *RecipesHandler) GetCollectionHandler(c *gin.Context) {
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// GetSharedCollectionHandler godoc
+//
+//	@Summary		Get shared collection
+//	@Description	get a collection its owner has shared
+//	@Tags			collections
+//	@Produce		json
+//	@Param			id	path		string	true	"Collection ID"
+//	@Success		200	{object}	models.Collection
+//	@Failure		404	{object}	Problem
+//	@Router			/collections/{id} [get]
+func (handler *RecipesHandler) GetSharedCollectionHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		collectionNotFound(c)
+		return
+	}
+	collection, err := handler.collections.Get(handler.ctx, objectId)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !collection.Shared {
+		collectionNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// UpdateCollectionHandler godoc
+//
+//	@Summary		Update my collection
+//	@Description	change the name, description and sharing of a collection. The Favourites collection keeps its name.
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		string				true	"Collection ID or favourites"
+//	@Param			collection	body		models.Collection	true	"Name, description and sharing"
+//	@Success		200			{object}	models.Collection
+//	@Failure		400			{object}	Problem
+//	@Failure		404			{object}	Problem
+//	@Failure		409			{object}	Problem
+//	@Router			/me/collections/{id} [put]
+func (handler *RecipesHandler) UpdateCollectionHandler(c *gin.Context) {
+	var input models.Collection
+	if !bindJSON(c, &input) {
+		return
+	}
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(input.Name)
+	if collection.Favourites && name != collection.Name {
+		problem(c, http.StatusConflict, CodeFavouritesCollection, "The Favourites collection cannot be renamed")
+		return
+	}
+
+	collection.Name = name
+	collection.Description = strings.TrimSpace(input.Description)
+	collection.Shared = input.Shared
+	collection.UpdatedAt = time.Now()
+	err := handler.collections.Update(handler.ctx, collection)
+	if errors.Is(err, store.ErrNotFound) {
+		collectionNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollectionHandler godoc
+//
+//	@Summary		Delete my collection
+//	@Description	delete a collection. The recipes in it are not affected. The Favourites collection cannot be deleted.
+//	@Tags			collections
+//	@Produce		json
+//	@Param			id	path		string	true	"Collection ID"
+//	@Success		200	{object}	string
+//	@Failure		404	{object}	Problem
+//	@Failure		409	{object}	Problem
+//	@Router			/me/collections/{id} [delete]
+func (handler *RecipesHandler) DeleteCollectionHandler(c *gin.Context) {
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	if collection.Favourites {
+		problem(c, http.StatusConflict, CodeFavouritesCollection, "The Favourites collection cannot be deleted")
+		return
+	}
+	err := handler.collections.Delete(handler.ctx, collection.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		collectionNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Collection has been deleted"})
+}
+
+// AddCollectionRecipeHandler godoc
+//
+//	@Summary		Add recipe to my collection
+//	@Description	add a recipe to a collection at the given position, or at the end
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Collection ID or favourites"
+//	@Param			recipe	body		CollectionRecipe	true	"Recipe and position"
+//	@Success		200		{object}	models.Collection
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Failure		409		{object}	Problem
+//	@Router			/me/collections/{id}/recipes [post]
+func (handler *RecipesHandler) AddCollectionRecipeHandler(c *gin.Context) {
+	var input CollectionRecipe
+	if !bindJSON(c, &input) {
+		return
+	}
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	if !handler.recipeExists(c, input.RecipeID) {
+		return
+	}
+
+	position := -1
+	if input.Position != nil {
+		position = *input.Position
+	}
+	err := handler.collections.AddRecipe(handler.ctx, collection.ID, input.RecipeID, position, maxRecipesPerCollection)
+	if errors.Is(err, store.ErrDuplicate) {
+		problem(c, http.StatusConflict, CodeRecipeInCollection, "The recipe is in the collection already")
+		return
+	}
+	if errors.Is(err, store.ErrLimitReached) {
+		problem(c, http.StatusConflict, CodeCollectionLimit,
+			fmt.Sprintf("A collection can hold at most %d recipes", maxRecipesPerCollection))
+		return
+	}
+	if err == nil {
+		// The recipe may have been deleted since it was looked up, after
+		// its removal from every collection had already run.
+		_, err = handler.store.Get(handler.ctx, input.RecipeID)
+		if errors.Is(err, store.ErrNotFound) {
+			if err := handler.collections.RemoveRecipe(handler.ctx, collection.ID, input.RecipeID); err != nil &&
+				!errors.Is(err, store.ErrNotFound) {
+				internalError(c, err)
+				return
+			}
+			recipeNotFound(c)
+			return
+		}
+	}
+	handler.respondCollection(c, collection.ID, err)
+}
+
+// RemoveCollectionRecipeHandler godoc
+//
+//	@Summary		Remove recipe from my collection
+//	@Description	take a recipe out of a collection
+//	@Tags			collections
+//	@Produce		json
+//	@Param			id			path		string	true	"Collection ID or favourites"
+//	@Param			recipeId	path		string	true	"Recipe ID"
+//	@Success		200	{object}	models.Collection
+//	@Failure		404	{object}	Problem
+//	@Router			/me/collections/{id}/recipes/{recipeId} [delete]
+func (handler *RecipesHandler) RemoveCollectionRecipeHandler(c *gin.Context) {
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	recipeID, err := primitive.ObjectIDFromHex(c.Param("recipeId"))
+	if err != nil {
+		notInCollection(c)
+		return
+	}
+	err = handler.collections.RemoveRecipe(handler.ctx, collection.ID, recipeID)
+	if errors.Is(err, store.ErrNotFound) {
+		notInCollection(c)
+		return
+	}
+	handler.respondCollection(c, collection.ID, err)
+}
+
+// ReorderCollectionHandler godoc
+//
+//	@Summary		Reorder my collection
+//	@Description	put the recipes of a collection in a new order. The order must list every recipe of the collection exactly once.
+//	@Tags			collections
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string			true	"Collection ID or favourites"
+//	@Param			order	body		CollectionOrder	true	"Recipe IDs in the new order"
+//	@Success		200		{object}	models.Collection
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Failure		409		{object}	Problem
+//	@Router			/me/collections/{id}/recipes [put]
+func (handler *RecipesHandler) ReorderCollectionHandler(c *gin.Context) {
+	var input CollectionOrder
+	if !bindJSON(c, &input) {
+		return
+	}
+	collection, ok := handler.findOwnCollection(c)
+	if !ok {
+		return
+	}
+	if !samePermutation(collection.RecipeIDs, input.RecipeIDs) {
+		validationFailed(c, "recipeIds", "must list every recipe of the collection exactly once")
+		return
+	}
+	err := handler.collections.ReorderRecipes(handler.ctx, collection.ID, collection.RecipeIDs, input.RecipeIDs)
+	if errors.Is(err, store.ErrConflict) {
+		problem(c, http.StatusConflict, CodeConcurrentUpdate, "The collection was modified concurrently, retry with its current recipes")
+		return
+	}
+	handler.respondCollection(c, collection.ID, err)
+}
+
+// findOwnCollection looks up the collection named by the id path
+// parameter, writing a 404 unless it belongs to the caller.
+func (handler *RecipesHandler) findOwnCollection(c *gin.Context) (models.Collection, bool) {
+	owner := currentUser(c).Username
+	if c.Param("id") == favouritesID {
+		collection, err := handler.collections.Favourites(handler.ctx, owner)
+		if err != nil {
+			internalError(c, err)
+			return collection, false
+		}
+		return collection, true
+	}
+
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		collectionNotFound(c)
+		return models.Collection{}, false
+	}
+	collection, err := handler.collections.Get(handler.ctx, objectId)
+	// Collections of other users are not revealed to exist.
+	if errors.Is(err, store.ErrNotFound) || err == nil && collection.Owner != owner {
+		collectionNotFound(c)
+		return collection, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return collection, false
+	}
+	return collection, true
+}
+
+// respondCollection answers a change to a collection with its new state,
+// or with the error of the change.
+func (handler *RecipesHandler) respondCollection(c *gin.Context, id primitive.ObjectID, err error) {
+	if err == nil {
+		var collection models.Collection
+		collection, err = handler.collections.Get(handler.ctx, id)
+		if err == nil {
+			c.JSON(http.StatusOK, collection)
+			return
+		}
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		collectionNotFound(c)
+		return
+	}
+	internalError(c, err)
+}
+
+// samePermutation reports whether order holds exactly the IDs of current.
+func samePermutation(current, order []primitive.ObjectID) bool {
+	if len(current) != len(order) {
+		return false
+	}
+	seen := make(map[primitive.ObjectID]bool, len(current))
+	for _, id := range current {
+		seen[id] = true
+	}
+	for _, id := range order {
+		if !seen[id] {
+			return false
+		}
+		delete(seen, id)
+	}
+	return true
+}
+
+// collectionNotFound aborts the request with a 404 for the collection.
+func collectionNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeCollectionNotFound, "Collection not found")
+}
+
+// notInCollection aborts the request with a 404 for a recipe missing from
+// the collection.
+func notInCollection(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeRecipeNotInCollection, "The recipe is not in the collection")
+}
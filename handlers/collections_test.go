@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"testing"
+)
+
+func TestCreateCollectionLimit(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < maxCollectionsPerUser; i++ {
+		rec := s.do(http.MethodPost, "/me/collections", "alice", fmt.Sprintf(`{"name":"List %d"}`, i))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("collection %d: status %d: %s", i, rec.Code, rec.Body)
+		}
+	}
+	rec := s.do(http.MethodPost, "/me/collections", "alice", `{"name":"One too many"}`)
+	if rec.Code != http.StatusConflict || problemCode(t, rec) != CodeCollectionLimit {
+		t.Errorf("over the limit: status %d: %s", rec.Code, rec.Body)
+	}
+	// The limit is per user.
+	if rec := s.do(http.MethodPost, "/me/collections", "bob", `{"name":"Mine"}`); rec.Code != http.StatusCreated {
+		t.Errorf("other user: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestAddCollectionRecipe(t *testing.T) {
+	s := newTestServer(t)
+	rec := s.do(http.MethodPost, "/me/collections", "alice", `{"name":"Breakfast"}`)
+	var collection models.Collection
+	decode(t, rec, &collection)
+	path := "/me/collections/" + collection.ID.Hex() + "/recipes"
+
+	omelette := s.create("alice", "Omelette")
+	draft := s.create("bob", "Secret")
+	trashed := s.create("alice", "Gone")
+	if rec := s.do(http.MethodDelete, "/recipes/"+trashed.ID.Hex(), "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("deleting: status %d: %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"own draft", `{"recipeId":"` + omelette.ID.Hex() + `"}`, http.StatusOK, ""},
+		{"already added", `{"recipeId":"` + omelette.ID.Hex() + `"}`, http.StatusConflict, CodeRecipeInCollection},
+		{"draft of another author", `{"recipeId":"` + draft.ID.Hex() + `"}`, http.StatusNotFound, CodeRecipeNotFound},
+		{"trashed recipe", `{"recipeId":"` + trashed.ID.Hex() + `"}`, http.StatusNotFound, CodeRecipeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, path, "alice", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" && problemCode(t, rec) != tt.wantCode {
+				t.Errorf("code = %s, want %s", problemCode(t, rec), tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"recipes-api/models"
+	"strings"
+)
+
+// recipeETag returns a strong entity tag derived from the JSON
+// representation of the recipe.
+func recipeETag(recipe models.Recipe) string {
+	data, _ := json.Marshal(recipe)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// variantETag derives the entity tag of a view of a recipe, such as one
+// scaled to other servings, from the tag of the stored recipe.
+func variantETag(etag string, variant string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + variant + `"`
+}
+
+// ifMatch reports whether etag is listed in an If-Match header value.
+// If-Match uses the strong comparison of RFC 9110: weak tags never match.
+func ifMatch(header, etag string) bool {
+	return etagListed(header, etag, false)
+}
+
+// ifNoneMatch reports whether etag is listed in an If-None-Match header
+// value, which uses the weak comparison: W/ prefixes are ignored.
+func ifNoneMatch(header, etag string) bool {
+	return etagListed(header, etag, true)
+}
+
+func etagListed(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestETagComparison(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header      string
+		ifMatch     bool
+		ifNoneMatch bool
+	}{
+		{`"abc"`, true, true},
+		{`W/"abc"`, false, true},
+		{`"xyz", "abc"`, true, true},
+		{`"xyz"`, false, false},
+		{`*`, true, true},
+	}
+	for _, tt := range tests {
+		if got := ifMatch(tt.header, etag); got != tt.ifMatch {
+			t.Errorf("ifMatch(%s) = %v, want %v", tt.header, got, tt.ifMatch)
+		}
+		if got := ifNoneMatch(tt.header, etag); got != tt.ifNoneMatch {
+			t.Errorf("ifNoneMatch(%s) = %v, want %v", tt.header, got, tt.ifNoneMatch)
+		}
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Stew")
+	path := "/recipes/" + recipe.ID.Hex()
+
+	etag := s.do(http.MethodGet, path, "alice", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET sent no ETag")
+	}
+	if rec := s.do(http.MethodGet, path, "alice", "", "If-None-Match", "W/"+etag); rec.Code != http.StatusNotModified {
+		t.Errorf("GET with weak If-None-Match: status %d, want 304", rec.Code)
+	}
+
+	update := `{"name":"Beef stew","ingredients":["1 kg beef"]}`
+	tests := []struct {
+		name    string
+		method  string
+		ifMatch string
+		status  int
+	}{
+		{"weak tag", http.MethodPut, "W/" + etag, http.StatusPreconditionFailed},
+		{"current tag", http.MethodPut, etag, http.StatusOK},
+		// A second writer holding the same tag must not overwrite the
+		// first one's change.
+		{"same tag again", http.MethodPut, etag, http.StatusPreconditionFailed},
+		{"delete with stale tag", http.MethodDelete, etag, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		body := update
+		if tt.method == http.MethodDelete {
+			body = ""
+		}
+		rec := s.do(tt.method, path, "alice", body, "If-Match", tt.ifMatch)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+
+	etag = s.do(http.MethodGet, path, "alice", "").Header().Get("ETag")
+	if rec := s.do(http.MethodDelete, path, "alice", "", "If-Match", etag); rec.Code != http.StatusOK {
+		t.Errorf("delete with current tag: status %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -2,67 +2,97 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"recipes-api/cache"
 	"recipes-api/models"
+	"recipes-api/store"
+	"recipes-api/units"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// maxServings bounds the servings a recipe can be scaled to.
+	maxServings = 1000
+	// writeAttempts bounds how often a write is retried when the recipe
+	// changes between reading and writing it.
+	writeAttempts = 5
 )
 
 type RecipesHandler struct {
-	collection  *mongo.Collection
+	store       store.RecipeStore
+	revisions   store.RevisionStore
+	reviews     store.ReviewStore
+	collections store.CollectionStore
+	mealPlans   store.MealPlanStore
+	images      ImageSettings
 	ctx         context.Context
-	redisClient *redis.Client
+	cache       cache.Cache
+	fetcher     *cache.Fetcher
+	ttls        CacheTTLs
 }
 
-func NewRecipesHandler(ctx context.Context, collection *mongo.
-	Collection, redisClient *redis.Client) *RecipesHandler {
+// NewRecipesHandler wires the handler to the recipe, revision, review,
+// collection and meal plan stores, the image storage and the cache in
+// front of them.
+func NewRecipesHandler(ctx context.Context, recipeStore store.RecipeStore, revisions store.RevisionStore,
+	reviews store.ReviewStore, collections store.CollectionStore, mealPlans store.MealPlanStore,
+	images ImageSettings, responseCache cache.Cache, ttls CacheTTLs) *RecipesHandler {
 	return &RecipesHandler{
-		collection:  collection,
+		store:       recipeStore,
+		revisions:   revisions,
+		reviews:     reviews,
+		collections: collections,
+		mealPlans:   mealPlans,
+		images:      images,
 		ctx:         ctx,
-		redisClient: redisClient,
+		cache:       responseCache,
+		fetcher:     cache.NewFetcher(responseCache, ttls.Stale),
+		ttls:        ttls,
 	}
 }
 
 // NewRecipeHandler godoc
 //
 //	@Summary		Add recipe
-//	@Description	add by json recipe
+//	@Description	add by json recipe. The recipe starts out as a draft, seen only by its author and editors, until it is published through POST /recipes/{id}/status.
 //	@Tags			recipes
 //	@Accept			json
 //	@Produce		json
 //	@Param			recipe	body		models.Recipe		true	"Add recipe"
 //	@Success		200		{object}	models.Recipe
+//	@Failure		400		{object}	Problem
 //	@Router			/recipes [post]
 func (handler *RecipesHandler) CreateRecipeHandler(c *gin.Context) {
 
 	var recipe models.Recipe
-	if err := c.ShouldBindJSON(&recipe); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error()})
+	if !bindJSON(c, &recipe) {
 		return
 	}
+	recipe.NormalizeIngredients()
+	recipe.NormalizeMetadata()
 	recipe.ID = primitive.NewObjectID()
-	recipe.PublishedAt = time.Now()
+	recipe.PublishedAt = time.Time{}
+	recipe.Author = currentUser(c).Username
+	recipe.Status = models.StatusDraft
+	recipe.PublishAt = nil
+	recipe.Rating = models.Rating{}
 
-	_, err := handler.collection.InsertOne(handler.ctx, recipe)
+	err := handler.store.Create(handler.ctx, &recipe)
 
 	if err != nil {
-
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while inserting a new recipe"})
+		internalError(c, err)
 		return
 	}
 
-	log.Println("Remove data from Redis")
-	handler.redisClient.Del("recipes")
+	handler.invalidateCreated()
+	handler.recordRevision(handler.ctx, recipe, recipe.Author, models.RevisionCreate, 0)
 
 	c.JSON(http.StatusOK, recipe)
 }
@@ -70,143 +100,387 @@ func (handler *RecipesHandler) CreateRecipeHandler(c *gin.Context) {
 // ListRecipesHandler godoc
 //
 //	@Summary		List recipes
-//	@Description	get recipes
+//	@Description	get recipes one page at a time. The next page token is returned in the X-Next-Cursor header. Only published recipes are listed, besides the caller's own; editors see every recipe.
 //	@Tags			recipes
 //	@Accept			json
 //	@Produce		json
+//	@Param			limit	query		int		false	"Page size (1-100, default 20)"
+//	@Param			sort	query		string	false	"id, publishedAt, name or rating; prefix with - for descending"
+//	@Param			cursor	query		string	false	"Continuation token from X-Next-Cursor"
+//	@Param			fields	query		string	false	"Comma separated list of fields to return"
+//	@Param			units	query		string	false	"Convert quantities and temperatures to metric or us"
 //	@Success		200	{array}		[]models.Recipe
+//	@Failure		400	{object}	Problem
 //	@Router			/recipes [get]
 func (handler *RecipesHandler) ListRecipesHandler(c *gin.Context) {
-	val, err := handler.redisClient.Get("recipes").Result()
-	if err == redis.Nil {
-		log.Printf("Request to MongoDB")
+	opts, err := parseListOptions(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+	system, err := parseUnits(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
 
-		cur, err := handler.collection.Find(handler.ctx, bson.M{})
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	var page listPage
+	err = handler.fetch(listCacheKey(opts, system), handler.ttls.List, &page, func() (interface{}, []string, error) {
+		log.Printf("Request to store")
+		return handler.loadPage(opts, system)
+	})
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	writePage(c, page)
+}
+
+// loadPage reads one page from the store, fetching one extra recipe to
+// find out whether another page follows, and converts it to system unless
+// that is empty. It also returns the cache tags of the page.
+func (handler *RecipesHandler) loadPage(opts store.ListOptions, system units.System) (listPage, []string, error) {
+	query := opts
+	query.Limit = opts.Limit + 1
+	recipes, err := handler.store.List(handler.ctx, query)
+	if err != nil {
+		return listPage{}, nil, err
+	}
+	if system != "" {
+		for i := range recipes {
+			recipes[i] = recipes[i].ConvertUnits(system)
 		}
-		defer cur.Close(handler.ctx)
-		recipes := make([]models.Recipe, 0)
-		for cur.Next(handler.ctx) {
-			var recipe models.Recipe
-			cur.Decode(&recipe)
-			recipes = append(recipes, recipe)
+	}
+	page, err := renderPage(recipes, opts)
+	if err != nil {
+		return page, nil, err
+	}
+
+	tags := []string{tagLists, listSortTag(opts.Sort.Field)}
+	for _, recipe := range recipes {
+		tags = append(tags, recipeTag(recipe.ID))
+	}
+	return page, tags, nil
+}
+
+// GetRecipeHandler godoc
+//
+//	@Summary		Get recipe
+//	@Description	get recipe by ID with its ingredients in structured form. With servings, ingredient quantities are scaled to that many portions. Honours If-None-Match. Recipes that are not published are only found by their author and editors.
+//	@Tags			recipes
+//	@Produce		json
+//	@Param			id			path		string	true	"Recipe ID"
+//	@Param			servings	query		int		false	"Scale the ingredients to this many servings"
+//	@Param			units		query		string	false	"Convert quantities and temperatures to metric or us"
+//	@Success		200	{object}	models.Recipe
+//	@Success		304
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id} [get]
+func (handler *RecipesHandler) GetRecipeHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+
+	system, err := parseUnits(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+
+	servings := 0
+	if value := c.Query("servings"); value != "" {
+		servings, err = strconv.Atoi(value)
+		if err != nil || servings < 1 || servings > maxServings {
+			problem(c, http.StatusBadRequest, CodeInvalidParameter, fmt.Sprintf("servings must be between 1 and %d", maxServings))
+			return
 		}
-		data, _ := json.Marshal(recipes)
-		handler.redisClient.Set("recipes", string(data), 0)
-		c.JSON(http.StatusOK, recipes)
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	var recipe models.Recipe
+	err = handler.fetch(recipeCacheKey(objectId), handler.ttls.Recipe, &recipe, func() (interface{}, []string, error) {
+		recipe, err := handler.store.Get(handler.ctx, objectId)
+		return recipe, []string{recipeTag(objectId)}, err
+	})
+	if errors.Is(err, store.ErrNotFound) || err == nil && !recipe.VisibleTo(currentUser(c)) {
+		recipeNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
 		return
+	}
+
+	// The entity tag identifies the stored recipe, so that it can be sent
+	// back in If-Match; a scaled view gets a tag of its own.
+	etag := recipeETag(recipe)
+	if servings != 0 {
+		if recipe.Servings == 0 {
+			problem(c, http.StatusBadRequest, CodeRecipeNotScalable, "Recipe does not state its servings and cannot be scaled")
+			return
+		}
+		etag = variantETag(etag, strconv.Itoa(servings))
+		recipe = recipe.Scale(servings)
 	} else {
-		log.Printf("Request to Redis")
-		recipes := make([]models.Recipe, 0)
-		json.Unmarshal([]byte(val), &recipes)
-		c.JSON(http.StatusOK, recipes)
+		recipe = recipe.WithIngredientDetails()
+	}
+	if system != "" {
+		etag = variantETag(etag, string(system))
+		recipe = recipe.ConvertUnits(system)
+	}
+
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match != "" && ifNoneMatch(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
 	}
+
+	c.JSON(http.StatusOK, recipe)
+}
+
+// authorizeWrite checks that the caller may modify the recipe identified by
+// id: only its author, editors and admins may, and the If-Match
+// precondition must hold. Recipes the caller may not see are reported as
+// not found. It returns the current recipe, or writes the
+// error response and returns false when the request must not proceed.
+func (handler *RecipesHandler) authorizeWrite(c *gin.Context, id primitive.ObjectID) (models.Recipe, bool) {
+	current, err := handler.store.Get(handler.ctx, id)
+	if errors.Is(err, store.ErrNotFound) {
+		recipeNotFound(c)
+		return current, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return current, false
+	}
+	if !current.VisibleTo(currentUser(c)) {
+		recipeNotFound(c)
+		return current, false
+	}
+
+	if !authorizeOwner(c, current) {
+		return current, false
+	}
+
+	if match := c.GetHeader("If-Match"); match != "" {
+		etag := recipeETag(current)
+		if !ifMatch(match, etag) {
+			c.Header("ETag", etag)
+			problem(c, http.StatusPreconditionFailed, CodePreconditionFailed, "Recipe has been modified")
+			return current, false
+		}
+	}
+	return current, true
+}
+
+// writeIfUnchanged authorizes a write like authorizeWrite and calls write
+// with the current recipe, which must apply it as a conditional write
+// failing with store.ErrConflict when the recipe has changed since it was
+// read, and return the recipe as written. A client that named the version
+// it changes through If-Match gets a 412 then; otherwise the write is
+// retried against the new version. A *patchError from write is reported
+// with its own status. It returns the recipe as written, or writes the
+// error response and returns false.
+func (handler *RecipesHandler) writeIfUnchanged(c *gin.Context, id primitive.ObjectID,
+	write func(current models.Recipe) (models.Recipe, error)) (models.Recipe, bool) {
+	for attempt := 0; attempt < writeAttempts; attempt++ {
+		current, ok := handler.authorizeWrite(c, id)
+		if !ok {
+			return current, false
+		}
+		written, err := write(current)
+		if errors.Is(err, store.ErrConflict) {
+			if c.GetHeader("If-Match") != "" {
+				problem(c, http.StatusPreconditionFailed, CodePreconditionFailed, "Recipe has been modified")
+				return current, false
+			}
+			continue
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			recipeNotFound(c)
+			return current, false
+		}
+		var invalid *patchError
+		if errors.As(err, &invalid) {
+			invalid.write(c)
+			return current, false
+		}
+		if err != nil {
+			internalError(c, err)
+			return current, false
+		}
+		return written, true
+	}
+	problem(c, http.StatusConflict, CodeConcurrentUpdate, "Recipe is being modified concurrently, try again")
+	return models.Recipe{}, false
+}
+
+// authorizeOwner checks that the caller is the author of the recipe, an
+// editor or an admin, writing a 403 otherwise.
+func authorizeOwner(c *gin.Context, recipe models.Recipe) bool {
+	user := currentUser(c)
+	owner := recipe.Author != "" && recipe.Author == user.Username
+	if !owner && !user.Can(models.RoleEditor) {
+		forbidden(c, CodeNotRecipeOwner, "Only the author of a recipe or an editor may modify it")
+		return false
+	}
+	return true
+}
+
+// editRecipe returns current with the editable fields of edited. A
+// published recipe changed by anyone but an editor goes back to review,
+// as only editors may publish.
+func editRecipe(c *gin.Context, current, edited models.Recipe) models.Recipe {
+	updated := current
+	updated.SetEditable(edited)
+	if updated.Published() && !currentUser(c).Can(models.RoleEditor) {
+		updated.Status = models.StatusInReview
+	}
+	return updated
 }
 
 // UpdateRecipesHandler godoc
 //
 //	@Summary		Update recipe
-//	@Description	Update by json recipe
+//	@Description	Update by json recipe. A published recipe changed by anyone but an editor goes back to review.
 //	@Tags			recipes
 //	@Accept			json
 //	@Produce		json
 //	@Param			id		path		string					true	"Recipe ID"
 //	@Param			recipe	body		models.Recipe				true	"Update recipe"
+//	@Param			If-Match	header	string	false	"ETag of the recipe being replaced"
 //	@Success		200		{object}	models.Recipe
+//	@Failure		400		{object}	Problem
+//	@Failure		403		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Failure		412		{object}	Problem
 //	@Router			/recipes/{id} [put]
 func (handler *RecipesHandler) UpdateRecipesHandler(c *gin.Context) {
-	id := c.Param("id")
-	var recipe models.Recipe
-	if err := c.ShouldBindJSON(&recipe); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error()})
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
 		return
 	}
 
-	objectId, _ := primitive.ObjectIDFromHex(id)
-
-	bsonD := bson.D{
-		{Key: "name", Value: recipe.Name},
-		{Key: "instructions", Value: recipe.Instructions},
-		{Key: "ingredients", Value: recipe.Ingredients},
-		{Key: "tags", Value: recipe.Tags},
+	var recipe models.Recipe
+	if !bindJSON(c, &recipe) {
+		return
 	}
+	recipe.NormalizeIngredients()
+	recipe.NormalizeMetadata()
 
-	_, err := handler.collection.UpdateOne(handler.ctx, bson.M{
-		"_id": objectId,
-	}, bson.D{{Key: "$set", Value: bsonD}})
-
-	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var status string
+	updated, ok := handler.writeIfUnchanged(c, objectId, func(current models.Recipe) (models.Recipe, error) {
+		status = current.Status
+		updated := editRecipe(c, current, recipe)
+		return updated, handler.store.UpdateIfUnchanged(handler.ctx, objectId, current, updated)
+	})
+	if !ok {
 		return
 	}
 
+	handler.invalidateEdit(objectId, status, updated.Status)
+	handler.recordRevision(handler.ctx, updated, currentUser(c).Username, models.RevisionUpdate, 0)
+	c.Header("ETag", recipeETag(updated))
+
 	c.JSON(http.StatusOK, gin.H{"message": "Recipe has been updated"})
 }
 
 // DeleteRecipeHandler godoc
 //
 //	@Summary		Delete recipe
-//	@Description	Delete by recipe ID
+//	@Description	Move a recipe to the trash. It can be restored until it is purged. The recipe is taken out of every collection and stays out when restored.
 //	@Tags			recipes
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		string	true	"Recipe ID"	string
+//	@Param			If-Match	header	string	false	"ETag of the recipe being deleted"
 //	@Success		200	{object}	models.Recipe
-//	@Failure		404	{object}	string
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		412	{object}	Problem
 //
 // @Router			/recipes/{id} [delete]
 func (handler *RecipesHandler) DeleteRecipeHandler(c *gin.Context) {
-	id := c.Param("id")
-
-	objectId, _ := primitive.ObjectIDFromHex(id)
-
-	_, err := handler.collection.DeleteOne(handler.ctx, bson.M{"_id": objectId})
-
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		recipeNotFound(c)
 		return
 	}
 
+	deleted, ok := handler.writeIfUnchanged(c, objectId, func(current models.Recipe) (models.Recipe, error) {
+		deletedAt := time.Now()
+		err := handler.store.DeleteIfUnchanged(handler.ctx, objectId, current, deletedAt)
+		current.DeletedAt = &deletedAt
+		return current, err
+	})
+	if !ok {
+		return
+	}
+
+	handler.invalidateRecipe(objectId)
+	if err := handler.collections.RemoveRecipeEverywhere(handler.ctx, objectId); err != nil {
+		log.Printf("Removing deleted recipe %s from collections failed: %v", objectId.Hex(), err)
+	}
+	handler.recordRevision(handler.ctx, deleted, currentUser(c).Username, models.RevisionDelete, 0)
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Recipe has been deleted"})
+		"message": "Recipe has been moved to the trash"})
 }
 
 // SearchRecipesHandler godoc
 //
 //	@Summary		Search recipes
-//	@Description	Search recipes by tag
+//	@Description	Search recipes by free text, tags, ingredients, time, difficulty, cuisine, course and calories. Text matches are ranked by relevance. Only published recipes match, besides the caller's own; editors search every recipe. The total number of matches is returned in the X-Total-Count header.
 //	@Tags			recipes
 //	@Accept			json
 //	@Produce		json
-//	@Param			id	path		string	true	"Recipe ID"	string
-//	@Success		200	{object}	models.Recipe
-//	@Failure		404	{object}	string
+//	@Param			q					query		string	false	"Free text over name, ingredients and instructions"
+//	@Param			tag					query		string	false	"Tags to match, repeated or comma separated"
+//	@Param			match				query		string	false	"all (default) or any"
+//	@Param			excludeTag			query		string	false	"Tags to exclude"
+//	@Param			ingredient			query		string	false	"Ingredients that must appear"
+//	@Param			excludeIngredient	query		string	false	"Ingredients that must not appear"
+//	@Param			maxTotalTime		query		int		false	"Longest total time in minutes"
+//	@Param			maxPrepTime			query		int		false	"Longest preparation time in minutes"
+//	@Param			difficulty			query		string	false	"Difficulties to match: easy, medium, hard"
+//	@Param			cuisine				query		string	false	"Cuisines to match"
+//	@Param			course				query		string	false	"Courses to match"
+//	@Param			maxCalories			query		number	false	"Most calories per serving"
+//	@Param			limit				query		int		false	"Page size (1-100, default 20)"
+//	@Param			offset				query		int		false	"Number of matches to skip"
+//	@Success		200	{array}		models.Recipe
+//	@Failure		400	{object}	Problem
 //
 // @Router			/recipes/search [get]
 func (handler *RecipesHandler) SearchRecipesHandler(c *gin.Context) {
-	tag := c.Query("tag")
+	query, err := parseSearchQuery(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
 
-	cur, err := handler.collection.Find(handler.ctx, bson.M{
-		"tags": tag,
+	var result searchResult
+	err = handler.fetch(hashKey("search:", query), handler.ttls.Search, &result, func() (interface{}, []string, error) {
+		var loaded searchResult
+		var err error
+		loaded.Recipes, loaded.Total, err = handler.store.Search(handler.ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+		tags := []string{tagSearches}
+		for _, recipe := range loaded.Recipes {
+			tags = append(tags, recipeTag(recipe.ID))
+		}
+		return loaded, tags, nil
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
-	defer cur.Close(handler.ctx)
-	listOfRecipes := make([]models.Recipe, 0)
-	for cur.Next(handler.ctx) {
-		var recipe models.Recipe
-		cur.Decode(&recipe)
-		listOfRecipes = append(listOfRecipes, recipe)
-	}
 
-	c.JSON(http.StatusOK, listOfRecipes)
+	writeOffsetHeaders(c, query.Offset, query.Limit, result.Total)
+	c.JSON(http.StatusOK, result.Recipes)
 }
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"recipes-api/cache"
+	"recipes-api/media"
+	"recipes-api/models"
+	"recipes-api/store"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testUsers are the accounts requests can be made as, by naming them in
+// the X-Test-User header.
+var testUsers = map[string]models.User{
+	"alice": {Username: "alice", Roles: []string{models.RoleAuthor}},
+	"bob":   {Username: "bob", Roles: []string{models.RoleAuthor}},
+	"erin":  {Username: "erin", Roles: []string{models.RoleEditor}},
+}
+
+// testServer serves the recipe routes from memory stores.
+type testServer struct {
+	t       *testing.T
+	handler *RecipesHandler
+	recipes *store.MemoryRecipeStore
+	router  *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	storage, err := media.NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipes := store.NewMemoryRecipeStore()
+	handler := NewRecipesHandler(context.Background(), recipes, store.NewMemoryRevisionStore(),
+		store.NewMemoryReviewStore(recipes), store.NewMemoryCollectionStore(), store.NewMemoryMealPlanStore(),
+		ImageSettings{Storage: storage, MaxSize: 1 << 20}, cache.NewMemoryCache(),
+		CacheTTLs{Recipe: time.Minute, List: time.Minute, Search: time.Minute})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user, ok := testUsers[c.GetHeader("X-Test-User")]; ok {
+			c.Set("username", user.Username)
+			c.Set("user", user)
+		}
+	})
+	router.GET("/recipes", handler.ListRecipesHandler)
+	router.GET("/recipes/search", handler.SearchRecipesHandler)
+	router.GET("/recipes/:id", handler.GetRecipeHandler)
+	router.POST("/recipes", handler.CreateRecipeHandler)
+	router.PUT("/recipes/:id", handler.UpdateRecipesHandler)
+	router.PATCH("/recipes/:id", handler.PatchRecipeHandler)
+	router.DELETE("/recipes/:id", handler.DeleteRecipeHandler)
+	router.POST("/recipes/:id/status", handler.ChangeStatusHandler)
+	router.POST("/recipes/:id/restore", handler.RestoreRecipeHandler)
+	router.GET("/recipes/:id/revisions", handler.ListRevisionsHandler)
+	router.GET("/recipes/:id/revisions/:rev", handler.GetRevisionHandler)
+	router.POST("/recipes/:id/revert/:rev", handler.RevertRecipeHandler)
+	router.POST("/recipes/:id/images", handler.UploadImageHandler)
+	router.DELETE("/recipes/:id/images/:image", handler.DeleteImageHandler)
+	router.GET("/images/:name", handler.ServeImageHandler)
+	router.GET("/recipes/:id/reviews", handler.ListReviewsHandler)
+	router.POST("/recipes/:id/reviews", handler.PostReviewHandler)
+	router.GET("/trash", handler.ListTrashHandler)
+	router.DELETE("/admin/trash/:id", handler.PurgeRecipeHandler)
+	router.POST("/me/collections", handler.CreateCollectionHandler)
+	router.POST("/me/collections/:id/recipes", handler.AddCollectionRecipeHandler)
+	return &testServer{t: t, handler: handler, recipes: recipes, router: router}
+}
+
+// do sends a request as user, who may be empty for an anonymous request.
+// headers are name, value pairs.
+func (s *testServer) do(method, path, user, body string, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// create adds a recipe as user and returns it.
+func (s *testServer) create(user, name string) models.Recipe {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/recipes", user, `{"name":"`+name+`","ingredients":["1 egg"]}`)
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("creating %s: status %d: %s", name, rec.Code, rec.Body)
+	}
+	var recipe models.Recipe
+	decode(s.t, rec, &recipe)
+	return recipe
+}
+
+// publish moves a recipe to published as an editor.
+func (s *testServer) publish(recipe models.Recipe) {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/recipes/"+recipe.ID.Hex()+"/status", "erin", `{"status":"published"}`)
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("publishing %s: status %d: %s", recipe.Name, rec.Code, rec.Body)
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, dst interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), dst); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+}
+
+// problemCode returns the code of a problem+json response.
+func problemCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var p Problem
+	decode(t, rec, &p)
+	return p.Code
+}
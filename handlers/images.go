@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"recipes-api/media"
+	"recipes-api/models"
+	"recipes-api/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// imagesPath is where ServeImageHandler is mounted.
+	imagesPath = "/images/"
+	// maxImagesPerRecipe bounds the images attached to one recipe.
+	maxImagesPerRecipe = 20
+	// multipartOverhead is allowed on top of the image size for the
+	// multipart framing around it.
+	multipartOverhead = 64 << 10
+)
+
+// ImageSettings configures recipe images.
+type ImageSettings struct {
+	Storage media.Storage
+	// MaxSize is the largest accepted upload in bytes.
+	MaxSize int64
+}
+
+// UploadImageHandler godoc
+//
+//	@Summary		Upload recipe image
+//	@Description	attach a JPEG, PNG or GIF image to a recipe. The type is detected from the content. Small and medium thumbnails are generated; the URLs of the image and its thumbnails are returned and included in the recipe.
+//	@Tags			images
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			id		path		string	true	"Recipe ID"
+//	@Param			image	formData	file	true	"Image file"
+//	@Success		201	{object}	models.Image
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		409	{object}	Problem
+//	@Failure		413	{object}	Problem
+//	@Failure		415	{object}	Problem
+//	@Router			/recipes/{id}/images [post]
+func (handler *RecipesHandler) UploadImageHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	current, ok := handler.authorizeWrite(c, objectId)
+	if !ok {
+		return
+	}
+	// Checked again when the image is added; this spares processing an
+	// upload that cannot be kept.
+	if len(current.Images) >= maxImagesPerRecipe {
+		imageLimitReached(c)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, handler.images.MaxSize+multipartOverhead)
+	header, err := c.FormFile("image")
+	if errors.Is(err, http.ErrMissingFile) {
+		validationFailed(c, "image", "is required")
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || err == nil && header.Size > handler.images.MaxSize {
+		problem(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+			fmt.Sprintf("The image exceeds %d bytes", handler.images.MaxSize))
+		return
+	}
+	if err != nil {
+		invalidBody(c, err)
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	imageID := primitive.NewObjectID()
+	processed, err := media.Process(c.Request.Context(), imageID.Hex(), data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		problem(c, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, err.Error())
+		return
+	case errors.Is(err, media.ErrInvalidImage):
+		problem(c, http.StatusBadRequest, CodeInvalidImage, err.Error())
+		return
+	case errors.Is(err, media.ErrTooManyPixels):
+		problem(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+		return
+	case err != nil:
+		internalError(c, err)
+		return
+	}
+
+	image := models.Image{
+		ID:          imageID,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Size:        len(data),
+		URL:         imagesPath + processed.Original.Name,
+		Thumbnails:  make(map[string]string, len(processed.Thumbnails)),
+		UploadedBy:  currentUser(c).Username,
+		UploadedAt:  time.Now(),
+	}
+	for size, thumbnail := range processed.Thumbnails {
+		image.Thumbnails[size] = imagesPath + thumbnail.Name
+	}
+	for _, file := range processed.Files() {
+		image.Files = append(image.Files, file.Name)
+		if err := handler.images.Storage.Save(handler.ctx, file.Name, file.Data); err != nil {
+			handler.deleteImageFiles(handler.ctx, image.Files)
+			internalError(c, err)
+			return
+		}
+	}
+
+	err = handler.store.AddImage(handler.ctx, objectId, image, maxImagesPerRecipe)
+	if err != nil {
+		handler.deleteImageFiles(handler.ctx, image.Files)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			recipeNotFound(c)
+		case errors.Is(err, store.ErrLimitReached):
+			imageLimitReached(c)
+		default:
+			internalError(c, err)
+		}
+		return
+	}
+	handler.invalidateRecipe(objectId)
+
+	c.Header("Location", image.URL)
+	c.JSON(http.StatusCreated, image)
+}
+
+// DeleteImageHandler godoc
+//
+//	@Summary		Delete recipe image
+//	@Description	remove an image and its thumbnails from a recipe
+//	@Tags			images
+//	@Produce		json
+//	@Param			id		path		string	true	"Recipe ID"
+//	@Param			image	path		string	true	"Image ID"
+//	@Success		200	{object}	string
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id}/images/{image} [delete]
+func (handler *RecipesHandler) DeleteImageHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	imageID, err := primitive.ObjectIDFromHex(c.Param("image"))
+	if err != nil {
+		imageNotFound(c)
+		return
+	}
+	if _, ok := handler.authorizeWrite(c, objectId); !ok {
+		return
+	}
+
+	image, err := handler.store.RemoveImage(handler.ctx, objectId, imageID)
+	if errors.Is(err, store.ErrNotFound) {
+		imageNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	handler.invalidateRecipe(objectId)
+	handler.deleteImageFiles(handler.ctx, image.Files)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Image has been deleted"})
+}
+
+// ServeImageHandler godoc
+//
+//	@Summary		Get image
+//	@Description	download an image or thumbnail by the file name in its URL. Images are visible to whoever can see their recipe; only those of published recipes may be cached publicly.
+//	@Tags			images
+//	@Produce		image/jpeg,image/png,image/gif
+//	@Param			name	path		string	true	"File name"
+//	@Success		200
+//	@Failure		404	{object}	Problem
+//	@Router			/images/{name} [get]
+func (handler *RecipesHandler) ServeImageHandler(c *gin.Context) {
+	name := c.Param("name")
+	if !media.ValidName(name) {
+		imageNotFound(c)
+		return
+	}
+	imageID, err := primitive.ObjectIDFromHex(media.ImageID(name))
+	if err != nil {
+		imageNotFound(c)
+		return
+	}
+	recipe, err := handler.store.GetByImage(c.Request.Context(), imageID)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !recipe.VisibleTo(currentUser(c)) {
+		imageNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	file, err := handler.images.Storage.Open(c.Request.Context(), name)
+	if errors.Is(err, media.ErrNotFound) {
+		imageNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	defer file.Close()
+
+	// A file name always refers to the same bytes, but the image of a
+	// recipe that is not published must not outlive its visibility in a
+	// shared cache.
+	cacheControl := "public, max-age=31536000, immutable"
+	if !recipe.Published() {
+		cacheControl = "private, no-store"
+	}
+	c.DataFromReader(http.StatusOK, -1, media.ContentType(name), file, map[string]string{
+		"Cache-Control":          cacheControl,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// imageLimitReached aborts the request with a 409 for a recipe that has
+// as many images as it may have.
+func imageLimitReached(c *gin.Context) {
+	problem(c, http.StatusConflict, CodeImageLimit,
+		fmt.Sprintf("A recipe can have at most %d images", maxImagesPerRecipe))
+}
+
+// imageNotFound aborts the request with a 404 for the image.
+func imageNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeImageNotFound, "Image not found")
+}
+
+// deleteImageFiles removes stored image files. Failures are logged: the
+// files are no longer referenced and only take up space.
+func (handler *RecipesHandler) deleteImageFiles(ctx context.Context, names []string) {
+	for _, name := range names {
+		if err := handler.images.Storage.Delete(ctx, name); err != nil {
+			log.Printf("Deleting image file %s failed: %v", name, err)
+		}
+	}
+}
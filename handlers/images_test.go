@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"recipes-api/models"
+)
+
+// upload posts data as the image of a recipe.
+func (s *testServer) upload(recipe models.Recipe, user string, data []byte) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if data != nil {
+		part, err := form.CreateFormFile("image", "photo")
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+	return s.do(http.MethodPost, "/recipes/"+recipe.ID.Hex()+"/images", user, body.String(),
+		"Content-Type", form.FormDataContentType())
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadImage(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Soup")
+	s.publish(recipe)
+
+	tests := []struct {
+		name   string
+		user   string
+		data   []byte
+		status int
+		code   string
+	}{
+		{"not the owner", "bob", pngImage(t, 8, 8), http.StatusForbidden, CodeNotRecipeOwner},
+		{"missing file", "alice", nil, http.StatusBadRequest, CodeValidationFailed},
+		{"not an image", "alice", []byte("plain text, not pixels"), http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+		{"truncated image", "alice", pngImage(t, 8, 8)[:40], http.StatusBadRequest, CodeInvalidImage},
+		{"too large", "alice", make([]byte, 2<<20), http.StatusRequestEntityTooLarge, CodePayloadTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.upload(recipe, tt.user, tt.data)
+			if rec.Code != tt.status || problemCode(t, rec) != tt.code {
+				t.Errorf("status = %d: %s, want %d %s", rec.Code, rec.Body, tt.status, tt.code)
+			}
+		})
+	}
+
+	rec := s.upload(recipe, "alice", pngImage(t, 64, 48))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+	}
+	var uploaded models.Image
+	decode(t, rec, &uploaded)
+	if uploaded.ContentType != "image/png" || uploaded.Width != 64 || uploaded.Height != 48 ||
+		uploaded.UploadedBy != "alice" || rec.Header().Get("Location") != uploaded.URL {
+		t.Errorf("image = %+v", uploaded)
+	}
+
+	rec = s.do(http.MethodGet, "/recipes/"+recipe.ID.Hex(), "", "")
+	var got models.Recipe
+	decode(t, rec, &got)
+	if len(got.Images) != 1 || got.Images[0].ID != uploaded.ID {
+		t.Fatalf("recipe images = %+v, want the upload", got.Images)
+	}
+
+	urls := []string{uploaded.URL}
+	for _, url := range uploaded.Thumbnails {
+		urls = append(urls, url)
+	}
+	for _, url := range urls {
+		rec := s.do(http.MethodGet, url, "", "")
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("GET %s: status %d, type %q", url, rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	imagePath := "/recipes/" + recipe.ID.Hex() + "/images/" + uploaded.ID.Hex()
+	if rec := s.do(http.MethodDelete, imagePath, "bob", ""); rec.Code != http.StatusForbidden {
+		t.Errorf("delete by another author: status %d", rec.Code)
+	}
+	if rec := s.do(http.MethodDelete, imagePath, "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	for _, url := range urls {
+		if rec := s.do(http.MethodGet, url, "", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s after delete: status %d, want 404", url, rec.Code)
+		}
+	}
+	if rec := s.do(http.MethodDelete, imagePath, "alice", ""); rec.Code != http.StatusNotFound || problemCode(t, rec) != CodeImageNotFound {
+		t.Errorf("second delete: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServeImageRejectsNames(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"..secret.png", "photo.png", strings.Repeat("0", 24) + ".png"} {
+		rec := s.do(http.MethodGet, "/images/"+name, "", "")
+		if rec.Code != http.StatusNotFound || problemCode(t, rec) != CodeImageNotFound {
+			t.Errorf("GET /images/%s: status %d: %s", name, rec.Code, rec.Body)
+		}
+	}
+}
+
+// Images are seen by whoever sees their recipe, and only those of
+// published recipes may be cached publicly.
+func TestServeImageVisibility(t *testing.T) {
+	s := newTestServer(t)
+	upload := func(name string) (models.Recipe, string) {
+		recipe := s.create("alice", name)
+		rec := s.upload(recipe, "alice", pngImage(t, 8, 8))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("upload to %s: status %d: %s", name, rec.Code, rec.Body)
+		}
+		var image models.Image
+		decode(t, rec, &image)
+		return recipe, image.URL
+	}
+	_, draft := upload("Draft")
+	published, public := upload("Published")
+	s.publish(published)
+	trashed, trash := upload("Trashed")
+	if rec := s.do(http.MethodDelete, "/recipes/"+trashed.ID.Hex(), "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		url    string
+		user   string
+		status int
+		cache  string
+	}{
+		{"published to anyone", public, "", http.StatusOK, "public, max-age=31536000, immutable"},
+		{"draft to anyone", draft, "", http.StatusNotFound, ""},
+		{"draft to another author", draft, "bob", http.StatusNotFound, ""},
+		{"draft to its author", draft, "alice", http.StatusOK, "private, no-store"},
+		{"draft to an editor", draft, "erin", http.StatusOK, "private, no-store"},
+		{"trashed to its author", trash, "alice", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodGet, tt.url, tt.user, "")
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && rec.Header().Get("Cache-Control") != tt.cache {
+				t.Errorf("Cache-Control = %q, want %q", rec.Header().Get("Cache-Control"), tt.cache)
+			}
+		})
+	}
+}
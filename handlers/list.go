@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"recipes-api/models"
+	"recipes-api/store"
+	"recipes-api/units"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// recipeFields are the JSON fields of models.Recipe that can be requested
+// through the fields= query parameter.
+var recipeFields = map[string]bool{
+	"id":           true,
+	"name":         true,
+	"tags":         true,
+	"ingredients":  true,
+	"instructions": true,
+	"publishedAt":  true,
+	"servings":     true,
+	"prepTime":     true,
+	"cookTime":     true,
+	"totalTime":    true,
+	"difficulty":   true,
+	"cuisine":      true,
+	"course":       true,
+	"nutrition":    true,
+	"images":       true,
+	"rating":       true,
+	"status":       true,
+	"publishAt":    true,
+}
+
+// listPage is a rendered page of recipes as stored in the cache.
+type listPage struct {
+	Items json.RawMessage `json:"items"`
+	Next  string          `json:"next,omitempty"`
+}
+
+// parseListOptions reads limit, sort, cursor and fields from the query
+// string and limits the listing to the recipes the caller may see.
+func parseListOptions(c *gin.Context) (store.ListOptions, error) {
+	opts := store.ListOptions{Limit: defaultPageSize, Visible: visibility(c)}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		opts.Limit = limit
+	}
+
+	sort, err := store.ParseSort(c.Query("sort"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Sort = sort
+
+	if token := c.Query("cursor"); token != "" {
+		cursor, err := store.DecodeCursor(token, sort)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = cursor
+	}
+
+	if value := c.Query("fields"); value != "" {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if !recipeFields[field] {
+				return opts, fmt.Errorf("unknown field %q", field)
+			}
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+	return opts, nil
+}
+
+// listCacheKey identifies a page by every option that shapes its content.
+func listCacheKey(opts store.ListOptions, system units.System) string {
+	cursor := ""
+	if opts.After != nil {
+		cursor = opts.After.Encode()
+	}
+	return hashKey("list:", []interface{}{opts.Sort.String(), opts.Limit, opts.Fields, cursor, system, opts.Visible})
+}
+
+// parseUnits reads the optional units= query parameter.
+func parseUnits(c *gin.Context) (units.System, error) {
+	value := c.Query("units")
+	if value == "" {
+		return "", nil
+	}
+	return units.ParseSystem(value)
+}
+
+// renderPage trims the look-ahead recipe fetched to detect a following
+// page, applies the field projection and computes the next cursor.
+func renderPage(recipes []models.Recipe, opts store.ListOptions) (listPage, error) {
+	var page listPage
+	if opts.Limit > 0 && len(recipes) > opts.Limit {
+		recipes = recipes[:opts.Limit]
+		page.Next = store.CursorAfter(recipes[len(recipes)-1], opts.Sort).Encode()
+	}
+
+	var items interface{} = recipes
+	if len(opts.Fields) > 0 {
+		projected, err := projectRecipes(recipes, opts.Fields)
+		if err != nil {
+			return page, err
+		}
+		items = projected
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return page, err
+	}
+	page.Items = data
+	return page, nil
+}
+
+// projectRecipes keeps only the requested fields, plus the ID.
+func projectRecipes(recipes []models.Recipe, fields []string) ([]map[string]interface{}, error) {
+	projected := make([]map[string]interface{}, 0, len(recipes))
+	for _, recipe := range recipes {
+		data, err := json.Marshal(recipe)
+		if err != nil {
+			return nil, err
+		}
+		var full map[string]interface{}
+		if err := json.Unmarshal(data, &full); err != nil {
+			return nil, err
+		}
+		item := map[string]interface{}{"id": full["id"]}
+		for _, field := range fields {
+			item[field] = full[field]
+		}
+		projected = append(projected, item)
+	}
+	return projected, nil
+}
+
+// writePage sends the page as a JSON array. The continuation token is
+// exposed in the X-Next-Cursor and Link headers so that the body stays
+// compatible with clients expecting a plain array.
+func writePage(c *gin.Context, page listPage) {
+	if page.Next != "" {
+		c.Header("X-Next-Cursor", page.Next)
+		query := c.Request.URL.Query()
+		query.Set("cursor", page.Next)
+		next := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", page.Items)
+}
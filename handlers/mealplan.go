@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/shopping"
+	"recipes-api/store"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// defaultPlanDays is the length of the date range when none is given:
+	// a week starting today.
+	defaultPlanDays = 7
+	// maxPlanDays bounds the date range of a listing or shopping list.
+	maxPlanDays = 366
+)
+
+// ShoppingList is the combined ingredients of the recipes planned in a
+// date range. MissingRecipes lists planned recipes that have since been
+// deleted or unpublished; their ingredients are not included.
+type ShoppingList struct {
+	From           string               `json:"from"`
+	To             string               `json:"to"`
+	Items          []shopping.Item      `json:"items"`
+	MissingRecipes []primitive.ObjectID `json:"missingRecipes,omitempty"`
+}
+
+// ListMealPlanHandler godoc
+//
+//	@Summary		List my meal plan
+//	@Description	list the planned meals of the caller in a date range, by date and meal slot. Without a range, the week starting today is listed.
+//	@Tags			meal plan
+//	@Produce		json
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD (default today)"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD (default six days after from)"
+//	@Success		200	{array}		models.MealPlanEntry
+//	@Failure		400	{object}	Problem
+//	@Router			/me/mealplan [get]
+func (handler *RecipesHandler) ListMealPlanHandler(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+	entries, err := handler.mealPlans.List(handler.ctx, currentUser(c).Username, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	slices.SortStableFunc(entries, func(a, b models.MealPlanEntry) int {
+		return cmp.Or(cmp.Compare(a.Date, b.Date),
+			cmp.Compare(slices.Index(models.MealSlots, a.Slot), slices.Index(models.MealSlots, b.Slot)))
+	})
+	c.JSON(http.StatusOK, entries)
+}
+
+// CreateMealPlanEntryHandler godoc
+//
+//	@Summary		Plan a meal
+//	@Description	plan a recipe for a meal slot on a day. Without servings, those of the recipe are planned.
+//	@Tags			meal plan
+//	@Accept			json
+//	@Produce		json
+//	@Param			entry	body		models.MealPlanEntry	true	"Date, slot, recipe and servings"
+//	@Success		201		{object}	models.MealPlanEntry
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Router			/me/mealplan [post]
+func (handler *RecipesHandler) CreateMealPlanEntryHandler(c *gin.Context) {
+	var input models.MealPlanEntry
+	if !bindJSON(c, &input) {
+		return
+	}
+	if !handler.recipeExists(c, input.RecipeID) {
+		return
+	}
+
+	now := time.Now()
+	entry := models.MealPlanEntry{
+		ID:        primitive.NewObjectID(),
+		Owner:     currentUser(c).Username,
+		Date:      input.Date,
+		Slot:      input.Slot,
+		RecipeID:  input.RecipeID,
+		Servings:  input.Servings,
+		Note:      strings.TrimSpace(input.Note),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := handler.mealPlans.Create(handler.ctx, entry); err != nil {
+		internalError(c, err)
+		return
+	}
+	c.Header("Location", "/me/mealplan/"+entry.ID.Hex())
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetMealPlanEntryHandler godoc
+//
+//	@Summary		Get planned meal
+//	@Description	get one entry of the caller's meal plan
+//	@Tags			meal plan
+//	@Produce		json
+//	@Param			id	path		string	true	"Entry ID"
+//	@Success		200	{object}	models.MealPlanEntry
+//	@Failure		404	{object}	Problem
+//	@Router			/me/mealplan/{id} [get]
+func (handler *RecipesHandler) GetMealPlanEntryHandler(c *gin.Context) {
+	entry, ok := handler.findOwnEntry(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// UpdateMealPlanEntryHandler godoc
+//
+//	@Summary		Update planned meal
+//	@Description	move a planned meal to another day or slot, or change its recipe, servings or note
+//	@Tags			meal plan
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Entry ID"
+//	@Param			entry	body		models.MealPlanEntry	true	"Date, slot, recipe and servings"
+//	@Success		200		{object}	models.MealPlanEntry
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Router			/me/mealplan/{id} [put]
+func (handler *RecipesHandler) UpdateMealPlanEntryHandler(c *gin.Context) {
+	var input models.MealPlanEntry
+	if !bindJSON(c, &input) {
+		return
+	}
+	entry, ok := handler.findOwnEntry(c)
+	if !ok {
+		return
+	}
+	if input.RecipeID != entry.RecipeID && !handler.recipeExists(c, input.RecipeID) {
+		return
+	}
+
+	entry.Date = input.Date
+	entry.Slot = input.Slot
+	entry.RecipeID = input.RecipeID
+	entry.Servings = input.Servings
+	entry.Note = strings.TrimSpace(input.Note)
+	entry.UpdatedAt = time.Now()
+	err := handler.mealPlans.Update(handler.ctx, entry)
+	if errors.Is(err, store.ErrNotFound) {
+		entryNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// DeleteMealPlanEntryHandler godoc
+//
+//	@Summary		Delete planned meal
+//	@Description	remove an entry from the caller's meal plan
+//	@Tags			meal plan
+//	@Produce		json
+//	@Param			id	path		string	true	"Entry ID"
+//	@Success		200	{object}	string
+//	@Failure		404	{object}	Problem
+//	@Router			/me/mealplan/{id} [delete]
+func (handler *RecipesHandler) DeleteMealPlanEntryHandler(c *gin.Context) {
+	entry, ok := handler.findOwnEntry(c)
+	if !ok {
+		return
+	}
+	err := handler.mealPlans.Delete(handler.ctx, entry.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		entryNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Meal plan entry has been deleted"})
+}
+
+// ShoppingListHandler godoc
+//
+//	@Summary		Shopping list
+//	@Description	combine the ingredients of the meals planned in a date range into a shopping list. Lines for the same item are merged and their quantities added up where the units are compatible; quantities are scaled to the planned servings when the recipe states its own.
+//	@Tags			meal plan
+//	@Produce		json
+//	@Param			from	query		string	false	"First day, YYYY-MM-DD (default today)"
+//	@Param			to		query		string	false	"Last day, YYYY-MM-DD (default six days after from)"
+//	@Param			units	query		string	false	"Convert quantities to metric or us before adding them up"
+//	@Success		200	{object}	ShoppingList
+//	@Failure		400	{object}	Problem
+//	@Router			/me/shopping-list [get]
+func (handler *RecipesHandler) ShoppingListHandler(c *gin.Context) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+	system, err := parseUnits(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+	entries, err := handler.mealPlans.List(handler.ctx, currentUser(c).Username, from, to)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	result := ShoppingList{From: from, To: to}
+	list := shopping.NewList(system)
+	recipes := make(map[primitive.ObjectID]*models.Recipe)
+	for _, entry := range entries {
+		recipe, seen := recipes[entry.RecipeID]
+		if !seen {
+			loaded, err := handler.store.Get(handler.ctx, entry.RecipeID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				internalError(c, err)
+				return
+			}
+			if err == nil && loaded.VisibleTo(currentUser(c)) {
+				recipe = &loaded
+			} else {
+				result.MissingRecipes = append(result.MissingRecipes, entry.RecipeID)
+			}
+			recipes[entry.RecipeID] = recipe
+		}
+		if recipe == nil {
+			continue
+		}
+
+		factor := 1.0
+		if entry.Servings > 0 && recipe.Servings > 0 {
+			factor = float64(entry.Servings) / float64(recipe.Servings)
+		}
+		list.Add(recipe.ID, recipe.Ingredients, factor)
+	}
+	result.Items = list.Items()
+	c.JSON(http.StatusOK, result)
+}
+
+// parseDateRange reads the from and to query parameters.
+func parseDateRange(c *gin.Context) (from, to string, err error) {
+	start := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("from"); value != "" {
+		if start, err = time.Parse(models.DateLayout, value); err != nil {
+			return "", "", errors.New("from must be a date formatted as YYYY-MM-DD")
+		}
+	}
+	end := start.AddDate(0, 0, defaultPlanDays-1)
+	if value := c.Query("to"); value != "" {
+		if end, err = time.Parse(models.DateLayout, value); err != nil {
+			return "", "", errors.New("to must be a date formatted as YYYY-MM-DD")
+		}
+	}
+	if end.Before(start) {
+		return "", "", errors.New("to must not be before from")
+	}
+	if end.Sub(start) >= maxPlanDays*24*time.Hour {
+		return "", "", fmt.Errorf("the range must not exceed %d days", maxPlanDays)
+	}
+	return start.Format(models.DateLayout), end.Format(models.DateLayout), nil
+}
+
+// findOwnEntry looks up the meal plan entry named by the id path
+// parameter, writing a 404 unless it belongs to the caller.
+func (handler *RecipesHandler) findOwnEntry(c *gin.Context) (models.MealPlanEntry, bool) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		entryNotFound(c)
+		return models.MealPlanEntry{}, false
+	}
+	entry, err := handler.mealPlans.Get(handler.ctx, objectId)
+	if errors.Is(err, store.ErrNotFound) || err == nil && entry.Owner != currentUser(c).Username {
+		entryNotFound(c)
+		return entry, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return entry, false
+	}
+	return entry, true
+}
+
+// recipeExists writes a 404 unless the recipe exists, is not in the trash
+// and may be seen by the caller.
+func (handler *RecipesHandler) recipeExists(c *gin.Context, id primitive.ObjectID) bool {
+	recipe, err := handler.store.Get(handler.ctx, id)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !recipe.VisibleTo(currentUser(c)) {
+		recipeNotFound(c)
+		return false
+	}
+	if err != nil {
+		internalError(c, err)
+		return false
+	}
+	return true
+}
+
+// entryNotFound aborts the request with a 404 for the meal plan entry.
+func entryNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeMealPlanEntryNotFound, "Meal plan entry not found")
+}
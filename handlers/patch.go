@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/patch"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+
+	// maxPatchSize bounds the body of a PATCH request.
+	maxPatchSize = 1 << 20
+)
+
+// patchableRecipe is the document patches are applied to: the fields of a
+// recipe its author may change.
+type patchableRecipe struct {
+	Name         string            `json:"name"`
+	Tags         []string          `json:"tags"`
+	Ingredients  []string          `json:"ingredients"`
+	Instructions []string          `json:"instructions"`
+	Servings     int               `json:"servings"`
+	PrepTime     int               `json:"prepTime"`
+	CookTime     int               `json:"cookTime"`
+	TotalTime    int               `json:"totalTime"`
+	Difficulty   string            `json:"difficulty"`
+	Cuisine      string            `json:"cuisine"`
+	Course       string            `json:"course"`
+	Nutrition    *models.Nutrition `json:"nutrition"`
+}
+
+// patchError is a patch that cannot be applied, with the status and code
+// to report.
+type patchError struct {
+	status  int
+	code    string
+	message string
+	fields  []FieldError
+}
+
+func (e *patchError) Error() string {
+	return e.message
+}
+
+// write aborts the request with the problem e describes.
+func (e *patchError) write(c *gin.Context) {
+	p := newProblem(c, e.status, e.code, e.message)
+	p.Errors = e.fields
+	writeProblem(c, p, nil)
+}
+
+// PatchRecipeHandler godoc
+//
+//	@Summary		Patch recipe
+//	@Description	Change part of a recipe with a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json) over name, tags, ingredients, instructions, servings, times, difficulty, cuisine, course and nutrition. The patch is applied to the current recipe as a whole or not at all. A published recipe changed by anyone but an editor goes back to review.
+//	@Tags			recipes
+//	@Accept			application/merge-patch+json,application/json-patch+json
+//	@Produce		json
+//	@Param			id			path		string	true	"Recipe ID"
+//	@Param			If-Match	header		string	false	"ETag of the recipe being patched"
+//	@Success		200	{object}	models.Recipe
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		409	{object}	Problem
+//	@Failure		412	{object}	Problem
+//	@Failure		415	{object}	Problem
+//	@Failure		422	{object}	Problem
+//	@Router			/recipes/{id} [patch]
+func (handler *RecipesHandler) PatchRecipeHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != mergePatchType && contentType != jsonPatchType {
+		c.Header("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		problem(c, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType,
+			fmt.Sprintf("Content-Type must be %s or %s", mergePatchType, jsonPatchType))
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchSize))
+	if err != nil {
+		invalidBody(c, err)
+		return
+	}
+
+	apply, err := decodePatch(contentType, body)
+	if err != nil {
+		problem(c, http.StatusBadRequest, CodeInvalidPatch, err.Error())
+		return
+	}
+
+	var status string
+	var changed bool
+	updated, ok := handler.writeIfUnchanged(c, objectId, func(current models.Recipe) (models.Recipe, error) {
+		status = current.Status
+		patched, err := patchRecipe(current, apply)
+		if err != nil {
+			return current, err
+		}
+		// A patch that changes nothing is not written.
+		if changed = len(models.DiffRecipes(current, patched)) > 0; !changed {
+			return current, nil
+		}
+		updated := editRecipe(c, current, patched)
+		return updated, handler.store.UpdateIfUnchanged(handler.ctx, objectId, current, updated)
+	})
+	if !ok {
+		return
+	}
+
+	if changed {
+		handler.invalidateEdit(objectId, status, updated.Status)
+		handler.recordRevision(handler.ctx, updated, currentUser(c).Username, models.RevisionUpdate, 0)
+	}
+	c.Header("ETag", recipeETag(updated))
+	c.JSON(http.StatusOK, updated)
+}
+
+// decodePatch parses the request body into a function applying it to a
+// decoded JSON document.
+func decodePatch(contentType string, body []byte) (func(interface{}) (interface{}, error), error) {
+	if contentType == mergePatchType {
+		var mergePatch interface{}
+		if err := json.Unmarshal(body, &mergePatch); err != nil {
+			return nil, fmt.Errorf("invalid merge patch: %v", err)
+		}
+		if _, ok := mergePatch.(map[string]interface{}); !ok {
+			return nil, errors.New("merge patch must be a JSON object")
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return patch.Merge(doc, mergePatch), nil
+		}, nil
+	}
+
+	ops, err := patch.DecodeJSONPatch(body)
+	if err != nil {
+		return nil, err
+	}
+	return func(doc interface{}) (interface{}, error) {
+		return patch.Apply(doc, ops)
+	}, nil
+}
+
+// patchRecipe applies a patch to the editable fields of recipe and
+// validates the result.
+func patchRecipe(recipe models.Recipe, apply func(interface{}) (interface{}, error)) (models.Recipe, error) {
+	data, err := json.Marshal(patchableRecipe{
+		Name:         recipe.Name,
+		Tags:         recipe.Tags,
+		Ingredients:  recipe.Ingredients,
+		Instructions: recipe.Instructions,
+		Servings:     recipe.Servings,
+		PrepTime:     recipe.PrepTime,
+		CookTime:     recipe.CookTime,
+		TotalTime:    recipe.TotalTime,
+		Difficulty:   recipe.Difficulty,
+		Cuisine:      recipe.Cuisine,
+		Course:       recipe.Course,
+		Nutrition:    recipe.Nutrition,
+	})
+	if err != nil {
+		return recipe, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return recipe, err
+	}
+
+	doc, err = apply(doc)
+	if errors.Is(err, patch.ErrTestFailed) {
+		return recipe, &patchError{status: http.StatusConflict, code: CodePatchTestFailed, message: err.Error()}
+	}
+	if err != nil {
+		return recipe, &patchError{status: http.StatusUnprocessableEntity, code: CodePatchNotApplicable, message: err.Error()}
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return recipe, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var patched patchableRecipe
+	if err := decoder.Decode(&patched); err != nil {
+		return recipe, &patchError{status: http.StatusUnprocessableEntity, code: CodePatchNotApplicable,
+			message: "patched recipe is invalid: " + err.Error()}
+	}
+
+	recipe.Name = patched.Name
+	recipe.Tags = patched.Tags
+	recipe.Ingredients = patched.Ingredients
+	recipe.Instructions = patched.Instructions
+	recipe.Servings = patched.Servings
+	// A total time derived from the other two is derived again unless the
+	// patch sets it.
+	if patched.TotalTime == recipe.TotalTime && recipe.TotalTime == recipe.PrepTime+recipe.CookTime {
+		patched.TotalTime = 0
+	}
+	recipe.PrepTime = patched.PrepTime
+	recipe.CookTime = patched.CookTime
+	recipe.TotalTime = patched.TotalTime
+	recipe.Difficulty = patched.Difficulty
+	recipe.Cuisine = patched.Cuisine
+	recipe.Course = patched.Course
+	recipe.Nutrition = patched.Nutrition
+	recipe.NormalizeMetadata()
+
+	// The result must pass the checks a PUT of the same recipe would.
+	var invalid validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(recipe); errors.As(err, &invalid) {
+		return recipe, &patchError{status: http.StatusUnprocessableEntity, code: CodeValidationFailed,
+			message: "patched recipe is invalid", fields: fieldErrors(invalid)}
+	} else if err != nil {
+		return recipe, err
+	}
+	return recipe, nil
+}
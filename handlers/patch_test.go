@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"testing"
+)
+
+func TestPatchRecipe(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Stew")
+	path := "/recipes/" + recipe.ID.Hex()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"wrong content type", "application/json", `{"name":"Soup"}`, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType},
+		{"merge patch", mergePatchType, `{"name":"Soup","tags":["winter"]}`, http.StatusOK, ""},
+		{"json patch", jsonPatchType, `[{"op":"add","path":"/tags/-","value":"easy"}]`, http.StatusOK, ""},
+		{"failed test", jsonPatchType, `[{"op":"test","path":"/name","value":"Stew"}]`, http.StatusConflict, CodePatchTestFailed},
+		{"missing path", jsonPatchType, `[{"op":"remove","path":"/nutrition/calories"}]`, http.StatusUnprocessableEntity, CodePatchNotApplicable},
+		{"read-only field", mergePatchType, `{"author":"bob"}`, http.StatusUnprocessableEntity, CodePatchNotApplicable},
+		{"invalid result", mergePatchType, `{"name":null}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"malformed patch", jsonPatchType, `{"op":"remove"}`, http.StatusBadRequest, CodeInvalidPatch},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodPatch, path, "alice", tt.body, "Content-Type", tt.contentType)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if tt.code != "" && problemCode(t, rec) != tt.code {
+			t.Errorf("%s: code %s, want %s", tt.name, problemCode(t, rec), tt.code)
+		}
+	}
+
+	var patched models.Recipe
+	decode(t, s.do(http.MethodGet, path, "alice", ""), &patched)
+	if patched.Name != "Soup" || len(patched.Tags) != 2 || patched.Author != "alice" {
+		t.Errorf("patched recipe = %+v, want Soup tagged winter and easy by alice", patched)
+	}
+	// Drafts are hidden from other authors; published recipes are read-only
+	// to them.
+	if rec := s.do(http.MethodPatch, path, "bob", `{"name":"Mine"}`, "Content-Type", mergePatchType); rec.Code != http.StatusNotFound {
+		t.Errorf("patch of a draft by another author: status %d, want 404", rec.Code)
+	}
+	s.publish(recipe)
+	if rec := s.do(http.MethodPatch, path, "bob", `{"name":"Mine"}`, "Content-Type", mergePatchType); rec.Code != http.StatusForbidden {
+		t.Errorf("patch by another author: status %d, want 403", rec.Code)
+	}
+}
+
+func TestPatchRecipeConditions(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Stew")
+	path := "/recipes/" + recipe.ID.Hex()
+	etag := s.do(http.MethodGet, path, "alice", "").Header().Get("ETag")
+
+	tests := []struct {
+		name      string
+		body      string
+		ifMatch   string
+		status    int
+		revisions int
+	}{
+		{"unchanged", `{"name":"Stew"}`, "", http.StatusOK, 1},
+		{"current version", `{"name":"Soup"}`, etag, http.StatusOK, 2},
+		{"stale version", `{"name":"Broth"}`, etag, http.StatusPreconditionFailed, 2},
+		{"no version", `{"name":"Broth"}`, "", http.StatusOK, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := []string{"Content-Type", mergePatchType}
+			if tt.ifMatch != "" {
+				headers = append(headers, "If-Match", tt.ifMatch)
+			}
+			rec := s.do(http.MethodPatch, path, "alice", tt.body, headers...)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			var revisions []models.Revision
+			decode(t, s.do(http.MethodGet, path+"/revisions", "alice", ""), &revisions)
+			if len(revisions) != tt.revisions {
+				t.Errorf("%d revisions, want %d", len(revisions), tt.revisions)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"recipes-api/models"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+const problemContentType = "application/problem+json"
+
+// Error codes are part of the API: clients branch on them, so they must not
+// change once published. The message accompanying a code may.
+const (
+	CodeInvalidBody           = "invalid_body"
+	CodeValidationFailed      = "validation_failed"
+	CodeInvalidParameter      = "invalid_parameter"
+	CodeRouteNotFound         = "route_not_found"
+	CodeRecipeNotFound        = "recipe_not_found"
+	CodeRevisionNotFound      = "revision_not_found"
+	CodeTrashNotFound         = "recipe_not_in_trash"
+	CodeUserNotFound          = "user_not_found"
+	CodeImageNotFound         = "image_not_found"
+	CodeInvalidImage          = "invalid_image"
+	CodeImageLimit            = "image_limit_reached"
+	CodeCollectionNotFound    = "collection_not_found"
+	CodeCollectionLimit       = "collection_limit_reached"
+	CodeFavouritesCollection  = "favourites_collection"
+	CodeRecipeInCollection    = "recipe_already_in_collection"
+	CodeRecipeNotInCollection = "recipe_not_in_collection"
+	CodeMealPlanEntryNotFound = "meal_plan_entry_not_found"
+	CodeRecipeNotScalable     = "recipe_not_scalable"
+	CodeUsernameTaken         = "username_taken"
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeAccountDisabled       = "account_disabled"
+	CodeNotAuthenticated      = "not_authenticated"
+	CodeCredentialRevoked     = "credential_revoked"
+	CodeTokenNotExpiring      = "token_not_expiring"
+	CodeInsufficientRole      = "insufficient_role"
+	CodeNotRecipeOwner        = "not_recipe_owner"
+	CodeOwnAccount            = "own_account"
+	CodePreconditionFailed    = "precondition_failed"
+	CodeConcurrentUpdate      = "concurrent_update"
+	CodeUnsupportedMediaType  = "unsupported_media_type"
+	CodePayloadTooLarge       = "payload_too_large"
+	CodeInvalidPatch          = "invalid_patch"
+	CodePatchNotApplicable    = "patch_not_applicable"
+	CodePatchTestFailed       = "patch_test_failed"
+	CodeInvalidTransition     = "invalid_status_transition"
+	CodeInternal              = "internal_error"
+)
+
+// Problem is an RFC 7807 problem details object. Type identifies the kind
+// of problem by its code; Error repeats the detail for clients written
+// against the earlier {"error": ...} bodies.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Error    string       `json:"error"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a request field that failed validation. Field is the JSON
+// path of the field, e.g. ingredients[2].
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func init() {
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterTagNameFunc(jsonFieldName)
+		models.RegisterValidations(engine)
+	}
+}
+
+// jsonFieldName names struct fields after their JSON key in validation
+// errors.
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// newProblem returns the problem with the given status and code.
+func newProblem(c *gin.Context, status int, code, detail string) Problem {
+	return Problem{
+		Type:     "urn:recipes-api:problem:" + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: c.Request.URL.Path,
+		Code:     code,
+		Error:    detail,
+	}
+}
+
+// writeProblem aborts the request with p. Members of extensions are added
+// to the problem object.
+func writeProblem(c *gin.Context, p Problem, extensions gin.H) {
+	c.Header("Content-Type", problemContentType)
+	if len(extensions) == 0 {
+		c.AbortWithStatusJSON(p.Status, p)
+		return
+	}
+	body := gin.H{}
+	data, _ := json.Marshal(p)
+	json.Unmarshal(data, &body)
+	for key, value := range extensions {
+		if _, taken := body[key]; !taken {
+			body[key] = value
+		}
+	}
+	c.AbortWithStatusJSON(p.Status, body)
+}
+
+// problem aborts the request with a problem of the given status and code.
+func problem(c *gin.Context, status int, code, detail string) {
+	writeProblem(c, newProblem(c, status, code, detail), nil)
+}
+
+// internalError logs err and aborts the request with a 500 that does not
+// reveal it: store errors can carry connection strings and query details.
+func internalError(c *gin.Context, err error) {
+	log.Printf("%s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	problem(c, http.StatusInternalServerError, CodeInternal, "An unexpected error occurred")
+}
+
+// recipeNotFound aborts the request with a 404 for the recipe.
+func recipeNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeRecipeNotFound, "Recipe not found")
+}
+
+// invalidParameter aborts the request with a 400 for a query or path
+// parameter that could not be parsed.
+func invalidParameter(c *gin.Context, err error) {
+	problem(c, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+}
+
+// forbidden aborts the request with a 403 and a machine readable code.
+func forbidden(c *gin.Context, code, message string) {
+	problem(c, http.StatusForbidden, code, message)
+}
+
+// bindJSON decodes and validates the JSON body into obj, aborting the
+// request with a 400 describing what is wrong when it cannot.
+func bindJSON(c *gin.Context, obj interface{}) bool {
+	err := c.ShouldBindJSON(obj)
+	if err == nil {
+		return true
+	}
+	invalidBody(c, err)
+	return false
+}
+
+// invalidBody aborts the request with a 400 for a body that failed to
+// decode or validate.
+func invalidBody(c *gin.Context, err error) {
+	var invalid validator.ValidationErrors
+	if errors.As(err, &invalid) {
+		p := newProblem(c, http.StatusBadRequest, CodeValidationFailed, "The request body is invalid")
+		p.Errors = fieldErrors(invalid)
+		writeProblem(c, p, nil)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		problem(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+			fmt.Sprintf("The request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	problem(c, http.StatusBadRequest, CodeInvalidBody, "The request body is malformed: "+err.Error())
+}
+
+// validationFailed aborts the request with a 400 for a single invalid
+// field.
+func validationFailed(c *gin.Context, field, message string) {
+	p := newProblem(c, http.StatusBadRequest, CodeValidationFailed, "The request body is invalid")
+	p.Errors = []FieldError{{Field: field, Message: message}}
+	writeProblem(c, p, nil)
+}
+
+// fieldErrors describes validation failures in terms of the JSON fields.
+func fieldErrors(invalid validator.ValidationErrors) []FieldError {
+	errs := make([]FieldError, len(invalid))
+	for i, fe := range invalid {
+		errs[i] = FieldError{Field: fieldPath(fe), Message: fieldMessage(fe)}
+	}
+	return errs
+}
+
+// fieldPath strips the name of the top level struct from the namespace of
+// a field error.
+func fieldPath(fe validator.FieldError) string {
+	namespace := fe.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	kind := fe.Kind()
+	unit := ""
+	switch kind {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "must not be blank"
+	case "required_without":
+		return fmt.Sprintf("is required unless %s is given", lowerFirst(fe.Param()))
+	case "min", "gte":
+		if kind == reflect.Slice && fe.Param() == "1" {
+			return "must not be empty"
+		}
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "datetime":
+		if fe.Param() == models.DateLayout {
+			return "must be a date formatted as YYYY-MM-DD"
+		}
+		return "must be a time formatted as " + fe.Param()
+	case "mintotaltime":
+		return "must be at least prepTime + cookTime"
+	}
+	return fmt.Sprintf("failed the %s check", fe.Tag())
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// NotFound answers requests that match no route.
+func NotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeRouteNotFound, "No such endpoint")
+}
+
+// Recovery turns panics in handlers into a 500 problem.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		internalError(c, fmt.Errorf("panic: %v", recovered))
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProblemResponses(t *testing.T) {
+	s := newTestServer(t)
+	s.router.NoRoute(NotFound)
+	recipe := s.create("alice", "Soup")
+	s.publish(recipe)
+	path := "/recipes/" + recipe.ID.Hex()
+
+	tests := []struct {
+		name, method, path, user, body string
+		status                         int
+		code                           string
+	}{
+		{"malformed JSON", http.MethodPost, "/recipes", "alice", `{"name":`, http.StatusBadRequest, CodeInvalidBody},
+		{"wrong JSON type", http.MethodPost, "/recipes", "alice", `{"name":42,"ingredients":["1 egg"]}`, http.StatusBadRequest, CodeInvalidBody},
+		{"failed validation", http.MethodPut, path, "alice", `{"name":""}`, http.StatusBadRequest, CodeValidationFailed},
+		{"invalid id", http.MethodGet, "/recipes/nope", "alice", "", http.StatusNotFound, CodeRecipeNotFound},
+		{"missing recipe", http.MethodGet, "/recipes/000000000000000000000000", "alice", "", http.StatusNotFound, CodeRecipeNotFound},
+		{"invalid parameter", http.MethodGet, "/recipes?limit=0", "alice", "", http.StatusBadRequest, CodeInvalidParameter},
+		{"not the owner", http.MethodDelete, path, "bob", "", http.StatusForbidden, CodeNotRecipeOwner},
+		{"unknown route", http.MethodGet, "/nowhere", "alice", "", http.StatusNotFound, CodeRouteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(tt.method, tt.path, tt.user, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, problemContentType) {
+				t.Errorf("Content-Type = %q, want %s", got, problemContentType)
+			}
+			instance, _, _ := strings.Cut(tt.path, "?")
+			var p Problem
+			decode(t, rec, &p)
+			if p.Code != tt.code || p.Status != tt.status || p.Type != "urn:recipes-api:problem:"+tt.code ||
+				p.Title != http.StatusText(tt.status) || p.Instance != instance ||
+				p.Detail == "" || p.Error != p.Detail {
+				t.Errorf("problem = %+v, want code %s", p, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidationErrorsListFields(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"missing name and ingredients", `{}`, []string{"name", "ingredients"}},
+		{"blank name", `{"name":"  ","ingredients":["1 egg"]}`, []string{"name"}},
+		{"blank list item", `{"name":"Soup","ingredients":["1 egg",""]}`, []string{"ingredients[1]"}},
+		{"empty ingredients", `{"name":"Soup","ingredients":[]}`, []string{"ingredients"}},
+		{"unknown difficulty", `{"name":"Soup","ingredients":["1 egg"],"difficulty":"extreme"}`, []string{"difficulty"}},
+		{"total time too short", `{"name":"Soup","ingredients":["1 egg"],"prepTime":20,"totalTime":10}`, []string{"totalTime"}},
+		{"too many servings", `{"name":"Soup","ingredients":["1 egg"],"servings":5000}`, []string{"servings"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/recipes", "alice", tt.body)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+			var p Problem
+			decode(t, rec, &p)
+			if p.Code != CodeValidationFailed {
+				t.Fatalf("code = %s, want %s", p.Code, CodeValidationFailed)
+			}
+			var fields []string
+			for _, fe := range p.Errors {
+				if fe.Message == "" {
+					t.Errorf("field %s has no message", fe.Field)
+				}
+				fields = append(fields, fe.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestRecoveryWritesProblem(t *testing.T) {
+	router := gin.New()
+	router.Use(Recovery())
+	router.GET("/panic", func(c *gin.Context) { panic("connection string leaked") })
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError || problemCode(t, rec) != CodeInternal {
+		t.Fatalf("status = %d: %s, want a 500 problem", rec.Code, rec.Body)
+	}
+	if strings.Contains(rec.Body.String(), "connection string") {
+		t.Errorf("problem reveals the panic: %s", rec.Body)
+	}
+}
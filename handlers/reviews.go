@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostReviewHandler godoc
+//
+//	@Summary		Review recipe
+//	@Description	rate a recipe from 1 to 5 stars with an optional text. Each user has one review per recipe; posting again replaces it. The average rating and review count of the recipe are updated accordingly.
+//	@Tags			reviews
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string			true	"Recipe ID"
+//	@Param			review	body		models.Review	true	"Rating and text"
+//	@Success		200		{object}	models.Review
+//	@Success		201		{object}	models.Review
+//	@Failure		400		{object}	Problem
+//	@Failure		404		{object}	Problem
+//	@Router			/recipes/{id}/reviews [post]
+func (handler *RecipesHandler) PostReviewHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	var review models.Review
+	if !bindJSON(c, &review) {
+		return
+	}
+	if !handler.recipeExists(c, objectId) {
+		return
+	}
+
+	now := time.Now()
+	review.ID = primitive.NewObjectID()
+	review.RecipeID = objectId
+	review.Author = currentUser(c).Username
+	review.CreatedAt = now
+	review.UpdatedAt = now
+	previous, err := handler.reviews.Put(handler.ctx, review)
+	// A failed Put may still have stored the review or changed the rating.
+	handler.invalidateRating(objectId)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if previous != nil {
+		status = http.StatusOK
+		review.ID = previous.ID
+		review.CreatedAt = previous.CreatedAt
+	}
+
+	c.JSON(status, review)
+}
+
+// ListReviewsHandler godoc
+//
+//	@Summary		List recipe reviews
+//	@Description	list the reviews of a recipe, most recently updated first. The total number of reviews is returned in the X-Total-Count header.
+//	@Tags			reviews
+//	@Produce		json
+//	@Param			id		path		string	true	"Recipe ID"
+//	@Param			limit	query		int		false	"Page size (1-100, default 20)"
+//	@Param			offset	query		int		false	"Number of reviews to skip"
+//	@Success		200	{array}		models.Review
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id}/reviews [get]
+func (handler *RecipesHandler) ListReviewsHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	limit, offset, err := parseOffsetPage(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+
+	if !handler.recipeExists(c, objectId) {
+		return
+	}
+	reviews, total, err := handler.reviews.List(handler.ctx, objectId, limit, offset)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	writeOffsetHeaders(c, offset, limit, total)
+	c.JSON(http.StatusOK, reviews)
+}
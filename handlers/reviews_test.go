@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"sync"
+	"testing"
+)
+
+func TestPostReview(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Omelette")
+	s.publish(recipe)
+	path := "/recipes/" + recipe.ID.Hex() + "/reviews"
+
+	tests := []struct {
+		name       string
+		user       string
+		body       string
+		wantStatus int
+		wantCount  int
+		wantAvg    float64
+	}{
+		{"first review", "bob", `{"rating":2}`, http.StatusCreated, 1, 2},
+		{"second reviewer", "erin", `{"rating":5}`, http.StatusCreated, 2, 3.5},
+		{"edit replaces", "bob", `{"rating":4,"text":"better"}`, http.StatusOK, 2, 4.5},
+		{"rating out of range", "bob", `{"rating":6}`, http.StatusBadRequest, 2, 4.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, path, tt.user, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			got, err := s.recipes.Get(s.handler.ctx, recipe.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Rating.Count != tt.wantCount || got.Rating.Average != tt.wantAvg {
+				t.Errorf("rating = %+v, want count %d and average %v", got.Rating, tt.wantCount, tt.wantAvg)
+			}
+		})
+	}
+}
+
+func TestPostReviewConcurrently(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Omelette")
+	s.publish(recipe)
+	path := "/recipes/" + recipe.ID.Hex() + "/reviews"
+
+	// Each user's first review races the others', and so does their edit.
+	users := []string{"alice", "bob", "erin"}
+	for _, body := range []string{`{"rating":1}`, `{"rating":3}`} {
+		var wg sync.WaitGroup
+		for _, user := range users {
+			wg.Add(1)
+			go func(user string) {
+				defer wg.Done()
+				if rec := s.do(http.MethodPost, path, user, body); rec.Code >= 300 {
+					t.Errorf("%s: status %d: %s", user, rec.Code, rec.Body)
+				}
+			}(user)
+		}
+		wg.Wait()
+	}
+
+	rec := s.do(http.MethodGet, "/recipes/"+recipe.ID.Hex(), "", "")
+	var got models.Recipe
+	decode(t, rec, &got)
+	if got.Rating.Count != len(users) || got.Rating.Average != 3 {
+		t.Errorf("rating = %+v, want %d reviews averaging 3", got.Rating, len(users))
+	}
+}
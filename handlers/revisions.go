@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/store"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// revisionAttempts bounds the retries when concurrent writers race for the
+// same revision number.
+const revisionAttempts = 5
+
+// recordRevision appends the state of a recipe after a change to its
+// history. The difference is taken to the previous revision, so recipes
+// created before history was kept start with a revision listing every
+// field; changes of status and schedule are listed besides the editable
+// fields. Failures are logged: the change itself has already been made.
+func (handler *RecipesHandler) recordRevision(ctx context.Context, recipe models.Recipe, author string,
+	action models.RevisionAction, revertedFrom int) {
+	recipe.IngredientDetails = nil
+	for attempt := 0; attempt < revisionAttempts; attempt++ {
+		var previous models.Recipe
+		number := 1
+		latest, err := handler.revisions.Latest(ctx, recipe.ID)
+		if err == nil {
+			previous = *latest.Recipe
+			number = latest.Number + 1
+		} else if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Recording revision of recipe %s failed: %v", recipe.ID.Hex(), err)
+			return
+		}
+
+		err = handler.revisions.Append(ctx, models.Revision{
+			RecipeID:     recipe.ID,
+			Number:       number,
+			Action:       action,
+			Author:       author,
+			CreatedAt:    time.Now(),
+			RevertedFrom: revertedFrom,
+			Changes:      revisionChanges(previous, recipe),
+			Recipe:       &recipe,
+		})
+		if !errors.Is(err, store.ErrDuplicate) {
+			if err != nil {
+				log.Printf("Recording revision of recipe %s failed: %v", recipe.ID.Hex(), err)
+			}
+			return
+		}
+	}
+	log.Printf("Recording revision of recipe %s failed: too many concurrent changes", recipe.ID.Hex())
+}
+
+// revisionChanges lists the editable fields, the status and the scheduled
+// publication time that differ between two states of a recipe.
+func revisionChanges(before, after models.Recipe) []models.FieldChange {
+	changes := models.DiffRecipes(before, after)
+	if before.Status != after.Status {
+		changes = append(changes, models.FieldChange{Field: "status", From: before.Status, To: after.Status})
+	}
+	if (before.PublishAt == nil) != (after.PublishAt == nil) ||
+		before.PublishAt != nil && !before.PublishAt.Equal(*after.PublishAt) {
+		changes = append(changes, models.FieldChange{Field: "publishAt", From: before.PublishAt, To: after.PublishAt})
+	}
+	return changes
+}
+
+// ListRevisionsHandler godoc
+//
+//	@Summary		List recipe revisions
+//	@Description	list the revisions of a recipe, newest first, with the fields each one changed. The total number of revisions is returned in the X-Total-Count header.
+//	@Tags			recipes
+//	@Produce		json
+//	@Param			id		path		string	true	"Recipe ID"
+//	@Param			limit	query		int		false	"Page size (1-100, default 20)"
+//	@Param			offset	query		int		false	"Number of revisions to skip"
+//	@Success		200	{array}		models.Revision
+//	@Failure		400	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id}/revisions [get]
+func (handler *RecipesHandler) ListRevisionsHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	limit, offset, err := parseOffsetPage(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+
+	if !handler.historyVisible(c, objectId) {
+		return
+	}
+	revisions, total, err := handler.revisions.List(handler.ctx, objectId, limit, offset)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	writeOffsetHeaders(c, offset, limit, total)
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRevisionHandler godoc
+//
+//	@Summary		Get recipe revision
+//	@Description	get one revision of a recipe including the full recipe as it was saved
+//	@Tags			recipes
+//	@Produce		json
+//	@Param			id	path		string	true	"Recipe ID"
+//	@Param			rev	path		int		true	"Revision number"
+//	@Success		200	{object}	models.Revision
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id}/revisions/{rev} [get]
+func (handler *RecipesHandler) GetRevisionHandler(c *gin.Context) {
+	revision, ok := handler.findRevision(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// findRevision looks up the revision named by the id and rev path
+// parameters, writing a 404 when there is none or the caller may not see
+// the recipe.
+func (handler *RecipesHandler) findRevision(c *gin.Context) (models.Revision, bool) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return models.Revision{}, false
+	}
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || number < 1 {
+		revisionNotFound(c)
+		return models.Revision{}, false
+	}
+	if !handler.historyVisible(c, objectId) {
+		return models.Revision{}, false
+	}
+
+	revision, err := handler.revisions.Get(handler.ctx, objectId, number)
+	if errors.Is(err, store.ErrNotFound) {
+		revisionNotFound(c)
+		return revision, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return revision, false
+	}
+	return revision, true
+}
+
+// historyVisible writes a 404 unless the caller may see the history of a
+// recipe: that of a live recipe whenever they may see the recipe, that of
+// a recipe in the trash only when it is in their trash, as for its author
+// or an editor.
+func (handler *RecipesHandler) historyVisible(c *gin.Context, id primitive.ObjectID) bool {
+	user := currentUser(c)
+	recipe, err := handler.store.Get(handler.ctx, id)
+	visible := err == nil && recipe.VisibleTo(user)
+	if errors.Is(err, store.ErrNotFound) {
+		recipe, err = handler.store.GetDeleted(handler.ctx, id)
+		visible = err == nil && user.Username != "" && (recipe.Author == user.Username || user.Can(models.RoleEditor))
+	}
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		internalError(c, err)
+		return false
+	}
+	if !visible {
+		recipeNotFound(c)
+		return false
+	}
+	return true
+}
+
+// revisionNotFound aborts the request with a 404 for the revision.
+func revisionNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeRevisionNotFound, "Revision not found")
+}
+
+// RevertRecipeHandler godoc
+//
+//	@Summary		Revert recipe
+//	@Description	restore the name, tags, ingredients, instructions and servings of an earlier revision. The restore is recorded as a new revision. A published recipe changed by anyone but an editor goes back to review.
+//	@Tags			recipes
+//	@Produce		json
+//	@Param			id			path		string	true	"Recipe ID"
+//	@Param			rev			path		int		true	"Revision number to restore"
+//	@Param			If-Match	header		string	false	"ETag of the recipe being replaced"
+//	@Success		200	{object}	models.Recipe
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		412	{object}	Problem
+//	@Router			/recipes/{id}/revert/{rev} [post]
+func (handler *RecipesHandler) RevertRecipeHandler(c *gin.Context) {
+	revision, ok := handler.findRevision(c)
+	if !ok {
+		return
+	}
+	var status string
+	recipe, ok := handler.writeIfUnchanged(c, revision.RecipeID, func(current models.Recipe) (models.Recipe, error) {
+		status = current.Status
+		updated := editRecipe(c, current, *revision.Recipe)
+		return updated, handler.store.UpdateIfUnchanged(handler.ctx, revision.RecipeID, current, updated)
+	})
+	if !ok {
+		return
+	}
+
+	handler.invalidateEdit(revision.RecipeID, status, recipe.Status)
+	handler.recordRevision(handler.ctx, recipe, currentUser(c).Username, models.RevisionRevert, revision.Number)
+
+	c.Header("ETag", recipeETag(recipe))
+	c.JSON(http.StatusOK, recipe)
+}
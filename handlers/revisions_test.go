@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"strconv"
+	"testing"
+)
+
+// Every write records the recipe as it wrote it and answers with the ETag a
+// following GET returns.
+func TestWritesRecordRevisions(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Stew")
+	path := "/recipes/" + recipe.ID.Hex()
+
+	writes := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers []string
+		action  models.RevisionAction
+		want    string
+	}{
+		{"put", http.MethodPut, path, `{"name":"Beef stew","ingredients":["1 kg beef"]}`, nil, models.RevisionUpdate, "Beef stew"},
+		{"patch", http.MethodPatch, path, `{"name":"Lamb stew"}`, []string{"Content-Type", mergePatchType}, models.RevisionUpdate, "Lamb stew"},
+		{"revert", http.MethodPost, path + "/revert/2", "", nil, models.RevisionRevert, "Beef stew"},
+	}
+	for i, tt := range writes {
+		rec := s.do(tt.method, tt.path, "alice", tt.body, tt.headers...)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", tt.name, rec.Code, rec.Body)
+		}
+		if got := s.do(http.MethodGet, path, "alice", "").Header().Get("ETag"); rec.Header().Get("ETag") != got {
+			t.Errorf("%s: ETag %s, GET returns %s", tt.name, rec.Header().Get("ETag"), got)
+		}
+
+		var revision models.Revision
+		decode(t, s.do(http.MethodGet, path+"/revisions/"+strconv.Itoa(i+2), "alice", ""), &revision)
+		if revision.Action != tt.action || revision.Recipe == nil || revision.Recipe.Name != tt.want {
+			t.Errorf("%s: revision %d = %s of %+v, want %s of %s", tt.name, i+2, revision.Action, revision.Recipe, tt.action, tt.want)
+		}
+	}
+
+	if rec := s.do(http.MethodDelete, path, "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	var revision models.Revision
+	decode(t, s.do(http.MethodGet, path+"/revisions/5", "alice", ""), &revision)
+	if revision.Action != models.RevisionDelete || revision.Recipe == nil || revision.Recipe.DeletedAt == nil {
+		t.Errorf("delete revision = %+v, want the trashed recipe", revision)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"recipes-api/models"
+	"recipes-api/store"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchResult is a page of search results as stored in the cache.
+type searchResult struct {
+	Recipes []models.Recipe `json:"recipes"`
+	Total   int64           `json:"total"`
+}
+
+// parseSearchQuery reads the /recipes/search query string.
+func parseSearchQuery(c *gin.Context) (store.SearchQuery, error) {
+	query := store.SearchQuery{
+		Text:               strings.TrimSpace(c.Query("q")),
+		Tags:               queryList(c, "tag"),
+		TagMatch:           c.DefaultQuery("match", store.TagMatchAll),
+		ExcludeTags:        queryList(c, "excludeTag"),
+		Ingredients:        queryList(c, "ingredient"),
+		ExcludeIngredients: queryList(c, "excludeIngredient"),
+		Visible:            visibility(c),
+	}
+
+	if query.TagMatch != store.TagMatchAll && query.TagMatch != store.TagMatchAny {
+		return query, errors.New("match must be all or any")
+	}
+
+	for _, difficulty := range queryList(c, "difficulty") {
+		query.Difficulties = append(query.Difficulties, strings.ToLower(difficulty))
+	}
+	for _, cuisine := range queryList(c, "cuisine") {
+		query.Cuisines = append(query.Cuisines, strings.ToLower(cuisine))
+	}
+	for _, course := range queryList(c, "course") {
+		query.Courses = append(query.Courses, strings.ToLower(course))
+	}
+
+	var err error
+	if query.MaxTotalTime, err = queryMinutes(c, "maxTotalTime"); err != nil {
+		return query, err
+	}
+	if query.MaxPrepTime, err = queryMinutes(c, "maxPrepTime"); err != nil {
+		return query, err
+	}
+	if value := c.Query("maxCalories"); value != "" {
+		query.MaxCalories, err = strconv.ParseFloat(value, 64)
+		if err != nil || query.MaxCalories <= 0 {
+			return query, errors.New("maxCalories must be a positive number")
+		}
+	}
+
+	query.Limit, query.Offset, err = parseOffsetPage(c)
+	return query, err
+}
+
+// queryMinutes reads a duration in minutes, zero when it is not given.
+func queryMinutes(c *gin.Context, name string) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 1 || minutes > models.MaxMinutes {
+		return 0, fmt.Errorf("%s must be between 1 and %d minutes", name, models.MaxMinutes)
+	}
+	return minutes, nil
+}
+
+// parseOffsetPage reads the limit and offset of an offset paginated
+// listing.
+func parseOffsetPage(c *gin.Context) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// queryList collects a multi-valued parameter given either repeated
+// (tag=a&tag=b) or comma separated (tag=a,b).
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, raw := range c.QueryArray(name) {
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// writeOffsetHeaders exposes the total count of an offset paginated
+// listing and, when more items remain, a link to the next page.
+func writeOffsetHeaders(c *gin.Context, offset, limit int, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	next := offset + limit
+	if int64(next) >= total {
+		return
+	}
+	values := c.Request.URL.Query()
+	values.Set("offset", strconv.Itoa(next))
+	link := url.URL{Path: c.Request.URL.Path, RawQuery: values.Encode()}
+	c.Header("Link", fmt.Sprintf("<%s>; rel=\"next\"", link.String()))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"slices"
+	"testing"
+)
+
+func TestSearchRecipes(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"Tomato soup", "Onion soup"} {
+		s.publish(s.create("alice", name))
+	}
+	s.create("bob", "Secret soup")
+
+	tests := []struct {
+		name      string
+		query     string
+		user      string
+		want      []string
+		wantTotal string
+	}{
+		{"published only", "q=soup", "", []string{"Onion soup", "Tomato soup"}, "2"},
+		{"own drafts", "q=soup", "bob", []string{"Onion soup", "Secret soup", "Tomato soup"}, "3"},
+		{"editors see all", "q=soup", "erin", []string{"Onion soup", "Secret soup", "Tomato soup"}, "3"},
+		{"comma separated list", "ingredient=egg,EGG", "", []string{"Onion soup", "Tomato soup"}, "2"},
+		// Equally relevant matches come in the order they were created.
+		{"paged", "q=soup&limit=1&offset=1", "", []string{"Onion soup"}, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodGet, "/recipes/search?"+tt.query, tt.user, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var recipes []models.Recipe
+			decode(t, rec, &recipes)
+			var got []string
+			for _, recipe := range recipes {
+				got = append(got, recipe.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("names = %q, want %q", got, tt.want)
+			}
+			if total := rec.Header().Get("X-Total-Count"); total != tt.wantTotal {
+				t.Errorf("X-Total-Count = %s, want %s", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestSearchRecipesInvalidParameters(t *testing.T) {
+	s := newTestServer(t)
+	for _, query := range []string{
+		"match=some",
+		"maxTotalTime=0",
+		"maxPrepTime=abc",
+		"maxCalories=-1",
+		"limit=0",
+		"offset=-1",
+	} {
+		rec := s.do(http.MethodGet, "/recipes/search?"+query, "", "")
+		if rec.Code != http.StatusBadRequest || problemCode(t, rec) != CodeInvalidParameter {
+			t.Errorf("%s: status %d: %s", query, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChangeStatusHandler godoc
+//
+//	@Summary		Change recipe status
+//	@Description	move a recipe between draft, in_review, published and archived. Authors submit their own drafts for review, withdraw them and archive their recipes; editors make every other change. Publishing with a future publishAt schedules the recipe instead: it stays in review until then and is published in the background.
+//	@Tags			recipes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string				true	"Recipe ID"
+//	@Param			status	body		models.StatusChange	true	"New status"
+//	@Success		200	{object}	models.Recipe
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Failure		409	{object}	Problem
+//	@Router			/recipes/{id}/status [post]
+func (handler *RecipesHandler) ChangeStatusHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		recipeNotFound(c)
+		return
+	}
+	var change models.StatusChange
+	if !bindJSON(c, &change) {
+		return
+	}
+	if change.PublishAt != nil && change.Status != models.StatusPublished {
+		validationFailed(c, "publishAt", "is only allowed when publishing")
+		return
+	}
+
+	current, err := handler.store.Get(handler.ctx, objectId)
+	if errors.Is(err, store.ErrNotFound) || err == nil && !current.VisibleTo(currentUser(c)) {
+		recipeNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	owner := current.Author != "" && current.Author == user.Username
+	editor := user.Can(models.RoleEditor)
+	if !models.CanTransition(current.Status, change.Status, owner, editor) {
+		switch {
+		case !models.CanTransition(current.Status, change.Status, true, true):
+			problem(c, http.StatusConflict, CodeInvalidTransition, fmt.Sprintf("Recipe is already %s", current.Status))
+		case !owner && !editor:
+			forbidden(c, CodeNotRecipeOwner, "Only the author of a recipe or an editor may change its status")
+		default:
+			forbidden(c, CodeInsufficientRole,
+				fmt.Sprintf("Only editors may move a recipe from %s to %s", current.Status, change.Status))
+		}
+		return
+	}
+
+	now := time.Now()
+	updated := current
+	updated.Status = change.Status
+	updated.PublishAt = nil
+	if change.Status == models.StatusPublished {
+		if change.PublishAt != nil && change.PublishAt.After(now) {
+			publishAt := change.PublishAt.UTC()
+			updated.Status = models.StatusInReview
+			updated.PublishAt = &publishAt
+		} else {
+			updated.PublishedAt = now
+		}
+	}
+
+	err = handler.store.SetStatus(handler.ctx, objectId, current.Status, updated)
+	if errors.Is(err, store.ErrNotFound) {
+		recipeNotFound(c)
+		return
+	}
+	if errors.Is(err, store.ErrConflict) {
+		problem(c, http.StatusConflict, CodeConcurrentUpdate, "Recipe status is being changed concurrently, try again")
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	handler.invalidateStatus(objectId)
+	handler.recordRevision(handler.ctx, updated, user.Username, models.RevisionStatus, 0)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// PublishScheduled publishes the recipes whose scheduled publication time
+// has come and returns how many there were.
+func (handler *RecipesHandler) PublishScheduled(ctx context.Context) (int, error) {
+	published, err := handler.store.PublishDue(ctx, time.Now())
+	for _, recipe := range published {
+		handler.invalidateStatus(recipe.ID)
+		handler.recordRevision(ctx, recipe, "", models.RevisionStatus, 0)
+	}
+	return len(published), err
+}
+
+// visibility limits listings to the recipes the caller may see: editors
+// see every recipe, everyone else the published ones and their own.
+func visibility(c *gin.Context) *store.Visibility {
+	user := currentUser(c)
+	if user.Can(models.RoleEditor) {
+		return nil
+	}
+	return &store.Visibility{Author: user.Username}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"testing"
+)
+
+func TestRecipeVisibility(t *testing.T) {
+	s := newTestServer(t)
+	draft := s.create("alice", "Draft")
+	published := s.create("alice", "Published")
+	s.publish(published)
+	trashed := s.create("alice", "Trashed")
+	s.publish(trashed)
+	if rec := s.do(http.MethodDelete, "/recipes/"+trashed.ID.Hex(), "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("deleting: status %d: %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name   string
+		recipe models.Recipe
+		suffix string
+		// want maps each caller, anonymous as "", to the status expected.
+		want map[string]int
+	}{
+		{"draft", draft, "", map[string]int{"": 404, "bob": 404, "alice": 200, "erin": 200}},
+		{"draft revisions", draft, "/revisions", map[string]int{"": 404, "bob": 404, "alice": 200, "erin": 200}},
+		{"draft revision", draft, "/revisions/1", map[string]int{"": 404, "bob": 404, "alice": 200, "erin": 200}},
+		{"published", published, "", map[string]int{"": 200, "bob": 200, "alice": 200, "erin": 200}},
+		{"published revisions", published, "/revisions", map[string]int{"": 200, "bob": 200, "alice": 200, "erin": 200}},
+		{"trashed", trashed, "", map[string]int{"": 404, "bob": 404, "alice": 404, "erin": 404}},
+		{"trashed revisions", trashed, "/revisions", map[string]int{"": 404, "bob": 404, "alice": 200, "erin": 200}},
+		{"trashed revision", trashed, "/revisions/1", map[string]int{"": 404, "bob": 404, "alice": 200, "erin": 200}},
+	}
+	for _, tt := range tests {
+		for user, want := range tt.want {
+			rec := s.do(http.MethodGet, "/recipes/"+tt.recipe.ID.Hex()+tt.suffix, user, "")
+			if rec.Code != want {
+				t.Errorf("%s as %q: status %d, want %d: %s", tt.name, user, rec.Code, want, rec.Body)
+			}
+		}
+	}
+}
+
+// Authors cannot publish, so their changes to a published recipe wait for
+// an editor again.
+func TestEditsOfPublishedRecipes(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		publish bool
+		method  string
+		suffix  string
+		body    string
+		headers []string
+		want    string
+	}{
+		{"author puts", "alice", true, http.MethodPut, "", `{"name":"Stew","ingredients":["1 kg beef"]}`, nil, models.StatusInReview},
+		{"author patches", "alice", true, http.MethodPatch, "", `{"name":"Stew"}`, []string{"Content-Type", mergePatchType}, models.StatusInReview},
+		{"author reverts", "alice", true, http.MethodPost, "/revert/1", "", nil, models.StatusInReview},
+		{"editor puts", "erin", true, http.MethodPut, "", `{"name":"Stew","ingredients":["1 kg beef"]}`, nil, models.StatusPublished},
+		{"editor patches", "erin", true, http.MethodPatch, "", `{"name":"Stew"}`, []string{"Content-Type", mergePatchType}, models.StatusPublished},
+		{"author edits a draft", "alice", false, http.MethodPut, "", `{"name":"Stew","ingredients":["1 kg beef"]}`, nil, models.StatusDraft},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			recipe := s.create("alice", "Soup")
+			if tt.publish {
+				s.publish(recipe)
+				// Renamed, so that reverting to revision 1 changes the recipe.
+				if rec := s.do(http.MethodPatch, "/recipes/"+recipe.ID.Hex(), "erin", `{"name":"Broth"}`,
+					"Content-Type", mergePatchType); rec.Code != http.StatusOK {
+					t.Fatalf("renaming: status %d: %s", rec.Code, rec.Body)
+				}
+			}
+
+			path := "/recipes/" + recipe.ID.Hex()
+			if rec := s.do(tt.method, path+tt.suffix, tt.user, tt.body, tt.headers...); rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var got models.Recipe
+			decode(t, s.do(http.MethodGet, path, tt.user, ""), &got)
+			if got.Status != tt.want {
+				t.Errorf("status = %s, want %s", got.Status, tt.want)
+			}
+			// The change of status is part of the revision of the edit.
+			if tt.want == models.StatusInReview {
+				var revisions []models.Revision
+				decode(t, s.do(http.MethodGet, path+"/revisions", tt.user, ""), &revisions)
+				if len(revisions) == 0 || !changesStatus(revisions[0]) {
+					t.Errorf("latest revision %+v does not record the change of status", revisions)
+				}
+			}
+		})
+	}
+}
+
+func changesStatus(revision models.Revision) bool {
+	for _, change := range revision.Changes {
+		if change.Field == "status" {
+			return true
+		}
+	}
+	return false
+}
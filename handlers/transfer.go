@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/recipeio"
+	"recipes-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize bounds the body of an import request.
+const maxImportSize = 64 << 20
+
+// ImportRecipesHandler godoc
+//
+//	@Summary		Import recipes
+//	@Description	Import a JSON array, NDJSON or CSV file of recipes. Invalid rows are reported and skipped. Field names are matched case-insensitively and xid identifiers are accepted.
+//	@Tags			recipes
+//	@Accept			json,text/csv,application/x-ndjson
+//	@Produce		json
+//	@Param			format	query		string	false	"json, ndjson or csv; defaults to the Content-Type"
+//	@Param			mode	query		string	false	"skip (default) keeps existing recipes, upsert replaces them"
+//	@Success		200	{object}	recipeio.Report
+//	@Failure		400	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		413	{object}	Problem
+//	@Router			/recipes/import [post]
+func (handler *RecipesHandler) ImportRecipesHandler(c *gin.Context) {
+	format := recipeio.FormatFromContentType(c.ContentType())
+	if name := c.Query("format"); name != "" {
+		var err error
+		if format, err = recipeio.ParseFormat(name); err != nil {
+			invalidParameter(c, err)
+			return
+		}
+	}
+
+	mode := c.DefaultQuery("mode", recipeio.ModeSkip)
+	if mode != recipeio.ModeSkip && mode != recipeio.ModeUpsert {
+		problem(c, http.StatusBadRequest, CodeInvalidParameter, "mode must be skip or upsert")
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	report, err := handler.Import(c.Request.Context(), body, recipeio.ImportOptions{
+		Format: format,
+		Mode:   mode,
+		Author: currentUser(c).Username,
+	})
+	if err != nil {
+		// The report tells the client which rows made it in before the
+		// import stopped.
+		p := newProblem(c, http.StatusBadRequest, CodeInvalidBody, err.Error())
+		var tooLarge *http.MaxBytesError
+		var storeErr *recipeio.StoreError
+		switch {
+		case errors.As(err, &tooLarge):
+			p = newProblem(c, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+				fmt.Sprintf("The import exceeds %d bytes", tooLarge.Limit))
+		case errors.As(err, &storeErr):
+			log.Printf("Importing recipes failed: %v", err)
+			p = newProblem(c, http.StatusInternalServerError, CodeInternal,
+				fmt.Sprintf("Storing row %d failed", storeErr.Row))
+		}
+		writeProblem(c, p, gin.H{"report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Import stores the recipes read from r, records a revision of each one
+// created or replaced and drops the cached responses they may appear in.
+func (handler *RecipesHandler) Import(ctx context.Context, r io.Reader, opts recipeio.ImportOptions) (recipeio.Report, error) {
+	report, err := recipeio.Import(ctx, handler.store, r, opts)
+	if len(report.Changed) > 0 {
+		tags := []string{tagLists, tagSearches}
+		for _, id := range report.Changed {
+			tags = append(tags, recipeTag(id))
+		}
+		handler.invalidate(tags...)
+	}
+	for _, id := range report.Changed {
+		if recipe, err := handler.store.Get(ctx, id); err == nil {
+			handler.recordRevision(ctx, recipe, opts.Author, models.RevisionImport, 0)
+		}
+	}
+	return report, err
+}
+
+// ExportRecipesHandler godoc
+//
+//	@Summary		Export recipes
+//	@Description	Stream every recipe the caller may see as a JSON array, NDJSON or CSV: the published ones and their own, or every recipe for editors.
+//	@Tags			recipes
+//	@Produce		json,text/csv,application/x-ndjson
+//	@Param			format	query		string	false	"json (default), ndjson or csv"
+//	@Success		200	{array}		models.Recipe
+//	@Failure		400	{object}	Problem
+//	@Router			/recipes/export [get]
+func (handler *RecipesHandler) ExportRecipesHandler(c *gin.Context) {
+	format, err := recipeio.ParseFormat(c.DefaultQuery("format", recipeio.FormatJSON))
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+
+	c.Header("Content-Type", recipeio.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="recipes.%s"`, format))
+	c.Status(http.StatusOK)
+	if err := handler.Export(c.Request.Context(), c.Writer, format, visibility(c)); err != nil {
+		// The status line has been sent; all that is left is to cut the
+		// stream short.
+		log.Printf("Exporting recipes failed: %v", err)
+	}
+}
+
+// Export writes every recipe visible allows to w in format.
+func (handler *RecipesHandler) Export(ctx context.Context, w io.Writer, format string, visible *store.Visibility) error {
+	writer, err := recipeio.NewWriter(format, w)
+	if err != nil {
+		return err
+	}
+	return recipeio.Export(ctx, handler.store, writer, visible)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListTrashHandler godoc
+//
+//	@Summary		List deleted recipes
+//	@Description	list the recipes in the trash, most recently deleted first. Editors see every deleted recipe, other users only their own. The total is returned in the X-Total-Count header.
+//	@Tags			trash
+//	@Produce		json
+//	@Param			limit	query		int		false	"Page size (1-100, default 20)"
+//	@Param			offset	query		int		false	"Number of recipes to skip"
+//	@Success		200	{array}		models.Recipe
+//	@Failure		400	{object}	Problem
+//	@Router			/trash [get]
+func (handler *RecipesHandler) ListTrashHandler(c *gin.Context) {
+	limit, offset, err := parseOffsetPage(c)
+	if err != nil {
+		invalidParameter(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	author := user.Username
+	if user.Can(models.RoleEditor) {
+		author = ""
+	}
+
+	recipes, total, err := handler.store.ListDeleted(handler.ctx, author, limit, offset)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	writeOffsetHeaders(c, offset, limit, total)
+	c.JSON(http.StatusOK, recipes)
+}
+
+// RestoreRecipeHandler godoc
+//
+//	@Summary		Restore recipe
+//	@Description	take a recipe out of the trash
+//	@Tags			trash
+//	@Produce		json
+//	@Param			id	path		string	true	"Recipe ID"
+//	@Success		200	{object}	models.Recipe
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/recipes/{id}/restore [post]
+func (handler *RecipesHandler) RestoreRecipeHandler(c *gin.Context) {
+	deleted, ok := handler.findDeleted(c)
+	if !ok || !authorizeOwner(c, deleted) {
+		return
+	}
+
+	err := handler.store.Restore(handler.ctx, deleted.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		notInTrash(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	handler.invalidateCreated()
+	handler.invalidate(recipeTag(deleted.ID))
+
+	// Recipes in the trash cannot be edited, so the restored recipe is the
+	// deleted one without its deletion time.
+	recipe := deleted
+	recipe.DeletedAt = nil
+	handler.recordRevision(handler.ctx, recipe, currentUser(c).Username, models.RevisionRestore, 0)
+
+	c.Header("ETag", recipeETag(recipe))
+	c.JSON(http.StatusOK, recipe)
+}
+
+// PurgeRecipeHandler godoc
+//
+//	@Summary		Purge recipe
+//	@Description	permanently remove a recipe in the trash together with its revisions
+//	@Tags			trash
+//	@Produce		json
+//	@Param			id	path		string	true	"Recipe ID"
+//	@Success		200	{object}	Problem
+//	@Failure		403	{object}	Problem
+//	@Failure		404	{object}	Problem
+//	@Router			/admin/trash/{id} [delete]
+func (handler *RecipesHandler) PurgeRecipeHandler(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		notInTrash(c)
+		return
+	}
+
+	recipe, err := handler.store.Purge(handler.ctx, objectId)
+	if errors.Is(err, store.ErrNotFound) {
+		notInTrash(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	handler.purged(handler.ctx, recipe)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recipe has been purged"})
+}
+
+// findDeleted looks up the trashed recipe named by the id path parameter,
+// writing a 404 when there is none.
+func (handler *RecipesHandler) findDeleted(c *gin.Context) (models.Recipe, bool) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		notInTrash(c)
+		return models.Recipe{}, false
+	}
+	recipe, err := handler.store.GetDeleted(handler.ctx, objectId)
+	if errors.Is(err, store.ErrNotFound) {
+		notInTrash(c)
+		return recipe, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return recipe, false
+	}
+	return recipe, true
+}
+
+// notInTrash aborts the request with a 404 for a recipe missing from the
+// trash.
+func notInTrash(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeTrashNotFound, "Recipe not found in the trash")
+}
+
+// PurgeExpired permanently removes the recipes that have been in the trash
+// for longer than retention and returns how many there were.
+func (handler *RecipesHandler) PurgeExpired(ctx context.Context, retention time.Duration) (int, error) {
+	purged, err := handler.store.PurgeDeletedBefore(ctx, time.Now().Add(-retention))
+	for _, recipe := range purged {
+		handler.purged(ctx, recipe)
+	}
+	return len(purged), err
+}
+
+// purged removes what is left of a recipe after it has been purged: its
+// revisions, reviews and image files.
+func (handler *RecipesHandler) purged(ctx context.Context, recipe models.Recipe) {
+	if err := handler.revisions.DeleteHistory(ctx, recipe.ID); err != nil {
+		log.Printf("Removing revisions of purged recipe %s failed: %v", recipe.ID.Hex(), err)
+	}
+	if err := handler.reviews.DeleteReviews(ctx, recipe.ID); err != nil {
+		log.Printf("Removing reviews of purged recipe %s failed: %v", recipe.ID.Hex(), err)
+	}
+	for _, image := range recipe.Images {
+		handler.deleteImageFiles(ctx, image.Files)
+	}
+}
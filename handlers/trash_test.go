@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"recipes-api/models"
+	"strconv"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	s := newTestServer(t)
+	recipe := s.create("alice", "Stew")
+	path := "/recipes/" + recipe.ID.Hex()
+	if rec := s.do(http.MethodDelete, path, "alice", ""); rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+
+	steps := []struct {
+		name   string
+		method string
+		path   string
+		user   string
+		status int
+		code   string
+	}{
+		{"get trashed", http.MethodGet, path, "alice", http.StatusNotFound, CodeRecipeNotFound},
+		{"update trashed", http.MethodPut, path, "alice", http.StatusNotFound, CodeRecipeNotFound},
+		{"delete trashed", http.MethodDelete, path, "alice", http.StatusNotFound, CodeRecipeNotFound},
+		{"restore as another author", http.MethodPost, path + "/restore", "bob", http.StatusForbidden, CodeNotRecipeOwner},
+		{"restore", http.MethodPost, path + "/restore", "alice", http.StatusOK, ""},
+		{"get restored", http.MethodGet, path, "alice", http.StatusOK, ""},
+		{"restore again", http.MethodPost, path + "/restore", "alice", http.StatusNotFound, CodeTrashNotFound},
+		{"purge live recipe", http.MethodDelete, "/admin/trash/" + recipe.ID.Hex(), "erin", http.StatusNotFound, CodeTrashNotFound},
+		{"delete again", http.MethodDelete, path, "alice", http.StatusOK, ""},
+		{"purge", http.MethodDelete, "/admin/trash/" + recipe.ID.Hex(), "erin", http.StatusOK, ""},
+		{"restore purged", http.MethodPost, path + "/restore", "alice", http.StatusNotFound, CodeTrashNotFound},
+	}
+	for _, step := range steps {
+		body := ""
+		if step.method == http.MethodPut {
+			body = `{"name":"Beef stew","ingredients":["1 kg beef"]}`
+		}
+		rec := s.do(step.method, step.path, step.user, body)
+		if rec.Code != step.status {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, rec.Code, step.status, rec.Body)
+		}
+		if step.code != "" && problemCode(t, rec) != step.code {
+			t.Errorf("%s: code %s, want %s", step.name, problemCode(t, rec), step.code)
+		}
+	}
+}
+
+func TestListTrash(t *testing.T) {
+	s := newTestServer(t)
+	for _, owner := range []string{"alice", "alice", "bob"} {
+		recipe := s.create(owner, "Stew")
+		if rec := s.do(http.MethodDelete, "/recipes/"+recipe.ID.Hex(), owner, ""); rec.Code != http.StatusOK {
+			t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	tests := []struct {
+		user  string
+		count int
+	}{
+		{"alice", 2},
+		{"bob", 1},
+		{"erin", 3},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodGet, "/trash", tt.user, "")
+		var recipes []models.Recipe
+		decode(t, rec, &recipes)
+		if len(recipes) != tt.count || rec.Header().Get("X-Total-Count") != strconv.Itoa(tt.count) {
+			t.Errorf("trash of %s holds %d recipes, total %s, want %d",
+				tt.user, len(recipes), rec.Header().Get("X-Total-Count"), tt.count)
+		}
+		for _, recipe := range recipes {
+			if tt.user != "erin" && recipe.Author != tt.user {
+				t.Errorf("trash of %s lists a recipe of %s", tt.user, recipe.Author)
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"recipes-api/models"
+	"recipes-api/store"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	minPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordLength = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+type PasswordChange struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return fmt.Errorf("password must be between %d and %d bytes", minPasswordLength, maxPasswordLength)
+	}
+	return nil
+}
+
+// currentUser returns the user stored by AuthMiddleware.
+func currentUser(c *gin.Context) models.User {
+	user, _ := c.Get("user")
+	u, _ := user.(models.User)
+	return u
+}
+
+// SignUpHandler godoc
+//
+//	@Summary		Signup
+//	@Description	Register a new user
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			user	body		models.User	true	"Username and password"
+//	@Success		201		{object}	models.User
+//	@Failure		400		{object}	Problem
+//	@Failure		409		{object}	Problem
+//	@Router			/signup [post]
+func (handler *AuthHandler) SignUpHandler(c *gin.Context) {
+	var input models.User
+	if !bindJSON(c, &input) {
+		return
+	}
+	if !usernamePattern.MatchString(input.Username) {
+		validationFailed(c, "username", "must be 3-32 characters of letters, digits, '.', '_' or '-'")
+		return
+	}
+	if err := validatePassword(input.Password); err != nil {
+		validationFailed(c, "password", err.Error())
+		return
+	}
+
+	hash, err := handler.hasher.Hash(input.Password)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	user := models.User{
+		ID:        primitive.NewObjectID(),
+		Username:  input.Username,
+		Password:  hash,
+		CreatedAt: time.Now(),
+		Roles:     append([]string(nil), models.DefaultRoles...),
+	}
+
+	err = handler.users.CreateUser(handler.ctx, user)
+	if errors.Is(err, store.ErrDuplicate) {
+		problem(c, http.StatusConflict, CodeUsernameTaken, "Username is already taken")
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusCreated, user)
+}
+
+// ChangePasswordHandler godoc
+//
+//	@Summary		Change password
+//	@Description	Change the password of the signed in user. Every other session of the user is signed out.
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			passwords	body		PasswordChange	true	"Current and new password"
+//	@Success		200			{object}	JWTOutput
+//	@Failure		400			{object}	Problem
+//	@Failure		401			{object}	Problem
+//	@Router			/me/password [put]
+func (handler *AuthHandler) ChangePasswordHandler(c *gin.Context) {
+	var input PasswordChange
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := validatePassword(input.NewPassword); err != nil {
+		validationFailed(c, "newPassword", err.Error())
+		return
+	}
+
+	username := currentUser(c).Username
+	if _, ok := handler.checkPassword(username, input.CurrentPassword); !ok {
+		problem(c, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid current password")
+		return
+	}
+
+	hash, err := handler.hasher.Hash(input.NewPassword)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	if err := handler.users.ChangePassword(handler.ctx, username, hash); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	user, err := handler.users.GetUser(handler.ctx, username)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	handler.startSession(c, user, "Password has been changed")
+}
+
+// RequireRole rejects callers that hold neither role nor a higher one. It
+// must run after AuthMiddleware.
+func (handler *AuthHandler) RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentUser(c).Can(role) {
+			forbidden(c, CodeInsufficientRole, fmt.Sprintf("This action requires the %s role", role))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ListUsersHandler godoc
+//
+//	@Summary		List users
+//	@Description	List every user. Admin only.
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		models.User
+//	@Failure		403	{object}	Problem
+//	@Router			/admin/users [get]
+func (handler *AuthHandler) ListUsersHandler(c *gin.Context) {
+	users, err := handler.users.ListUsers(handler.ctx)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	for i := range users {
+		users[i].Password = ""
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// DisableUserHandler godoc
+//
+//	@Summary		Disable user
+//	@Description	Disable a user and sign out their sessions. Admin only.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	Problem
+//	@Failure		404			{object}	Problem
+//	@Failure		409			{object}	Problem
+//	@Router			/admin/users/{username}/disable [post]
+func (handler *AuthHandler) DisableUserHandler(c *gin.Context) {
+	handler.setDisabled(c, true, "User has been disabled")
+}
+
+// EnableUserHandler godoc
+//
+//	@Summary		Enable user
+//	@Description	Re-enable a disabled user. Admin only.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	Problem
+//	@Failure		404			{object}	Problem
+//	@Router			/admin/users/{username}/enable [post]
+func (handler *AuthHandler) EnableUserHandler(c *gin.Context) {
+	handler.setDisabled(c, false, "User has been enabled")
+}
+
+func (handler *AuthHandler) setDisabled(c *gin.Context, disabled bool, message string) {
+	username := c.Param("username")
+	if disabled && username == currentUser(c).Username {
+		problem(c, http.StatusConflict, CodeOwnAccount, "You cannot disable your own account")
+		return
+	}
+
+	err := handler.users.SetDisabled(handler.ctx, username, disabled)
+	if errors.Is(err, store.ErrNotFound) {
+		userNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// userNotFound aborts the request with a 404 for the user.
+func userNotFound(c *gin.Context) {
+	problem(c, http.StatusNotFound, CodeUserNotFound, "User not found")
+}
+
+type RolesInput struct {
+	Roles []string `json:"roles" binding:"required,min=1,dive,oneof=viewer author editor admin"`
+}
+
+// SetRolesHandler godoc
+//
+//	@Summary		Set user roles
+//	@Description	Replace the roles of a user (viewer, author, editor, admin). Admin only.
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			username	path		string		true	"Username"
+//	@Param			roles		body		RolesInput	true	"Roles"
+//	@Success		200			{object}	Problem
+//	@Failure		400			{object}	Problem
+//	@Failure		404			{object}	Problem
+//	@Failure		409			{object}	Problem
+//	@Router			/admin/users/{username}/roles [put]
+func (handler *AuthHandler) SetRolesHandler(c *gin.Context) {
+	var input RolesInput
+	if !bindJSON(c, &input) {
+		return
+	}
+
+	username := c.Param("username")
+	if username == currentUser(c).Username && !slices.Contains(input.Roles, models.RoleAdmin) {
+		problem(c, http.StatusConflict, CodeOwnAccount, "You cannot remove your own admin role")
+		return
+	}
+
+	err := handler.users.SetRoles(handler.ctx, username, input.Roles)
+	if errors.Is(err, store.ErrNotFound) {
+		userNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Roles have been updated"})
+}
+
+// DeleteUserHandler godoc
+//
+//	@Summary		Delete user
+//	@Description	Delete a user. Admin only. The account is signed out everywhere and its username cannot be registered again.
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	Problem
+//	@Failure		404			{object}	Problem
+//	@Failure		409			{object}	Problem
+//	@Router			/admin/users/{username} [delete]
+func (handler *AuthHandler) DeleteUserHandler(c *gin.Context) {
+	username := c.Param("username")
+	if username == currentUser(c).Username {
+		problem(c, http.StatusConflict, CodeOwnAccount, "You cannot delete your own account")
+		return
+	}
+
+	err := handler.users.DeleteUser(handler.ctx, username)
+	if errors.Is(err, store.ErrNotFound) {
+		userNotFound(c)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User has been deleted"})
+}
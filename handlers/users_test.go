@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"recipes-api/models"
+	"recipes-api/password"
+	"recipes-api/store"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authServer serves the account routes in jwt mode from a memory store
+// holding an admin account, root, with the password "root password".
+type authServer struct {
+	t      *testing.T
+	router *gin.Engine
+}
+
+func newAuthServer(t *testing.T) *authServer {
+	t.Helper()
+	hasher, err := password.New("bcrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := store.NewMemoryUserStore()
+	hash, err := hasher.Hash("root password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.CreateUser(context.Background(),
+		models.User{Username: "root", Password: hash, Roles: []string{models.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+	auth := NewAuthHandler(context.Background(), users, hasher, AuthModeJWT, []byte("0123456789abcdef0123456789abcdef"))
+
+	router := gin.New()
+	router.POST("/signup", auth.SignUpHandler)
+	router.POST("/signin", auth.SignInHandler)
+	authorized := router.Group("/", auth.AuthMiddleware())
+	authorized.PUT("/me/password", auth.ChangePasswordHandler)
+	admin := authorized.Group("/admin", auth.RequireRole(models.RoleAdmin))
+	admin.GET("/users", auth.ListUsersHandler)
+	admin.POST("/users/:username/disable", auth.DisableUserHandler)
+	admin.POST("/users/:username/enable", auth.EnableUserHandler)
+	admin.PUT("/users/:username/roles", auth.SetRolesHandler)
+	admin.DELETE("/users/:username", auth.DeleteUserHandler)
+	return &authServer{t: t, router: router}
+}
+
+// do sends a request with token, which may be empty, as bearer token.
+func (s *authServer) do(method, path, token, body string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// signIn returns a token for the account, failing the test otherwise.
+func (s *authServer) signIn(username, password string) string {
+	s.t.Helper()
+	rec := s.do(http.MethodPost, "/signin", "", `{"username":"`+username+`","password":"`+password+`"}`)
+	if rec.Code != http.StatusOK {
+		s.t.Fatalf("signing in %s: status %d: %s", username, rec.Code, rec.Body)
+	}
+	var output JWTOutput
+	decode(s.t, rec, &output)
+	return output.Token
+}
+
+func TestSignUp(t *testing.T) {
+	s := newAuthServer(t)
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"valid", `{"username":"alice","password":"correct horse"}`, http.StatusCreated, ""},
+		{"taken", `{"username":"alice","password":"correct horse"}`, http.StatusConflict, CodeUsernameTaken},
+		{"short username", `{"username":"al","password":"correct horse"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"username with spaces", `{"username":"al ice","password":"correct horse"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"short password", `{"username":"bob","password":"short"}`, http.StatusBadRequest, CodeValidationFailed},
+		{"long password", `{"username":"bob","password":"` + strings.Repeat("x", 73) + `"}`, http.StatusBadRequest, CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := s.do(http.MethodPost, "/signup", "", tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" {
+				if code := problemCode(t, rec); code != tt.wantCode {
+					t.Errorf("code = %s, want %s", code, tt.wantCode)
+				}
+				return
+			}
+			var user models.User
+			decode(t, rec, &user)
+			if user.Password != "" || len(user.Roles) != 1 || user.Roles[0] != models.RoleAuthor {
+				t.Errorf("user = %+v, want an author without password", user)
+			}
+		})
+	}
+	s.signIn("alice", "correct horse")
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newAuthServer(t)
+	s.do(http.MethodPost, "/signup", "", `{"username":"alice","password":"correct horse"}`)
+	token := s.signIn("alice", "correct horse")
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"wrong current password", `{"currentPassword":"wrong horse","newPassword":"battery staple"}`,
+			http.StatusUnauthorized, CodeInvalidCredentials},
+		{"short new password", `{"currentPassword":"correct horse","newPassword":"short"}`,
+			http.StatusBadRequest, CodeValidationFailed},
+		{"missing current password", `{"newPassword":"battery staple"}`, http.StatusBadRequest, CodeValidationFailed},
+	}
+	for _, tt := range tests {
+		rec := s.do(http.MethodPut, "/me/password", token, tt.body)
+		if rec.Code != tt.wantStatus || problemCode(t, rec) != tt.wantCode {
+			t.Errorf("%s: status %d: %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+
+	rec := s.do(http.MethodPut, "/me/password", token, `{"currentPassword":"correct horse","newPassword":"battery staple"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("changing: status %d: %s", rec.Code, rec.Body)
+	}
+	var output JWTOutput
+	decode(t, rec, &output)
+
+	// Tokens issued before the change stop working; the new one works.
+	if rec := s.do(http.MethodPut, "/me/password", token, `{}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("old token: status %d, want 401", rec.Code)
+	}
+	if rec := s.do(http.MethodPut, "/me/password", output.Token, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("new token: status %d, want 400 for the empty body", rec.Code)
+	}
+	if rec := s.do(http.MethodPost, "/signin", "", `{"username":"alice","password":"correct horse"}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("signing in with the old password: status %d, want 401", rec.Code)
+	}
+	s.signIn("alice", "battery staple")
+}
+
+func TestManageUsers(t *testing.T) {
+	s := newAuthServer(t)
+	s.do(http.MethodPost, "/signup", "", `{"username":"alice","password":"correct horse"}`)
+	alice := s.signIn("alice", "correct horse")
+	root := s.signIn("root", "root password")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"not an admin", http.MethodGet, "/admin/users", alice, "", http.StatusForbidden, CodeInsufficientRole},
+		{"list", http.MethodGet, "/admin/users", root, "", http.StatusOK, ""},
+		{"disable own account", http.MethodPost, "/admin/users/root/disable", root, "", http.StatusConflict, CodeOwnAccount},
+		{"drop own admin role", http.MethodPut, "/admin/users/root/roles", root, `{"roles":["editor"]}`, http.StatusConflict, CodeOwnAccount},
+		{"unknown role", http.MethodPut, "/admin/users/alice/roles", root, `{"roles":["chef"]}`, http.StatusBadRequest, CodeValidationFailed},
+		{"set roles", http.MethodPut, "/admin/users/alice/roles", root, `{"roles":["editor"]}`, http.StatusOK, ""},
+		{"disable unknown user", http.MethodPost, "/admin/users/nobody/disable", root, "", http.StatusNotFound, CodeUserNotFound},
+		{"disable", http.MethodPost, "/admin/users/alice/disable", root, "", http.StatusOK, ""},
+		{"disabled account's token", http.MethodGet, "/admin/users", alice, "", http.StatusUnauthorized, ""},
+		{"disabled account signs in", http.MethodPost, "/signin", "", `{"username":"alice","password":"correct horse"}`,
+			http.StatusForbidden, CodeAccountDisabled},
+		{"enable", http.MethodPost, "/admin/users/alice/enable", root, "", http.StatusOK, ""},
+		{"delete own account", http.MethodDelete, "/admin/users/root", root, "", http.StatusConflict, CodeOwnAccount},
+		{"delete", http.MethodDelete, "/admin/users/alice", root, "", http.StatusOK, ""},
+		{"delete again", http.MethodDelete, "/admin/users/alice", root, "", http.StatusNotFound, CodeUserNotFound},
+		{"deleted account signs in", http.MethodPost, "/signin", "", `{"username":"alice","password":"correct horse"}`,
+			http.StatusUnauthorized, CodeInvalidCredentials},
+		{"username of a deleted account", http.MethodPost, "/signup", "", `{"username":"alice","password":"correct horse"}`,
+			http.StatusConflict, CodeUsernameTaken},
+	}
+	for _, tt := range tests {
+		rec := s.do(tt.method, tt.path, tt.token, tt.body)
+		if rec.Code != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.wantStatus, rec.Body)
+		}
+		if tt.wantCode != "" && problemCode(t, rec) != tt.wantCode {
+			t.Errorf("%s: code = %s, want %s", tt.name, problemCode(t, rec), tt.wantCode)
+		}
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	tests := []struct {
+		roles []string
+		role  string
+		want  int
+	}{
+		{[]string{models.RoleViewer}, models.RoleAuthor, http.StatusForbidden},
+		{[]string{models.RoleAuthor}, models.RoleAuthor, http.StatusOK},
+		{[]string{models.RoleAdmin}, models.RoleEditor, http.StatusOK},
+		{[]string{models.RoleEditor}, models.RoleAdmin, http.StatusForbidden},
+	}
+	auth := &AuthHandler{}
+	for _, tt := range tests {
+		router := gin.New()
+		router.GET("/", func(c *gin.Context) {
+			c.Set("user", models.User{Username: "alice", Roles: tt.roles})
+		}, auth.RequireRole(tt.role), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != tt.want {
+			t.Errorf("%v requiring %s: status %d, want %d", tt.roles, tt.role, rec.Code, tt.want)
+		}
+		if tt.want == http.StatusForbidden && problemCode(t, rec) != CodeInsufficientRole {
+			t.Errorf("%v requiring %s: code %s, want %s", tt.roles, tt.role, problemCode(t, rec), CodeInsufficientRole)
+		}
+	}
+}
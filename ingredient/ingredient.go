@@ -0,0 +1,154 @@
+// Package ingredient parses free-text ingredient lines such as
+// "3 3/4 cups (490 g) bread flour" into quantities, units and items, and
+// scales them.
+package ingredient
+
+import (
+	"strings"
+)
+
+// Amount is a quantity in a unit.
+type Amount struct {
+	Quantity Quantity `json:"quantity"`
+	Unit     string   `json:"unit,omitempty"`
+}
+
+// Ingredient is a structured ingredient line. Quantity is nil for lines
+// without one, such as "salt, to taste". Alternate holds a second
+// measurement given in parentheses after the first.
+type Ingredient struct {
+	Text      string    `json:"text"`
+	Quantity  *Quantity `json:"quantity,omitempty"`
+	Unit      string    `json:"unit,omitempty"`
+	Alternate *Amount   `json:"alternate,omitempty"`
+	Item      string    `json:"item"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// Parse converts a free-text ingredient line. It never fails: text it does
+// not understand ends up in Item.
+func Parse(line string) Ingredient {
+	line = strings.TrimSpace(line)
+	ingredient := Ingredient{Text: line}
+	rest := line
+
+	if q, after, ok := parseQuantity(rest); ok {
+		ingredient.Quantity = &q
+		rest = strings.TrimSpace(after)
+		ingredient.Unit, rest = parseUnit(rest)
+
+		if alternate, after, ok := parseAlternate(rest); ok {
+			ingredient.Alternate = &alternate
+			rest = after
+		}
+		rest = strings.TrimPrefix(rest, "of ")
+	}
+
+	ingredient.Item, ingredient.Note = splitNote(rest)
+	return ingredient
+}
+
+// ParseAll parses every line.
+func ParseAll(lines []string) []Ingredient {
+	ingredients := make([]Ingredient, len(lines))
+	for i, line := range lines {
+		ingredients[i] = Parse(line)
+	}
+	return ingredients
+}
+
+// parseUnit reads a unit of one or two words from the start of s.
+func parseUnit(s string) (string, string) {
+	words := strings.Fields(s)
+	if len(words) >= 2 {
+		if name, ok := lookupUnit(words[0] + " " + words[1]); ok {
+			second := len(words[0]) + strings.Index(s[len(words[0]):], words[1])
+			return name, strings.TrimSpace(s[second+len(words[1]):])
+		}
+	}
+	if len(words) >= 1 {
+		if name, ok := lookupUnit(words[0]); ok {
+			return name, strings.TrimSpace(s[len(words[0]):])
+		}
+	}
+	return "", s
+}
+
+// parseAlternate reads a parenthesised amount such as "(490 g)".
+func parseAlternate(s string) (Amount, string, bool) {
+	if !strings.HasPrefix(s, "(") {
+		return Amount{}, s, false
+	}
+	end := strings.Index(s, ")")
+	if end < 0 {
+		return Amount{}, s, false
+	}
+	inner := strings.TrimSpace(s[1:end])
+	q, after, ok := parseQuantity(inner)
+	if !ok {
+		return Amount{}, s, false
+	}
+	name, after := parseUnit(strings.TrimSpace(after))
+	if name == "" || after != "" {
+		return Amount{}, s, false
+	}
+	return Amount{Quantity: q, Unit: name}, strings.TrimSpace(s[end+1:]), true
+}
+
+// splitNote separates the item from a preparation note given after a comma
+// or in trailing parentheses.
+func splitNote(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, ")") {
+		if start := strings.LastIndex(s, "("); start > 0 {
+			return strings.TrimSpace(s[:start]), strings.TrimSpace(s[start+1 : len(s)-1])
+		}
+	}
+	if item, note, ok := strings.Cut(s, ","); ok {
+		return strings.TrimSpace(item), strings.TrimSpace(note)
+	}
+	return s, ""
+}
+
+// Scale returns the ingredient with its quantities multiplied by factor and
+// Text rewritten to match. Ingredients without a quantity are unchanged.
+func (ingredient Ingredient) Scale(factor float64) Ingredient {
+	if ingredient.Quantity == nil {
+		return ingredient
+	}
+	scaled := ingredient
+	q := ingredient.Quantity.Scale(factor)
+	scaled.Quantity = &q
+	if ingredient.Alternate != nil {
+		scaled.Alternate = &Amount{Quantity: ingredient.Alternate.Quantity.Scale(factor), Unit: ingredient.Alternate.Unit}
+	}
+	scaled.Text = scaled.String()
+	return scaled
+}
+
+// String formats the ingredient as a line of text.
+func (ingredient Ingredient) String() string {
+	var parts []string
+	if ingredient.Quantity != nil {
+		parts = append(parts, formatAmount(*ingredient.Quantity, ingredient.Unit))
+	}
+	if ingredient.Alternate != nil {
+		parts = append(parts, "("+formatAmount(ingredient.Alternate.Quantity, ingredient.Alternate.Unit)+")")
+	}
+	if ingredient.Item != "" {
+		parts = append(parts, ingredient.Item)
+	}
+	text := strings.Join(parts, " ")
+	if ingredient.Note != "" {
+		text += ", " + ingredient.Note
+	}
+	return text
+}
+
+func formatAmount(q Quantity, unit string) string {
+	text := q.format(IsMetric(unit))
+	if unit != "" {
+		text += " " + unitName(unit, q.plural())
+	}
+	return text
+}
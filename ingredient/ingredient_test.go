@@ -0,0 +1,66 @@
+package ingredient
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line      string
+		quantity  *Quantity
+		unit      string
+		alternate *Amount
+		item      string
+		note      string
+	}{
+		{"3 3/4 cups (490 g) bread flour", &Quantity{Value: 3.75}, "cup", &Amount{Quantity{Value: 490}, "g"}, "bread flour", ""},
+		{"1½ tsp salt", &Quantity{Value: 1.5}, "tsp", nil, "salt", ""},
+		{"½ cup sugar", &Quantity{Value: 0.5}, "cup", nil, "sugar", ""},
+		{"2-3 cloves garlic, minced", &Quantity{Value: 2, Max: 3}, "clove", nil, "garlic", "minced"},
+		{"2 to 3 large eggs", &Quantity{Value: 2, Max: 3}, "", nil, "large eggs", ""},
+		{"1 T butter", &Quantity{Value: 1}, "tbsp", nil, "butter", ""},
+		{"1 t baking soda", &Quantity{Value: 1}, "tsp", nil, "baking soda", ""},
+		{"2 fluid ounces of cream", &Quantity{Value: 2}, "fl oz", nil, "cream", ""},
+		{"0,5 l milk", &Quantity{Value: 0.5}, "l", nil, "milk", ""},
+		{"1 onion (finely chopped)", &Quantity{Value: 1}, "", nil, "onion", "finely chopped"},
+		{"salt, to taste", nil, "", nil, "salt", "to taste"},
+		{"1/0 cup water", nil, "", nil, "1/0 cup water", ""},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.line)
+		if got.Text != tt.line {
+			t.Errorf("Parse(%q).Text = %q", tt.line, got.Text)
+		}
+		if (got.Quantity == nil) != (tt.quantity == nil) || got.Quantity != nil && *got.Quantity != *tt.quantity {
+			t.Errorf("Parse(%q).Quantity = %v, want %v", tt.line, got.Quantity, tt.quantity)
+		}
+		if got.Unit != tt.unit {
+			t.Errorf("Parse(%q).Unit = %q, want %q", tt.line, got.Unit, tt.unit)
+		}
+		if (got.Alternate == nil) != (tt.alternate == nil) || got.Alternate != nil && *got.Alternate != *tt.alternate {
+			t.Errorf("Parse(%q).Alternate = %v, want %v", tt.line, got.Alternate, tt.alternate)
+		}
+		if got.Item != tt.item || got.Note != tt.note {
+			t.Errorf("Parse(%q) item, note = %q, %q, want %q, %q", tt.line, got.Item, got.Note, tt.item, tt.note)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		line   string
+		factor float64
+		want   string
+	}{
+		{"1 cup flour", 2, "2 cups flour"},
+		{"3/4 cup (90 g) flour", 2, "1 1/2 cups (180 g) flour"},
+		{"1 tbsp oil", 0.5, "1/2 tablespoon oil"},
+		{"2-3 cloves garlic, minced", 2, "4-6 cloves garlic, minced"},
+		{"250 g butter", 1.0 / 3, "83 g butter"},
+		{"1 egg", 1.5, "1 1/2 egg"},
+		{"salt, to taste", 2, "salt, to taste"},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.line).Scale(tt.factor).Text; got != tt.want {
+			t.Errorf("Parse(%q).Scale(%v) = %q, want %q", tt.line, tt.factor, got, tt.want)
+		}
+	}
+}
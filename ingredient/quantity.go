@@ -0,0 +1,132 @@
+package ingredient
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Quantity is an amount, or a range of amounts when Max is set.
+type Quantity struct {
+	Value float64 `json:"value"`
+	Max   float64 `json:"max,omitempty"`
+}
+
+// Scale multiplies the quantity by factor.
+func (q Quantity) Scale(factor float64) Quantity {
+	return Quantity{Value: q.Value * factor, Max: q.Max * factor}
+}
+
+// plural reports whether a unit counted by q takes its plural form.
+func (q Quantity) plural() bool {
+	return q.Value > 1 || q.Max > 1
+}
+
+// format writes the quantity as fractions, or as decimals for metric units.
+func (q Quantity) format(metric bool) string {
+	text := formatNumber(q.Value, metric)
+	if q.Max != 0 {
+		text += "-" + formatNumber(q.Max, metric)
+	}
+	return text
+}
+
+var vulgarFractions = map[rune]float64{
+	'½': 1.0 / 2, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 1.0 / 4, '¾': 3.0 / 4,
+	'⅕': 1.0 / 5, '⅖': 2.0 / 5, '⅗': 3.0 / 5, '⅘': 4.0 / 5, '⅙': 1.0 / 6,
+	'⅚': 5.0 / 6, '⅛': 1.0 / 8, '⅜': 3.0 / 8, '⅝': 5.0 / 8, '⅞': 7.0 / 8,
+}
+
+// numberPattern matches, in order of preference: a mixed number ("1 1/2"),
+// a fraction ("3/4"), a whole number followed by a vulgar fraction ("1½")
+// and a decimal ("0.5" or "0,5").
+var numberPattern = regexp.MustCompile(`^(?:(\d+)\s+(\d+)/(\d+)|(\d+)/(\d+)|(\d*)\s*([½⅓⅔¼¾⅕⅖⅗⅘⅙⅚⅛⅜⅝⅞])|(\d+(?:[.,]\d+)?))`)
+
+// rangePattern matches the separator of a range such as "2-3" or "2 to 3".
+var rangePattern = regexp.MustCompile(`^\s*(?:-|–|to\s)\s*`)
+
+// parseNumber reads a number from the start of s and returns the rest.
+func parseNumber(s string) (float64, string, bool) {
+	m := numberPattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, s, false
+	}
+	atoi := func(v string) float64 {
+		n, _ := strconv.Atoi(v)
+		return float64(n)
+	}
+
+	var value float64
+	switch {
+	case m[1] != "":
+		if atoi(m[3]) == 0 {
+			return 0, s, false
+		}
+		value = atoi(m[1]) + atoi(m[2])/atoi(m[3])
+	case m[4] != "":
+		if atoi(m[5]) == 0 {
+			return 0, s, false
+		}
+		value = atoi(m[4]) / atoi(m[5])
+	case m[7] != "":
+		value = atoi(m[6]) + vulgarFractions[[]rune(m[7])[0]]
+	default:
+		value, _ = strconv.ParseFloat(strings.Replace(m[8], ",", ".", 1), 64)
+	}
+	return value, s[len(m[0]):], true
+}
+
+// parseQuantity reads a number or a range from the start of s.
+func parseQuantity(s string) (Quantity, string, bool) {
+	value, rest, ok := parseNumber(s)
+	if !ok {
+		return Quantity{}, s, false
+	}
+	q := Quantity{Value: value}
+	if sep := rangePattern.FindString(rest); sep != "" {
+		if max, after, ok := parseNumber(rest[len(sep):]); ok && max > value {
+			q.Max = max
+			rest = after
+		}
+	}
+	return q, rest, true
+}
+
+// fractionDenominators are tried in order, so the first match is the
+// simplest fraction.
+var fractionDenominators = []int{2, 3, 4, 8}
+
+// formatNumber writes v as a whole number, a mixed fraction or, when no
+// common fraction is close enough or the unit is metric, a decimal.
+func formatNumber(v float64, metric bool) string {
+	if metric {
+		if v >= 10 {
+			return strconv.FormatFloat(math.Round(v), 'f', -1, 64)
+		}
+		if v >= 1 {
+			return strconv.FormatFloat(math.Round(v*10)/10, 'f', -1, 64)
+		}
+		return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+	}
+
+	whole := math.Floor(v)
+	fraction := v - whole
+	if fraction < 0.01 {
+		return strconv.FormatFloat(whole, 'f', -1, 64)
+	}
+	if fraction > 0.99 {
+		return strconv.FormatFloat(whole+1, 'f', -1, 64)
+	}
+	for _, denominator := range fractionDenominators {
+		numerator := math.Round(fraction * float64(denominator))
+		if math.Abs(fraction-numerator/float64(denominator)) < 0.01 {
+			text := strconv.Itoa(int(numerator)) + "/" + strconv.Itoa(denominator)
+			if whole == 0 {
+				return text
+			}
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + text
+		}
+	}
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
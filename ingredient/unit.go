@@ -0,0 +1,102 @@
+package ingredient
+
+import "strings"
+
+// unit describes how a unit of measure is written.
+type unit struct {
+	singular string
+	plural   string
+	// metric units are written as symbols with decimal quantities.
+	metric bool
+}
+
+// units maps the canonical unit names stored in Ingredient.Unit to their
+// spelling.
+var units = map[string]unit{
+	"tsp":     {singular: "teaspoon", plural: "teaspoons"},
+	"tbsp":    {singular: "tablespoon", plural: "tablespoons"},
+	"cup":     {singular: "cup", plural: "cups"},
+	"fl oz":   {singular: "fl oz", plural: "fl oz"},
+	"pt":      {singular: "pint", plural: "pints"},
+	"qt":      {singular: "quart", plural: "quarts"},
+	"gal":     {singular: "gallon", plural: "gallons"},
+	"oz":      {singular: "oz", plural: "oz"},
+	"lb":      {singular: "lb", plural: "lb"},
+	"ml":      {singular: "ml", plural: "ml", metric: true},
+	"l":       {singular: "l", plural: "l", metric: true},
+	"g":       {singular: "g", plural: "g", metric: true},
+	"kg":      {singular: "kg", plural: "kg", metric: true},
+	"pinch":   {singular: "pinch", plural: "pinches"},
+	"dash":    {singular: "dash", plural: "dashes"},
+	"clove":   {singular: "clove", plural: "cloves"},
+	"package": {singular: "package", plural: "packages"},
+	"can":     {singular: "can", plural: "cans"},
+	"slice":   {singular: "slice", plural: "slices"},
+	"stick":   {singular: "stick", plural: "sticks"},
+	"bunch":   {singular: "bunch", plural: "bunches"},
+	"sprig":   {singular: "sprig", plural: "sprigs"},
+}
+
+// unitAliases maps the lower-cased ways of writing a unit to its canonical
+// name. Aliases that depend on case are in caseSensitiveAliases.
+var unitAliases = map[string]string{
+	"fluid ounce": "fl oz", "fluid ounces": "fl oz", "fl oz": "fl oz", "fl. oz": "fl oz",
+	"millilitre": "ml", "millilitres": "ml", "milliliter": "ml", "milliliters": "ml", "ml": "ml",
+	"litre": "l", "litres": "l", "liter": "l", "liters": "l", "l": "l",
+	"gram": "g", "grams": "g", "gr": "g", "g": "g",
+	"kilogram": "kg", "kilograms": "kg", "kilo": "kg", "kilos": "kg", "kg": "kg",
+	"ounce": "oz", "ounces": "oz", "oz": "oz",
+	"pound": "lb", "pounds": "lb", "lb": "lb", "lbs": "lb",
+	"pint": "pt", "pints": "pt", "pt": "pt",
+	"quart": "qt", "quarts": "qt", "qt": "qt",
+	"gallon": "gal", "gallons": "gal", "gal": "gal",
+	"teaspoon": "tsp", "teaspoons": "tsp", "tsp": "tsp", "tsps": "tsp",
+	"tablespoon": "tbsp", "tablespoons": "tbsp", "tbsp": "tbsp", "tbsps": "tbsp", "tbs": "tbsp",
+	"cup": "cup", "cups": "cup",
+	"pinch": "pinch", "pinches": "pinch",
+	"dash": "dash", "dashes": "dash",
+	"clove": "clove", "cloves": "clove",
+	"package": "package", "packages": "package", "packet": "package", "packets": "package", "pkg": "package",
+	"can": "can", "cans": "can",
+	"slice": "slice", "slices": "slice",
+	"stick": "stick", "sticks": "stick",
+	"bunch": "bunch", "bunches": "bunch",
+	"sprig": "sprig", "sprigs": "sprig",
+}
+
+// caseSensitiveAliases are the single letter abbreviations where case
+// tells tablespoons from teaspoons.
+var caseSensitiveAliases = map[string]string{
+	"T": "tbsp",
+	"t": "tsp",
+	"c": "cup",
+	"C": "cup",
+}
+
+// lookupUnit returns the canonical name of a unit as written in a recipe,
+// ignoring a trailing period.
+func lookupUnit(word string) (string, bool) {
+	word = strings.TrimSuffix(word, ".")
+	if name, ok := caseSensitiveAliases[word]; ok {
+		return name, true
+	}
+	name, ok := unitAliases[strings.ToLower(word)]
+	return name, ok
+}
+
+// unitName spells a canonical unit for a quantity.
+func unitName(name string, plural bool) string {
+	u, ok := units[name]
+	if !ok {
+		return name
+	}
+	if plural {
+		return u.plural
+	}
+	return u.singular
+}
+
+// IsMetric reports whether the canonical unit is metric.
+func IsMetric(name string) bool {
+	return units[name].metric
+}
@@ -2,86 +2,23 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-contrib/sessions"
-	redisStore "github.com/gin-contrib/sessions/redis"
-	"github.com/gin-gonic/gin"
-
+	"recipes-api/app"
+	"recipes-api/config"
 	_ "recipes-api/docs"
-	"recipes-api/handlers"
-
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-	"go.mongodb.org/mongo-driver/mongo/readpref"
-
-	_ "github.com/joho/godotenv/autoload"
-
-	redis "github.com/go-redis/redis"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
+	"recipes-api/recipeio"
 )
 
-var authHandler *handlers.AuthHandler
-var recipesHandler *handlers.RecipesHandler
-
-func init() {
-
-	ctx := context.Background()
-	client, errMongo := mongo.Connect(ctx, options.Client().ApplyURI(os.Getenv("MONGO_URI")))
-	if errMongo != nil {
-		log.Fatal("Error connection to MongoDB ")
-		return
-	} else {
-		log.Println("Connected to MongoDB")
-
-		users := map[string]string{
-			"admin":      "fCRmh4Q2J7Rseqkz",
-			"packt":      "RE4zfHB35VPtTkbT",
-			"mlabouardy": "L3nSFRcZzNQ67bcc",
-		}
-		collection := client.Database(os.Getenv(
-			"MONGO_DATABASE")).Collection("users")
-		h := sha256.New()
-		for username, password := range users {
-			collection.InsertOne(ctx, bson.M{
-				"username": username,
-				"password": string(h.Sum([]byte(password))),
-			})
-		}
-	}
-
-	if err := client.Ping(context.TODO(),
-		readpref.Primary()); err != nil {
-		log.Fatal(err)
-	}
-	collectionRecipes := client.Database(os.Getenv("MONGO_DATABASE")).Collection("recipes")
-
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "",
-		DB:       0,
-	})
-
-	status := redisClient.Ping()
-	fmt.Println(status)
-	recipesHandler = handlers.NewRecipesHandler(ctx, collectionRecipes, redisClient)
-
-	collectionUsers := client.Database(os.Getenv("MONGO_DATABASE")).Collection("users")
-	authHandler = handlers.NewAuthHandler(ctx, collectionUsers)
-}
-
-func cleanup() {
-	fmt.Println("cleanup")
-}
-
 // @title           Swagger Recipes with Mongo API
 // @version         1.0
 // @description     This is a sample server celler server.
@@ -98,41 +35,180 @@ func cleanup() {
 
 // @securityDefinitions.basic  BasicAuth
 
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		cleanup()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "serve":
+		err = serve(ctx, cfg)
+	case "import":
+		err = runImport(ctx, cfg, os.Args[2:])
+	case "export":
+		err = runExport(ctx, cfg, os.Args[2:])
+	case "dedupe-users":
+		err = runDedupeUsers(ctx, cfg)
+	case "rehash-passwords":
+		err = runRehashPasswords(ctx, cfg)
+	default:
+		err = fmt.Errorf("unknown command %q, use serve, import, export, dedupe-users or rehash-passwords", command)
+	}
+	if err != nil {
+		log.Printf("Exited with error: %v", err)
+		stop()
 		os.Exit(1)
-	}()
+	}
+}
+
+func serve(ctx context.Context, cfg config.Config) error {
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
 
-	router := gin.Default()
-	router.Use(cors.Default())
-	store, _ := redisStore.NewStore(10, "tcp", "localhost:6379", "", []byte("secret"))
-	router.Use(sessions.Sessions("recipes_api", store))
+	runErr := application.Run(ctx)
+	if err := errors.Join(runErr, closeApp(cfg, application)); err != nil {
+		return err
+	}
+	log.Println("Server stopped")
+	return nil
+}
 
-	router.GET("/recipes", recipesHandler.ListRecipesHandler)
+// runImport implements "import [-format f] [-mode m] file". A file of "-"
+// reads standard input.
+func runImport(ctx context.Context, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "json, ndjson or csv; defaults to the file extension")
+	mode := flags.String("mode", recipeio.ModeSkip, "skip keeps existing recipes, upsert replaces them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: import [-format json|ndjson|csv] [-mode skip|upsert] file")
+	}
+	path := flags.Arg(0)
 
-	router.POST("/signin", authHandler.SignInHandler)
-	router.POST("/refresh", authHandler.RefreshHandler)
-	router.POST("/signout", authHandler.SignOutHandler)
+	input := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
 
-	authorized := router.Group("/")
-	authorized.Use(authHandler.AuthMiddleware())
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(path), ".")
+		if *format == "jsonl" {
+			*format = recipeio.FormatNDJSON
+		}
+	}
+	name, err := recipeio.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if err := requireStoredData(cfg, "import"); err != nil {
+		return err
+	}
 
-	authorized.POST("/recipes", recipesHandler.CreateRecipeHandler)
-	authorized.PUT("/recipes/:id", recipesHandler.UpdateRecipesHandler)
-	authorized.DELETE("/recipes/:id", recipesHandler.DeleteRecipeHandler)
-	authorized.GET("/recipes/search", recipesHandler.SearchRecipesHandler)
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	report, importErr := application.Import(ctx, input, recipeio.ImportOptions{Format: name, Mode: *mode})
 
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	log.Printf("Imported %s: %d created, %d updated, %d skipped, %d failed",
+		path, report.Created, report.Updated, report.Skipped, report.Failed)
+	for _, rowErr := range report.Errors {
+		log.Printf("row %d %s: %s", rowErr.Row, rowErr.ID, rowErr.Error)
+	}
+	return errors.Join(importErr, closeApp(cfg, application))
+}
 
-	router.Run(":3000")
+// runExport implements "export [-format f] [-o file]", writing to standard
+// output by default.
+func runExport(ctx context.Context, cfg config.Config, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", recipeio.FormatJSON, "json, ndjson or csv")
+	output := flags.String("o", "-", "output file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	name, err := recipeio.ParseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if err := requireStoredData(cfg, "export"); err != nil {
+		return err
+	}
+
+	application, err := app.New(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	var exportErr error
+	if *output == "-" {
+		exportErr = application.Export(ctx, os.Stdout, name)
+	} else if file, err := os.Create(*output); err != nil {
+		exportErr = err
+	} else {
+		exportErr = errors.Join(application.Export(ctx, file, name), file.Close())
+	}
+	return errors.Join(exportErr, closeApp(cfg, application))
+}
+
+// requireStoredData rejects commands that work on the recipes of a running
+// server when the memory store is configured: the command would only see
+// a store of its own, discarded when it exits.
+func requireStoredData(cfg config.Config, command string) error {
+	if cfg.Store == config.StoreMemory {
+		return fmt.Errorf("%s needs the MongoDB store, the memory store only lives as long as the command", command)
+	}
+	return nil
+}
+
+// runDedupeUsers implements "dedupe-users", the one-off migration that
+// keeps the oldest account of every username and deletes the others.
+func runDedupeUsers(ctx context.Context, cfg config.Config) error {
+	removed, err := app.RemoveDuplicateUsers(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("Removed %d duplicate users", removed)
+	return nil
+}
+
+// runRehashPasswords implements "rehash-passwords", the one-off migration
+// that hashes the passwords still stored in the legacy SHA-256 scheme.
+func runRehashPasswords(ctx context.Context, cfg config.Config) error {
+	rehashed, err := app.RehashLegacyPasswords(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	log.Printf("Rehashed %d legacy passwords", rehashed)
+	return nil
+}
 
-	// router.RunTLS(":443", "certs/localhost.crt", "certs/localhost.key")
+func closeApp(cfg config.Config, application *app.App) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+	return application.Close(ctx)
 }
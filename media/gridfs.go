@@ -0,0 +1,87 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFSStorage keeps images in a MongoDB GridFS bucket.
+type GridFSStorage struct {
+	db     *mongo.Database
+	bucket string
+}
+
+// NewGridFSStorage returns a GridFSStorage using the named bucket of db.
+func NewGridFSStorage(db *mongo.Database, bucket string) *GridFSStorage {
+	return &GridFSStorage{db: db, bucket: bucket}
+}
+
+// open returns a Bucket bounded by the deadline of ctx. Buckets keep their
+// deadlines as mutable state, so one is made per operation rather than
+// shared between requests.
+func (s *GridFSStorage) open(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.db, options.GridFSBucket().SetName(s.bucket))
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+		bucket.SetWriteDeadline(deadline)
+	}
+	return bucket, nil
+}
+
+func (s *GridFSStorage) Save(ctx context.Context, name string, data []byte) error {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = bucket.UploadFromStream(name, bytes.NewReader(data))
+	return err
+}
+
+func (s *GridFSStorage) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := bucket.OpenDownloadStreamByName(name)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *GridFSStorage) Delete(ctx context.Context, name string) error {
+	bucket, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	cur, err := bucket.FindContext(ctx, bson.M{"filename": name})
+	if err != nil {
+		return err
+	}
+	var files []struct {
+		ID interface{} `bson:"_id"`
+	}
+	if err := cur.All(ctx, &files); err != nil {
+		return err
+	}
+	for _, file := range files {
+		err := bucket.DeleteContext(ctx, file.ID)
+		if err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sort"
+)
+
+var (
+	// ErrUnsupportedType is returned for uploads that are not JPEG, PNG or
+	// GIF images.
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+	// ErrInvalidImage is returned for uploads that claim to be images but
+	// cannot be decoded.
+	ErrInvalidImage = errors.New("image is corrupt or truncated")
+	// ErrTooManyPixels is returned for images whose decoded size exceeds
+	// MaxPixels.
+	ErrTooManyPixels = fmt.Errorf("image must not exceed %d pixels", MaxPixels)
+)
+
+// MaxPixels bounds the decoded size of an image. A small compressed file
+// can decode to gigabytes, so the upload size limit alone is not enough.
+// An image this large takes about 100 MB once decoded and as much again
+// converted to RGBA for resizing.
+const MaxPixels = 25_000_000
+
+// MaxConcurrent bounds how many images are decoded and resized at once,
+// which bounds the memory processing takes to MaxConcurrent times that of
+// the largest image. Further uploads wait for their turn.
+const MaxConcurrent = 2
+
+var processing = make(chan struct{}, MaxConcurrent)
+
+// ThumbnailSizes maps each thumbnail size to the length of its longer side
+// in pixels.
+var ThumbnailSizes = map[string]int{
+	"small":  200,
+	"medium": 600,
+}
+
+// extensions lists the accepted content types with the extension their
+// files are stored under.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// ContentType returns the content type of a file named by Process.
+func ContentType(name string) string {
+	for contentType, ext := range extensions {
+		if len(name) > len(ext) && name[len(name)-len(ext):] == ext {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
+
+// File is an encoded image ready to be stored.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Processed is an uploaded image with its thumbnails.
+type Processed struct {
+	ContentType string
+	Width       int
+	Height      int
+	Original    File
+	Thumbnails  map[string]File
+}
+
+// Files returns the original and every thumbnail, ordered by name.
+func (p Processed) Files() []File {
+	files := []File{p.Original}
+	for _, file := range p.Thumbnails {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+// Process checks that data is an image of an accepted type by sniffing its
+// content rather than trusting the client, and renders its thumbnails.
+// Files are named after id. The original is kept byte for byte; thumbnails
+// are JPEG for JPEG images and PNG otherwise, which keeps transparency.
+// Waiting for a processing slot ends with ctx.
+func Process(ctx context.Context, id string, data []byte) (Processed, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return Processed{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return Processed{}, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Processed{}, ErrTooManyPixels
+	}
+
+	select {
+	case processing <- struct{}{}:
+		defer func() { <-processing }()
+	case <-ctx.Done():
+		return Processed{}, ctx.Err()
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Processed{}, ErrInvalidImage
+	}
+	// Every thumbnail is resized from the same RGBA copy.
+	rgba := toRGBA(img)
+
+	processed := Processed{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Original:    File{Name: id + ext, Data: data},
+		Thumbnails:  make(map[string]File, len(ThumbnailSizes)),
+	}
+	for size, longest := range ThumbnailSizes {
+		w, h := fit(config.Width, config.Height, longest)
+		thumbnail := resize(rgba, w, h)
+
+		var buf bytes.Buffer
+		thumbExt := ".png"
+		if contentType == "image/jpeg" {
+			thumbExt = ".jpg"
+			err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, thumbnail)
+		}
+		if err != nil {
+			return Processed{}, fmt.Errorf("encoding %s thumbnail: %w", size, err)
+		}
+		processed.Thumbnails[size] = File{Name: id + "_" + size + thumbExt, Data: buf.Bytes()}
+	}
+	return processed, nil
+}
@@ -0,0 +1,107 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, longest int
+		wantW, wantH  int
+	}{
+		{100, 50, 200, 100, 50},
+		{1200, 800, 600, 600, 400},
+		{800, 1200, 600, 400, 600},
+		{3000, 2, 200, 200, 1},
+	}
+	for _, tt := range tests {
+		if w, h := fit(tt.w, tt.h, tt.longest); w != tt.wantW || h != tt.wantH {
+			t.Errorf("fit(%d, %d, %d) = %d×%d, want %d×%d", tt.w, tt.h, tt.longest, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	processed, err := Process(context.Background(), "abc", encodePNG(t, 800, 400))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if processed.ContentType != "image/png" || processed.Width != 800 || processed.Height != 400 {
+		t.Errorf("Process = %s %d×%d", processed.ContentType, processed.Width, processed.Height)
+	}
+	tests := []struct {
+		size string
+		name string
+		w, h int
+	}{
+		{"small", "abc_small.png", 200, 100},
+		{"medium", "abc_medium.png", 600, 300},
+	}
+	for _, tt := range tests {
+		file := processed.Thumbnails[tt.size]
+		if file.Name != tt.name {
+			t.Errorf("%s thumbnail named %q, want %q", tt.size, file.Name, tt.name)
+		}
+		config, err := png.DecodeConfig(bytes.NewReader(file.Data))
+		if err != nil || config.Width != tt.w || config.Height != tt.h {
+			t.Errorf("%s thumbnail is %d×%d (%v), want %d×%d", tt.size, config.Width, config.Height, err, tt.w, tt.h)
+		}
+	}
+	if len(processed.Files()) != 3 {
+		t.Errorf("Files() = %d files, want 3", len(processed.Files()))
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	data := encodePNG(t, 10, 10)
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("hello, world"), ErrUnsupportedType},
+		{"truncated", data[:len(data)/2], ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		if _, err := Process(context.Background(), "abc", tt.data); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestProcessWaitsForSlot(t *testing.T) {
+	for i := 0; i < MaxConcurrent; i++ {
+		processing <- struct{}{}
+	}
+	defer func() {
+		for i := 0; i < MaxConcurrent; i++ {
+			<-processing
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Process(ctx, "abc", encodePNG(t, 10, 10)); !errors.Is(err, context.Canceled) {
+		t.Errorf("Process with every slot taken = %v, want context.Canceled", err)
+	}
+}
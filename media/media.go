@@ -0,0 +1,37 @@
+// Package media stores recipe images and derives their thumbnails.
+package media
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// ErrNotFound is returned for files that do not exist.
+var ErrNotFound = errors.New("file not found")
+
+// Storage keeps image files by name. Files are written once and never
+// modified, so a name always refers to the same content.
+type Storage interface {
+	Save(ctx context.Context, name string, data []byte) error
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+	// Delete removes a file. Deleting a missing file is not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+// namePattern matches the file names this package hands out: an ObjectID
+// in hex, optionally followed by a thumbnail size, and an extension.
+var namePattern = regexp.MustCompile(`^[0-9a-f]{24}(_[a-z]+)?\.(jpg|png|gif)$`)
+
+// ValidName reports whether name could have been produced by Process. It
+// keeps names taken from URLs from reaching outside the storage.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// ImageID returns the hex ID of the image a valid file name belongs to,
+// which thumbnails share with their original.
+func ImageID(name string) string {
+	return name[:24]
+}
@@ -0,0 +1,77 @@
+package media
+
+import (
+	"image"
+	"image/draw"
+)
+
+// fit returns the dimensions of a w×h image scaled down so that neither
+// side exceeds longest, keeping the aspect ratio. Images that already fit
+// keep their size.
+func fit(w, h, longest int) (int, int) {
+	if w <= longest && h <= longest {
+		return w, h
+	}
+	if w >= h {
+		return longest, max(1, h*longest/w)
+	}
+	return max(1, w*longest/h), longest
+}
+
+// toRGBA returns src as an RGBA image with its origin at zero, copying it
+// unless it already is one. Resizing works on RGBA pixels directly, which
+// avoids an interface call per source pixel; draw.Draw has fast paths for
+// the decoders' image types.
+func toRGBA(src image.Image) *image.RGBA {
+	bounds := src.Bounds()
+	if rgba, ok := src.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resize scales src down to w×h. Each destination pixel is the average of
+// the source pixels it covers, which is slower than sampling but does not
+// alias fine detail such as text or herbs.
+func resize(rgba *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := span(y, h, sh)
+		for x := 0; x < w; x++ {
+			x0, x1 := span(x, w, sw)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0] = uint8(r / n)
+			p[1] = uint8(g / n)
+			p[2] = uint8(b / n)
+			p[3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span returns the range of source rows or columns covered by destination
+// index i when scaling n source pixels to size destination pixels.
+func span(i, size, n int) (int, int) {
+	from := i * n / size
+	to := (i + 1) * n / size
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
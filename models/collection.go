@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection is a named, ordered list of recipes kept by one user. Every
+// user has a Favourites collection, created on first use, which cannot be
+// renamed or deleted. Shared collections can be read by anyone.
+type Collection struct {
+	ID          primitive.ObjectID   `json:"id" bson:"_id"`
+	Owner       string               `json:"owner" bson:"owner"`
+	Name        string               `json:"name" bson:"name" binding:"required,notblank,max=100"`
+	Description string               `json:"description,omitempty" bson:"description,omitempty" binding:"max=1000"`
+	Shared      bool                 `json:"shared" bson:"shared"`
+	Favourites  bool                 `json:"favourites,omitempty" bson:"favourites,omitempty"`
+	RecipeIDs   []primitive.ObjectID `json:"recipeIds" bson:"recipeIds"`
+	CreatedAt   time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// FavouritesName is the name given to the Favourites collection.
+const FavouritesName = "Favourites"
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Image is a picture of a recipe. URL points at the image as uploaded and
+// Thumbnails at the scaled down copies by size name; both are relative to
+// the API root.
+type Image struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Width       int                `json:"width" bson:"width"`
+	Height      int                `json:"height" bson:"height"`
+	Size        int                `json:"size" bson:"size"`
+	URL         string             `json:"url" bson:"url"`
+	Thumbnails  map[string]string  `json:"thumbnails" bson:"thumbnails"`
+	UploadedBy  string             `json:"uploadedBy" bson:"uploadedBy"`
+	UploadedAt  time.Time          `json:"uploadedAt" bson:"uploadedAt"`
+	// Files names every stored file of the image, thumbnails included.
+	Files []string `json:"-" bson:"files"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DateLayout is the format of meal plan dates. Dates are kept as strings
+// so that they denote a calendar day regardless of time zones, and sort
+// chronologically.
+const DateLayout = "2006-01-02"
+
+// Meal slots of a day, in the order they are eaten.
+const (
+	SlotBreakfast = "breakfast"
+	SlotLunch     = "lunch"
+	SlotDinner    = "dinner"
+	SlotSnack     = "snack"
+)
+
+// MealSlots lists the slots in the order of a day.
+var MealSlots = []string{SlotBreakfast, SlotLunch, SlotDinner, SlotSnack}
+
+// MealPlanEntry plans a recipe for a meal. Servings defaults to those of
+// the recipe when zero.
+type MealPlanEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Owner     string             `json:"owner" bson:"owner"`
+	Date      string             `json:"date" bson:"date" binding:"required,datetime=2006-01-02"`
+	Slot      string             `json:"slot" bson:"slot" binding:"required,oneof=breakfast lunch dinner snack"`
+	RecipeID  primitive.ObjectID `json:"recipeId" bson:"recipeId" binding:"required"`
+	Servings  int                `json:"servings,omitempty" bson:"servings,omitempty" binding:"min=0,max=1000"`
+	Note      string             `json:"note,omitempty" bson:"note,omitempty" binding:"max=500"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
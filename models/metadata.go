@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+// Difficulty levels a recipe can be rated at.
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Difficulties and Courses list the values the binding tags of Recipe
+// accept.
+var (
+	Difficulties = []string{DifficultyEasy, DifficultyMedium, DifficultyHard}
+	Courses      = []string{"appetizer", "soup", "salad", "main", "side", "dessert", "breakfast", "snack", "drink"}
+)
+
+// MaxMinutes bounds the preparation, cooking and total times of a recipe.
+const MaxMinutes = 7 * 24 * 60
+
+// MetadataFields names the Recipe fields describing rather than making up
+// a recipe, for validating them on their own.
+var MetadataFields = []string{"PrepTime", "CookTime", "TotalTime", "Difficulty", "Cuisine", "Course", "Nutrition"}
+
+// Nutrition holds the nutrition facts of one serving. Calories are in
+// kcal, sodium in milligrams and the rest in grams. Facts that are not
+// known are left out rather than given as zero.
+type Nutrition struct {
+	Calories      *float64 `json:"calories,omitempty" bson:"calories,omitempty" binding:"omitempty,min=0,max=10000"`
+	Protein       *float64 `json:"protein,omitempty" bson:"protein,omitempty" binding:"omitempty,min=0,max=1000"`
+	Fat           *float64 `json:"fat,omitempty" bson:"fat,omitempty" binding:"omitempty,min=0,max=1000"`
+	SaturatedFat  *float64 `json:"saturatedFat,omitempty" bson:"saturatedFat,omitempty" binding:"omitempty,min=0,max=1000"`
+	Carbohydrates *float64 `json:"carbohydrates,omitempty" bson:"carbohydrates,omitempty" binding:"omitempty,min=0,max=1000"`
+	Sugar         *float64 `json:"sugar,omitempty" bson:"sugar,omitempty" binding:"omitempty,min=0,max=1000"`
+	Fiber         *float64 `json:"fiber,omitempty" bson:"fiber,omitempty" binding:"omitempty,min=0,max=1000"`
+	Sodium        *float64 `json:"sodium,omitempty" bson:"sodium,omitempty" binding:"omitempty,min=0,max=100000"`
+}
+
+// NormalizeMetadata lower-cases the cuisine and course so that they can be
+// matched exactly, and derives the total time from the preparation and
+// cooking times when it is not given.
+func (recipe *Recipe) NormalizeMetadata() {
+	recipe.Cuisine = strings.ToLower(strings.TrimSpace(recipe.Cuisine))
+	recipe.Course = strings.ToLower(strings.TrimSpace(recipe.Course))
+	if recipe.TotalTime == 0 {
+		recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	}
+	if recipe.Nutrition != nil && *recipe.Nutrition == (Nutrition{}) {
+		recipe.Nutrition = nil
+	}
+}
+
+// RegisterValidations adds the checks the binding tags of the models rely
+// on beyond the validator built-ins.
+func RegisterValidations(v *validator.Validate) {
+	v.RegisterValidation("notblank", validators.NotBlank)
+	v.RegisterStructValidation(validateRecipe, Recipe{})
+}
+
+// validateRecipe checks the rules spanning several fields of a recipe.
+func validateRecipe(sl validator.StructLevel) {
+	recipe := sl.Current().Interface().(Recipe)
+	if recipe.TotalTime > 0 && recipe.TotalTime < recipe.PrepTime+recipe.CookTime {
+		sl.ReportError(recipe.TotalTime, "totalTime", "TotalTime", "mintotaltime", "")
+	}
+}
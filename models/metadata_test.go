@@ -0,0 +1,110 @@
+package models
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestNormalizeMetadata(t *testing.T) {
+	zero := 0.0
+	tests := []struct {
+		name string
+		in   Recipe
+		want Recipe
+	}{
+		{"total time derived", Recipe{PrepTime: 10, CookTime: 20}, Recipe{PrepTime: 10, CookTime: 20, TotalTime: 30}},
+		{"total time kept", Recipe{PrepTime: 10, CookTime: 20, TotalTime: 90}, Recipe{PrepTime: 10, CookTime: 20, TotalTime: 90}},
+		{"cuisine and course lower-cased", Recipe{Cuisine: " Italian ", Course: "Main"}, Recipe{Cuisine: "italian", Course: "main"}},
+		{"empty nutrition dropped", Recipe{Nutrition: &Nutrition{}}, Recipe{}},
+		{"zero calories kept", Recipe{Nutrition: &Nutrition{Calories: &zero}}, Recipe{Nutrition: &Nutrition{Calories: &zero}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in
+			got.NormalizeMetadata()
+			if got.PrepTime != tt.want.PrepTime || got.CookTime != tt.want.CookTime || got.TotalTime != tt.want.TotalTime ||
+				got.Cuisine != tt.want.Cuisine || got.Course != tt.want.Course || (got.Nutrition == nil) != (tt.want.Nutrition == nil) {
+				t.Errorf("NormalizeMetadata(%+v) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecipeMetadataValidation(t *testing.T) {
+	validate := validator.New()
+	validate.SetTagName("binding")
+	RegisterValidations(validate)
+
+	calories := func(value float64) *Nutrition {
+		return &Nutrition{Calories: &value}
+	}
+	tests := []struct {
+		name   string
+		edit   func(*Recipe)
+		failed []string
+	}{
+		{"valid", func(r *Recipe) {}, nil},
+		{"every field", func(r *Recipe) {
+			r.PrepTime, r.CookTime, r.TotalTime = 15, 30, 60
+			r.Difficulty, r.Cuisine, r.Course = DifficultyHard, "thai", "main"
+			r.Nutrition = calories(450)
+		}, nil},
+		{"times above a week", func(r *Recipe) { r.PrepTime, r.CookTime = MaxMinutes+1, MaxMinutes+1 }, []string{"PrepTime", "CookTime"}},
+		{"negative time", func(r *Recipe) { r.CookTime = -5 }, []string{"CookTime"}},
+		{"total shorter than parts", func(r *Recipe) { r.PrepTime, r.CookTime, r.TotalTime = 30, 30, 45 }, []string{"TotalTime"}},
+		{"unknown difficulty", func(r *Recipe) { r.Difficulty = "extreme" }, []string{"Difficulty"}},
+		{"unknown course", func(r *Recipe) { r.Course = "starter" }, []string{"Course"}},
+		{"long cuisine", func(r *Recipe) { r.Cuisine = string(make([]byte, 51)) }, []string{"Cuisine"}},
+		{"negative calories", func(r *Recipe) { r.Nutrition = calories(-1) }, []string{"Calories"}},
+		{"too many calories", func(r *Recipe) { r.Nutrition = calories(10001) }, []string{"Calories"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipe := Recipe{Name: "Curry", Ingredients: []string{"1 onion"}}
+			tt.edit(&recipe)
+			var failed []string
+			if err := validate.Struct(recipe); err != nil {
+				invalid, ok := err.(validator.ValidationErrors)
+				if !ok {
+					t.Fatalf("Struct = %v", err)
+				}
+				for _, fe := range invalid {
+					failed = append(failed, fe.StructField())
+				}
+			}
+			if !slices.Equal(failed, tt.failed) {
+				t.Errorf("failed fields = %v, want %v", failed, tt.failed)
+			}
+		})
+	}
+}
+
+func TestDiffRecipesMetadata(t *testing.T) {
+	calories := 300.0
+	before := Recipe{Name: "Curry", PrepTime: 10, Difficulty: DifficultyEasy, Cuisine: "thai"}
+	tests := []struct {
+		name   string
+		edit   func(*Recipe)
+		fields []string
+	}{
+		{"unchanged", func(r *Recipe) {}, nil},
+		{"times", func(r *Recipe) { r.PrepTime, r.TotalTime = 20, 40 }, []string{"prepTime", "totalTime"}},
+		{"labels", func(r *Recipe) { r.Difficulty, r.Course = DifficultyMedium, "main" }, []string{"difficulty", "course"}},
+		{"nutrition", func(r *Recipe) { r.Nutrition = &Nutrition{Calories: &calories} }, []string{"nutrition"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := before
+			tt.edit(&after)
+			var fields []string
+			for _, change := range DiffRecipes(before, after) {
+				fields = append(fields, change.Field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("changed fields = %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"recipes-api/ingredient"
+	"recipes-api/units"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -8,9 +10,123 @@ import (
 
 type Recipe struct {
 	ID           primitive.ObjectID `json:"id" bson:"_id"`
-	Name         string             `json:"name" bson:"name"`
-	Tags         []string           `json:"tags" bson:"tags"`
-	Ingredients  []string           `json:"ingredients" bson:"ingredients"`
-	Instructions []string           `json:"instructions" bson:"instructions"`
-	PublishedAt  time.Time          `json:"publishedAt" bson:"publishedAt"`
+	Name         string             `json:"name" bson:"name" binding:"required,notblank,max=200"`
+	Tags         []string           `json:"tags" bson:"tags" binding:"max=20,dive,notblank,max=50"`
+	Ingredients  []string           `json:"ingredients" bson:"ingredients" binding:"required_without=IngredientDetails,omitempty,min=1,max=100,dive,notblank,max=500"`
+	Instructions []string           `json:"instructions" bson:"instructions" binding:"max=100,dive,notblank,max=2000"`
+	// PublishedAt is the time the recipe was last published; it is zero
+	// while the recipe has never been.
+	PublishedAt time.Time `json:"publishedAt" bson:"publishedAt"`
+	Author      string    `json:"author" bson:"author"`
+	// Status is one of draft, in_review, published or archived. It is
+	// changed through POST /recipes/{id}/status only.
+	Status string `json:"status" bson:"status"`
+	// PublishAt is the time a recipe in review has been scheduled to be
+	// published at.
+	PublishAt *time.Time `json:"publishAt,omitempty" bson:"publishAt,omitempty"`
+	// Servings is the number of portions the quantities are given for.
+	Servings int `json:"servings,omitempty" bson:"servings,omitempty" binding:"min=0,max=1000"`
+	// PrepTime, CookTime and TotalTime are in minutes. TotalTime may exceed
+	// the other two combined, e.g. for resting or chilling.
+	PrepTime   int    `json:"prepTime,omitempty" bson:"prepTime,omitempty" binding:"min=0,max=10080"`
+	CookTime   int    `json:"cookTime,omitempty" bson:"cookTime,omitempty" binding:"min=0,max=10080"`
+	TotalTime  int    `json:"totalTime,omitempty" bson:"totalTime,omitempty" binding:"min=0,max=10080"`
+	Difficulty string `json:"difficulty,omitempty" bson:"difficulty,omitempty" binding:"omitempty,oneof=easy medium hard"`
+	Cuisine    string `json:"cuisine,omitempty" bson:"cuisine,omitempty" binding:"max=50"`
+	// Course is one of appetizer, soup, salad, main, side, dessert,
+	// breakfast, snack or drink.
+	Course    string     `json:"course,omitempty" bson:"course,omitempty" binding:"omitempty,oneof=appetizer soup salad main side dessert breakfast snack drink"`
+	Nutrition *Nutrition `json:"nutrition,omitempty" bson:"nutrition,omitempty"`
+	Images    []Image    `json:"images,omitempty" bson:"images,omitempty"`
+	// Rating is maintained from the reviews of the recipe; it is ignored
+	// in requests.
+	Rating Rating `json:"rating" bson:"rating"`
+	// DeletedAt is set while the recipe is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+	// IngredientDetails is the structured form of Ingredients. It is
+	// derived from the ingredient lines rather than stored; clients may send
+	// it instead of Ingredients.
+	IngredientDetails []ingredient.Ingredient `json:"ingredientDetails,omitempty" bson:"-" binding:"omitempty,min=1,max=100"`
+}
+
+// NormalizeIngredients fills Ingredients from IngredientDetails when only
+// the structured form was given, and drops the structured form, which is
+// not stored.
+func (recipe *Recipe) NormalizeIngredients() {
+	if len(recipe.Ingredients) == 0 && len(recipe.IngredientDetails) > 0 {
+		recipe.Ingredients = make([]string, len(recipe.IngredientDetails))
+		for i, detail := range recipe.IngredientDetails {
+			recipe.Ingredients[i] = detail.String()
+		}
+	}
+	recipe.IngredientDetails = nil
+}
+
+// SetEditable copies the fields clients edit, which are the ones a store
+// update writes, from edited.
+func (recipe *Recipe) SetEditable(edited Recipe) {
+	recipe.Name = edited.Name
+	recipe.Instructions = edited.Instructions
+	recipe.Ingredients = edited.Ingredients
+	recipe.Tags = edited.Tags
+	recipe.Servings = edited.Servings
+	recipe.PrepTime = edited.PrepTime
+	recipe.CookTime = edited.CookTime
+	recipe.TotalTime = edited.TotalTime
+	recipe.Difficulty = edited.Difficulty
+	recipe.Cuisine = edited.Cuisine
+	recipe.Course = edited.Course
+	recipe.Nutrition = edited.Nutrition
+}
+
+// WithIngredientDetails returns the recipe with IngredientDetails parsed
+// from its ingredient lines.
+func (recipe Recipe) WithIngredientDetails() Recipe {
+	recipe.IngredientDetails = ingredient.ParseAll(recipe.Ingredients)
+	return recipe
+}
+
+// Scale returns the recipe with ingredient quantities adjusted from
+// Servings to servings. The recipe must declare Servings.
+func (recipe Recipe) Scale(servings int) Recipe {
+	factor := float64(servings) / float64(recipe.Servings)
+	details := ingredient.ParseAll(recipe.Ingredients)
+	recipe.Ingredients = make([]string, len(details))
+	for i, detail := range details {
+		details[i] = detail.Scale(factor)
+		recipe.Ingredients[i] = details[i].Text
+	}
+	recipe.IngredientDetails = details
+	recipe.Servings = servings
+	return recipe
+}
+
+// ConvertUnits returns the recipe with ingredient quantities and the
+// temperatures in its instructions expressed in system. IngredientDetails
+// is converted too when present.
+func (recipe Recipe) ConvertUnits(system units.System) Recipe {
+	details := recipe.IngredientDetails
+	if details == nil {
+		details = ingredient.ParseAll(recipe.Ingredients)
+	}
+	if len(details) > 0 {
+		converted := make([]ingredient.Ingredient, len(details))
+		recipe.Ingredients = make([]string, len(details))
+		for i, detail := range details {
+			converted[i] = units.ConvertIngredient(detail, system)
+			recipe.Ingredients[i] = converted[i].Text
+		}
+		if recipe.IngredientDetails != nil {
+			recipe.IngredientDetails = converted
+		}
+	}
+
+	if recipe.Instructions != nil {
+		instructions := make([]string, len(recipe.Instructions))
+		for i, instruction := range recipe.Instructions {
+			instructions[i] = units.ConvertTemperatures(instruction, system)
+		}
+		recipe.Instructions = instructions
+	}
+	return recipe
 }
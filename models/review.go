@@ -0,0 +1,48 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Review is the rating and opinion of one user on a recipe. Each user has
+// at most one review per recipe; posting again replaces it.
+type Review struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	RecipeID  primitive.ObjectID `json:"recipeId" bson:"recipeId"`
+	Author    string             `json:"author" bson:"author"`
+	Rating    int                `json:"rating" bson:"rating" binding:"required,min=1,max=5"`
+	Text      string             `json:"text" bson:"text" binding:"max=5000"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Rating aggregates the reviews of a recipe. Average is rounded to two
+// decimals and zero while there are no reviews.
+type Rating struct {
+	Average float64 `json:"average" bson:"average"`
+	Count   int     `json:"count" bson:"count"`
+	// Sum is the total of every star given, from which Average is derived.
+	Sum int `json:"-" bson:"sum"`
+	// ReviewedAt is the time the newest review counted was written. A
+	// rating is only replaced by one computed from reviews at least as new.
+	ReviewedAt time.Time `json:"-" bson:"reviewedAt,omitempty"`
+}
+
+// RatingOf computes the rating of a recipe from all of its reviews.
+func RatingOf(reviews []Review) Rating {
+	var r Rating
+	for _, review := range reviews {
+		r.Sum += review.Rating
+		r.Count++
+		if review.UpdatedAt.After(r.ReviewedAt) {
+			r.ReviewedAt = review.UpdatedAt
+		}
+	}
+	if r.Count > 0 {
+		r.Average = math.Round(float64(r.Sum)/float64(r.Count)*100) / 100
+	}
+	return r
+}
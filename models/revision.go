@@ -0,0 +1,95 @@
+package models
+
+import (
+	"reflect"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionRevert  RevisionAction = "revert"
+	RevisionImport  RevisionAction = "import"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+	// RevisionStatus records a change of status. Scheduled publications
+	// are recorded without an author.
+	RevisionStatus RevisionAction = "status"
+)
+
+// FieldChange records the old and new value of one recipe field.
+type FieldChange struct {
+	Field string      `json:"field" bson:"field"`
+	From  interface{} `json:"from" bson:"from"`
+	To    interface{} `json:"to" bson:"to"`
+}
+
+// Revision is one saved state of a recipe. Numbers start at 1 and grow by
+// one with every change. Recipe holds the full state after the change and
+// Changes the difference to the previous revision.
+type Revision struct {
+	ID           primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	RecipeID     primitive.ObjectID `json:"recipeId" bson:"recipeId"`
+	Number       int                `json:"rev" bson:"rev"`
+	Action       RevisionAction     `json:"action" bson:"action"`
+	Author       string             `json:"author" bson:"author"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	RevertedFrom int                `json:"revertedFrom,omitempty" bson:"revertedFrom,omitempty"`
+	Changes      []FieldChange      `json:"changes" bson:"changes"`
+	Recipe       *Recipe            `json:"recipe,omitempty" bson:"recipe,omitempty"`
+}
+
+// DiffRecipes lists the editable fields that differ between two states of
+// a recipe.
+func DiffRecipes(before, after Recipe) []FieldChange {
+	changes := make([]FieldChange, 0)
+	if before.Name != after.Name {
+		changes = append(changes, FieldChange{Field: "name", From: before.Name, To: after.Name})
+	}
+	for _, field := range []struct {
+		name          string
+		before, after []string
+	}{
+		{"tags", before.Tags, after.Tags},
+		{"ingredients", before.Ingredients, after.Ingredients},
+		{"instructions", before.Instructions, after.Instructions},
+	} {
+		if !slices.Equal(field.before, field.after) {
+			changes = append(changes, FieldChange{Field: field.name, From: field.before, To: field.after})
+		}
+	}
+	for _, field := range []struct {
+		name          string
+		before, after int
+	}{
+		{"servings", before.Servings, after.Servings},
+		{"prepTime", before.PrepTime, after.PrepTime},
+		{"cookTime", before.CookTime, after.CookTime},
+		{"totalTime", before.TotalTime, after.TotalTime},
+	} {
+		if field.before != field.after {
+			changes = append(changes, FieldChange{Field: field.name, From: field.before, To: field.after})
+		}
+	}
+	for _, field := range []struct {
+		name          string
+		before, after string
+	}{
+		{"difficulty", before.Difficulty, after.Difficulty},
+		{"cuisine", before.Cuisine, after.Cuisine},
+		{"course", before.Course, after.Course},
+	} {
+		if field.before != field.after {
+			changes = append(changes, FieldChange{Field: field.name, From: field.before, To: field.after})
+		}
+	}
+	if !reflect.DeepEqual(before.Nutrition, after.Nutrition) {
+		changes = append(changes, FieldChange{Field: "nutrition", From: before.Nutrition, To: after.Nutrition})
+	}
+	return changes
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Publication states of a recipe. Only published recipes are public; the
+// others are seen by their author and by editors alone.
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Statuses lists every publication state.
+var Statuses = []string{StatusDraft, StatusInReview, StatusPublished, StatusArchived}
+
+// StatusChange is a request to move a recipe to another status. PublishAt
+// schedules a publication: a recipe approved for a later time waits in
+// review until then.
+type StatusChange struct {
+	Status    string     `json:"status" binding:"required,oneof=draft in_review published archived"`
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+}
+
+// CanTransition reports whether a user may move a recipe from one status
+// to another. Editors may make any change. Authors may submit their own
+// drafts for review, withdraw them, archive their recipes and take them
+// out of the archive again as drafts; publishing is left to editors.
+func CanTransition(from, to string, owner, editor bool) bool {
+	if from == to {
+		return false
+	}
+	if editor {
+		return true
+	}
+	if !owner {
+		return false
+	}
+	switch to {
+	case StatusInReview:
+		return from == StatusDraft
+	case StatusDraft:
+		return from == StatusInReview || from == StatusArchived
+	case StatusArchived:
+		return true
+	}
+	return false
+}
+
+// Published reports whether the recipe is visible to everyone.
+func (recipe Recipe) Published() bool {
+	return recipe.Status == StatusPublished
+}
+
+// VisibleTo reports whether user may see the recipe: published recipes are
+// public, the others are shown to their author and to editors only.
+func (recipe Recipe) VisibleTo(user User) bool {
+	if recipe.Published() {
+		return true
+	}
+	return user.Username != "" && (recipe.Author == user.Username || user.Can(RoleEditor))
+}
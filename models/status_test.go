@@ -0,0 +1,69 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to      string
+		owner, editor bool
+		want          bool
+	}{
+		// Authors move their own recipes between draft, review and archive.
+		{StatusDraft, StatusInReview, true, false, true},
+		{StatusInReview, StatusDraft, true, false, true},
+		{StatusArchived, StatusDraft, true, false, true},
+		{StatusDraft, StatusArchived, true, false, true},
+		{StatusPublished, StatusArchived, true, false, true},
+		{StatusInReview, StatusArchived, true, false, true},
+		// Publishing and unpublishing are left to editors.
+		{StatusInReview, StatusPublished, true, false, false},
+		{StatusDraft, StatusPublished, true, false, false},
+		{StatusPublished, StatusDraft, true, false, false},
+		{StatusPublished, StatusInReview, true, false, false},
+		{StatusArchived, StatusInReview, true, false, false},
+		// Other users' recipes are out of reach.
+		{StatusDraft, StatusInReview, false, false, false},
+		{StatusPublished, StatusArchived, false, false, false},
+		// Editors make any change, to anyone's recipe.
+		{StatusInReview, StatusPublished, false, true, true},
+		{StatusDraft, StatusPublished, false, true, true},
+		{StatusPublished, StatusDraft, true, true, true},
+		{StatusArchived, StatusPublished, false, true, true},
+		// Nothing moves a recipe to the status it is in.
+		{StatusDraft, StatusDraft, true, false, false},
+		{StatusPublished, StatusPublished, false, true, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to, tt.owner, tt.editor); got != tt.want {
+			t.Errorf("CanTransition(%s, %s, owner %t, editor %t) = %t, want %t",
+				tt.from, tt.to, tt.owner, tt.editor, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	author := User{Username: "alice", Roles: []string{RoleAuthor}}
+	other := User{Username: "bob", Roles: []string{RoleAuthor}}
+	editor := User{Username: "erin", Roles: []string{RoleEditor}}
+	tests := []struct {
+		status string
+		user   User
+		want   bool
+	}{
+		{StatusPublished, User{}, true},
+		{StatusPublished, other, true},
+		{StatusDraft, author, true},
+		{StatusDraft, editor, true},
+		{StatusDraft, other, false},
+		{StatusDraft, User{}, false},
+		{StatusInReview, other, false},
+		{StatusArchived, author, true},
+		{StatusArchived, other, false},
+	}
+	for _, tt := range tests {
+		recipe := Recipe{Author: "alice", Status: tt.status}
+		if got := recipe.VisibleTo(tt.user); got != tt.want {
+			t.Errorf("%s recipe VisibleTo(%q) = %t, want %t", tt.status, tt.user.Username, got, tt.want)
+		}
+	}
+}
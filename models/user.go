@@ -1,6 +1,65 @@
 package models
 
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Roles are ordered: each role grants everything the previous one does.
+const (
+	RoleViewer = "viewer"
+	RoleAuthor = "author"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleAuthor: 2,
+	RoleEditor: 3,
+	RoleAdmin:  4,
+}
+
+// DefaultRoles are granted to accounts created through signup.
+var DefaultRoles = []string{RoleAuthor}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
 type User struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username  string             `json:"username" bson:"username" binding:"required,min=3,max=32"`
+	Password  string             `json:"password,omitempty" bson:"password" binding:"required,max=72"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	Disabled  bool               `json:"disabled" bson:"disabled"`
+	Roles     []string           `json:"roles" bson:"roles"`
+	// TokenVersion is bumped whenever existing sessions and tokens of the
+	// user must stop working, e.g. after a password change.
+	TokenVersion int `json:"-" bson:"tokenVersion"`
+	// DeletedAt marks a deleted account. The record is kept so that its
+	// username cannot be registered again and inherit its authorship.
+	DeletedAt *time.Time `json:"-" bson:"deletedAt,omitempty"`
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the user holds role or a role above it. A user
+// without roles, such as the anonymous caller, can do nothing.
+func (u User) Can(role string) bool {
+	for _, r := range u.Roles {
+		if roleRank[r] >= roleRank[role] {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,30 @@
+package models
+
+import "testing"
+
+func TestUserCan(t *testing.T) {
+	tests := []struct {
+		roles []string
+		role  string
+		want  bool
+	}{
+		{[]string{RoleViewer}, RoleViewer, true},
+		{[]string{RoleViewer}, RoleAuthor, false},
+		{[]string{RoleAuthor}, RoleViewer, true},
+		{[]string{RoleAuthor}, RoleEditor, false},
+		{[]string{RoleEditor}, RoleAuthor, true},
+		{[]string{RoleEditor}, RoleAdmin, false},
+		{[]string{RoleAdmin}, RoleEditor, true},
+		// The highest role held counts.
+		{[]string{RoleViewer, RoleEditor}, RoleAuthor, true},
+		// Without roles, as when nobody is logged in, nothing is allowed.
+		{nil, RoleViewer, false},
+		{nil, RoleAuthor, false},
+		{[]string{"chef"}, RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := (User{Roles: tt.roles}).Can(tt.role); got != tt.want {
+			t.Errorf("User with %v Can(%s) = %t, want %t", tt.roles, tt.role, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package password hashes and verifies user passwords.
+//
+// Hashes are stored in self-describing encodings (bcrypt's modular crypt
+// format and the PHC string format for argon2id), so any configured Hasher
+// can verify a hash produced by another one and report that it should be
+// upgraded. Hashes written by earlier releases of the API are recognised as
+// the legacy scheme and are always reported as needing a rehash.
+package password
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var ErrUnknownScheme = errors.New("password: unknown hash scheme")
+
+// Hasher produces password hashes for storage and checks candidates
+// against them.
+type Hasher interface {
+	// Hash returns the encoded hash of password using a fresh salt.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded. The comparison is
+	// constant time with respect to the hash contents.
+	Verify(encoded, password string) (bool, error)
+	// NeedsRehash reports whether encoded was produced by another scheme
+	// or with weaker parameters than the hasher currently uses.
+	NeedsRehash(encoded string) bool
+}
+
+// New returns the hasher for the named algorithm with default parameters.
+func New(algorithm string) (Hasher, error) {
+	switch algorithm {
+	case "", Bcrypt:
+		return NewBcryptHasher(0), nil
+	case Argon2id:
+		return NewArgon2idHasher(), nil
+	default:
+		return nil, fmt.Errorf("password: unsupported algorithm %q", algorithm)
+	}
+}
+
+// verify dispatches on the scheme of encoded.
+func verify(encoded, password string) (bool, error) {
+	switch {
+	case isLegacy(encoded):
+		return verifyLegacy(encoded, password), nil
+	case strings.HasPrefix(encoded, argon2idPrefix):
+		return verifyArgon2id(encoded, password)
+	case isBcrypt(encoded):
+		return verifyBcrypt(encoded, password)
+	default:
+		return false, ErrUnknownScheme
+	}
+}
+
+// legacySuffix is the SHA-256 digest of the empty input. The legacy scheme
+// computed sha256.New().Sum([]byte(password)), which appends that digest to
+// the plaintext instead of hashing it.
+var legacySuffix = string(sha256.New().Sum(nil))
+
+func isLegacy(encoded string) bool {
+	return strings.HasSuffix(encoded, legacySuffix)
+}
+
+// LegacyPassword returns the password a legacy hash holds in the clear,
+// so that it can be hashed properly without waiting for the user to sign
+// in. ok is false for hashes of other schemes.
+func LegacyPassword(encoded string) (password string, ok bool) {
+	if !isLegacy(encoded) {
+		return "", false
+	}
+	return strings.TrimSuffix(encoded, legacySuffix), true
+}
+
+func verifyLegacy(encoded, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(encoded), []byte(password+legacySuffix)) == 1
+}
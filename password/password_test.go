@@ -0,0 +1,106 @@
+package password
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+// legacyHash reproduces how earlier releases stored passwords.
+func legacyHash(password string) string {
+	return string(sha256.New().Sum([]byte(password)))
+}
+
+func TestVerify(t *testing.T) {
+	fast := NewArgon2idHasher()
+	fast.Time, fast.Memory = 1, 1024
+	argon, err := fast.Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := NewBcryptHasher(4).Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     bool
+		err      error
+	}{
+		{"legacy", legacyHash("s3cret"), "s3cret", true, nil},
+		{"legacy wrong password", legacyHash("s3cret"), "s3cre", false, nil},
+		{"legacy empty password", legacyHash(""), "", true, nil},
+		{"legacy prefix of hash", legacyHash("s3cret"), "s3cret" + legacySuffix, false, nil},
+		{"argon2id", argon, "s3cret", true, nil},
+		{"argon2id wrong password", argon, "secret", false, nil},
+		{"bcrypt", bcryptHash, "s3cret", true, nil},
+		{"bcrypt wrong password", bcryptHash, "secret", false, nil},
+		{"unknown scheme", "plaintext", "plaintext", false, ErrUnknownScheme},
+		{"malformed argon2id", "$argon2id$v=19$m=x$salt$key", "s3cret", false, errInvalidArgon2id},
+	}
+	for _, tt := range tests {
+		got, err := verify(tt.encoded, tt.password)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak, err := NewBcryptHasher(4).Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strong := NewBcryptHasher(4)
+	current, err := strong.Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		hasher  Hasher
+		encoded string
+		want    bool
+	}{
+		{"legacy", strong, legacyHash("s3cret"), true},
+		{"current bcrypt", strong, current, false},
+		{"lower bcrypt cost", NewBcryptHasher(5), weak, true},
+		{"bcrypt under argon2id", NewArgon2idHasher(), current, true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.encoded); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLegacyPassword(t *testing.T) {
+	bcrypt, err := NewBcryptHasher(4).Hash("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		encoded string
+		want    string
+		ok      bool
+	}{
+		{"legacy", legacyHash("s3cret"), "s3cret", true},
+		{"legacy empty password", legacyHash(""), "", true},
+		{"bcrypt", bcrypt, "", false},
+		{"unknown", "plaintext", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := LegacyPassword(tt.encoded)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: LegacyPassword = %q, %t; want %q, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
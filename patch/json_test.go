@@ -0,0 +1,91 @@
+package patch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Mostly the examples of RFC 6902, appendix A.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, nil},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, nil},
+		{"append", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, nil},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, nil},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, nil},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, nil},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`, nil},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`, nil},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`,
+			`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, nil},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"remove","path":"/m~0n"}]`, `{}`, nil},
+		{"failed test", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ``, ErrTestFailed},
+		{"missing member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ``, nil},
+		{"index out of range", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, ``, nil},
+		{"leading zero index", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, ``, nil},
+		{"move into itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ``, nil},
+	}
+	for _, tt := range tests {
+		ops, err := DecodeJSONPatch([]byte(tt.patch))
+		if err != nil {
+			t.Errorf("%s: DecodeJSONPatch: %v", tt.name, err)
+			continue
+		}
+		doc := decodeJSON(t, tt.doc)
+		got, err := Apply(doc, ops)
+		if !reflect.DeepEqual(doc, decodeJSON(t, tt.doc)) {
+			t.Errorf("%s: Apply modified the document", tt.name)
+		}
+		if tt.want == "" {
+			var patchErr *Error
+			if !errors.As(err, &patchErr) {
+				t.Errorf("%s: error = %v, want a *patch.Error", tt.name, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Apply = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		ok    bool
+	}{
+		{"valid", `[{"op":"remove","path":"/a"}]`, true},
+		{"explicit null value", `[{"op":"add","path":"/a","value":null}]`, true},
+		{"not an array", `{"op":"remove","path":"/a"}`, false},
+		{"unknown op", `[{"op":"frobnicate","path":"/a"}]`, false},
+		{"missing value", `[{"op":"add","path":"/a"}]`, false},
+		{"relative path", `[{"op":"remove","path":"a"}]`, false},
+		{"relative from", `[{"op":"copy","from":"a","path":"/b"}]`, false},
+		{"unknown member", `[{"op":"remove","path":"/a","extra":1}]`, false},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeJSONPatch([]byte(tt.patch)); (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
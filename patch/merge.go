@@ -0,0 +1,27 @@
+// Package patch applies JSON Merge Patch (RFC 7386) and JSON Patch
+// (RFC 6902) documents to JSON values decoded into interface{}.
+package patch
+
+// Merge applies a JSON Merge Patch to doc and returns the result. doc is
+// not modified.
+func Merge(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]interface{})
+	result := make(map[string]interface{}, len(docObject))
+	if ok {
+		for key, value := range docObject {
+			result[key] = value
+		}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		result[key] = Merge(result[key], value)
+	}
+	return result
+}
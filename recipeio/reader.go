@@ -0,0 +1,162 @@
+package recipeio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxLineSize bounds a single NDJSON record.
+const maxLineSize = 1 << 20
+
+// reader yields the records of an import stream. A malformed record is
+// reported through err with a nil fatal error so that the import can go on;
+// a fatal error means the stream itself cannot be read any further.
+type reader interface {
+	next() (row int, rec record, err error, fatal error)
+}
+
+func newReader(format string, r io.Reader) (reader, error) {
+	switch format {
+	case FormatJSON:
+		return newJSONReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	row     int
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("reading JSON: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("JSON import must be an array of recipes")
+	}
+	return &jsonReader{decoder: decoder}, nil
+}
+
+func (r *jsonReader) next() (int, record, error, error) {
+	if !r.decoder.More() {
+		return r.row, record{}, nil, io.EOF
+	}
+	r.row++
+	var raw json.RawMessage
+	if err := r.decoder.Decode(&raw); err != nil {
+		return r.row, record{}, nil, fmt.Errorf("row %d: %w", r.row, err)
+	}
+	rec, err := parseJSONRecord(raw)
+	return r.row, rec, err, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	row     int
+}
+
+func (r *ndjsonReader) next() (int, record, error, error) {
+	for r.scanner.Scan() {
+		r.row++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		rec, err := parseJSONRecord(line)
+		return r.row, rec, err, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return r.row, record{}, nil, fmt.Errorf("line %d: %w", r.row+1, err)
+	}
+	return r.row, record{}, nil, io.EOF
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	row     int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("CSV header must contain a name column")
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) next() (int, record, error, error) {
+	fields, err := r.reader.Read()
+	r.row++
+	if err == io.EOF {
+		return r.row, record{}, nil, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) && parseErr.Err == csv.ErrFieldCount {
+		err = nil
+	}
+	if err != nil {
+		return r.row, record{}, nil, fmt.Errorf("row %d: %w", r.row, err)
+	}
+
+	cell := func(name string) string {
+		if i, ok := r.columns[strings.ToLower(name)]; ok && i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	list := func(name string) []string {
+		return strings.Split(cell(name), "\n")
+	}
+	var numbers [4]int
+	for i, name := range []string{"servings", "prepTime", "cookTime", "totalTime"} {
+		if value := strings.TrimSpace(cell(name)); value != "" {
+			if numbers[i], err = strconv.Atoi(value); err != nil {
+				return r.row, record{ID: cell("id")}, fmt.Errorf("%s %q is not a number", name, value), nil
+			}
+		}
+	}
+	return r.row, record{
+		ID:           cell("id"),
+		Name:         cell("name"),
+		Tags:         list("tags"),
+		Ingredients:  list("ingredients"),
+		Instructions: list("instructions"),
+		PublishedAt:  cell("publishedAt"),
+		Author:       cell("author"),
+		Servings:     numbers[0],
+		PrepTime:     numbers[1],
+		CookTime:     numbers[2],
+		TotalTime:    numbers[3],
+		Difficulty:   cell("difficulty"),
+		Cuisine:      cell("cuisine"),
+		Course:       cell("course"),
+		Status:       cell("status"),
+	}, nil, nil
+}
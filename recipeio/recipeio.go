@@ -0,0 +1,58 @@
+// Package recipeio imports and exports recipes in bulk as a JSON array,
+// newline delimited JSON or CSV.
+//
+// Import is tolerant of the legacy layout of recipes.json: field names are
+// matched case-insensitively and identifiers that are not ObjectIDs are
+// mapped to stable ObjectIDs, so re-importing a file is idempotent.
+package recipeio
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+var contentTypes = map[string]string{
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+	FormatCSV:    "text/csv",
+}
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (string, error) {
+	name = strings.ToLower(name)
+	if _, ok := contentTypes[name]; !ok {
+		return "", fmt.Errorf("unsupported format %q, use json, ndjson or csv", name)
+	}
+	return name, nil
+}
+
+// FormatFromContentType maps a media type to a format. Unknown or empty
+// media types map to JSON.
+func FormatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	case "text/csv":
+		return FormatCSV
+	default:
+		return FormatJSON
+	}
+}
+
+// ContentType returns the media type written for format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// csvColumns is the header written by the CSV exporter. List cells hold
+// one item per line. Nutrition facts are only carried by the JSON formats.
+var csvColumns = []string{"id", "name", "tags", "ingredients", "instructions", "publishedAt", "author", "servings",
+	"prepTime", "cookTime", "totalTime", "difficulty", "cuisine", "course", "status"}
@@ -0,0 +1,344 @@
+package recipeio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"recipes-api/models"
+	"recipes-api/store"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"json", FormatJSON, false},
+		{"NDJSON", FormatNDJSON, false},
+		{"csv", FormatCSV, false},
+		{"xml", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, error %t", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/json", FormatJSON},
+		{"application/x-ndjson", FormatNDJSON},
+		{"application/jsonl", FormatNDJSON},
+		{"text/csv; charset=utf-8", FormatCSV},
+		{"text/plain", FormatJSON},
+		{"", FormatJSON},
+	}
+	for _, tt := range tests {
+		if got := FormatFromContentType(tt.contentType); got != tt.want {
+			t.Errorf("FormatFromContentType(%q) = %q, want %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestParseID(t *testing.T) {
+	hex := primitive.NewObjectID()
+	legacy, err := ParseID("c0283p3d0cvuglq85log")
+	if err != nil {
+		t.Fatalf("ParseID of a legacy id: %v", err)
+	}
+	tests := []struct {
+		value   string
+		want    primitive.ObjectID
+		wantErr bool
+	}{
+		{hex.Hex(), hex, false},
+		{"c0283p3d0cvuglq85log", legacy, false},
+		{"not an id!", primitive.NilObjectID, true},
+		{strings.Repeat("x", 65), primitive.NilObjectID, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseID(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseID(%q) = %s, %v; want %s, error %t", tt.value, got.Hex(), err, tt.want.Hex(), tt.wantErr)
+		}
+	}
+	if other, _ := ParseID("c0283p3d0cvuglq85lof"); other == legacy {
+		t.Error("distinct legacy ids map to the same ObjectID")
+	}
+}
+
+func sampleRecipes() []models.Recipe {
+	published := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	return []models.Recipe{
+		{
+			ID:           primitive.NewObjectID(),
+			Name:         "Pancakes, fluffy",
+			Tags:         []string{"breakfast", "sweet"},
+			Ingredients:  []string{"2 eggs", "200 g flour", `1 cup "whole" milk`},
+			Instructions: []string{"Whisk.", "Fry."},
+			PublishedAt:  published,
+			Author:       "alice",
+			Status:       models.StatusPublished,
+			Servings:     4,
+			PrepTime:     10,
+			CookTime:     15,
+			TotalTime:    25,
+			Difficulty:   models.DifficultyEasy,
+			Cuisine:      "american",
+			Course:       "breakfast",
+		},
+		{
+			ID:           primitive.NewObjectID(),
+			Name:         "Draft soup",
+			Tags:         []string{},
+			Ingredients:  []string{"1 l water"},
+			Instructions: []string{},
+			Author:       "bob",
+			Status:       models.StatusDraft,
+		},
+	}
+}
+
+// TestRoundTrip exports recipes in each format and imports them into an
+// empty store.
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	for _, format := range []string{FormatJSON, FormatNDJSON, FormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			source := store.NewMemoryRecipeStore()
+			want := sampleRecipes()
+			for i := range want {
+				if err := source.Create(ctx, &want[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var buf bytes.Buffer
+			writer, err := NewWriter(format, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Export(ctx, source, writer, nil); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			target := store.NewMemoryRecipeStore()
+			report, err := Import(ctx, target, &buf, ImportOptions{Format: format, Mode: ModeSkip})
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if report.Created != len(want) || report.Failed != 0 {
+				t.Fatalf("report = %+v, want %d created", report, len(want))
+			}
+
+			for _, recipe := range want {
+				got, err := target.Get(ctx, recipe.ID)
+				if err != nil {
+					t.Fatalf("Get %s: %v", recipe.Name, err)
+				}
+				if got.Name != recipe.Name || got.Author != recipe.Author || got.Status != recipe.Status ||
+					!slices.Equal(got.Tags, recipe.Tags) || !slices.Equal(got.Ingredients, recipe.Ingredients) ||
+					!slices.Equal(got.Instructions, recipe.Instructions) || !got.PublishedAt.Equal(recipe.PublishedAt) ||
+					got.Servings != recipe.Servings || got.TotalTime != recipe.TotalTime ||
+					got.Difficulty != recipe.Difficulty || got.Course != recipe.Course {
+					t.Errorf("imported %+v, want %+v", got, recipe)
+				}
+			}
+		})
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	ctx := context.Background()
+	existing := primitive.NewObjectID()
+	input := strings.Join([]string{
+		`{"ID": "` + existing.Hex() + `", "Name": "Replaced"}`,
+		`{"name": "New", "ingredients": ["1 egg"]}`,
+		``,
+		`{"id": "bad id!", "name": "Broken"}`,
+		`{"name": ""}`,
+		`{"name": "Negative", "servings": -1}`,
+		`{"name": "Too long", "prepTime": 30, "totalTime": 10}`,
+		`{"name": "Unknown", "status": "lost"}`,
+		`not json`,
+	}, "\n")
+
+	tests := []struct {
+		mode    string
+		created int
+		updated int
+		skipped int
+	}{
+		{ModeSkip, 1, 0, 1},
+		{ModeUpsert, 1, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			recipes := store.NewMemoryRecipeStore()
+			original := models.Recipe{ID: existing, Name: "Original", Status: models.StatusPublished}
+			if err := recipes.Create(ctx, &original); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := Import(ctx, recipes, strings.NewReader(input), ImportOptions{Format: FormatNDJSON, Mode: tt.mode, Author: "importer"})
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if report.Created != tt.created || report.Updated != tt.updated || report.Skipped != tt.skipped || report.Failed != 6 {
+				t.Errorf("report = %+v", report)
+			}
+			var rows []int
+			for _, rowErr := range report.Errors {
+				rows = append(rows, rowErr.Row)
+			}
+			if want := []int{4, 5, 6, 7, 8, 9}; !slices.Equal(rows, want) {
+				t.Errorf("failed rows = %v, want %v", rows, want)
+			}
+			if report.Errors[0].ID != "bad id!" {
+				t.Errorf("first error = %+v, want the id of the row", report.Errors[0])
+			}
+			if len(report.Changed) != tt.created+tt.updated {
+				t.Errorf("changed = %v", report.Changed)
+			}
+
+			got, err := recipes.Get(ctx, existing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantName := "Original"
+			if tt.mode == ModeUpsert {
+				wantName = "Replaced"
+				if got.Author != "importer" {
+					t.Errorf("author = %q, want the importing user", got.Author)
+				}
+			}
+			if got.Name != wantName {
+				t.Errorf("existing recipe is named %q, want %q", got.Name, wantName)
+			}
+		})
+	}
+}
+
+func TestImportRejectsStreams(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  ImportOptions
+		input string
+	}{
+		{"unknown mode", ImportOptions{Format: FormatJSON, Mode: "merge"}, `[]`},
+		{"unknown format", ImportOptions{Format: "xml", Mode: ModeSkip}, `<recipes/>`},
+		{"JSON object", ImportOptions{Format: FormatJSON, Mode: ModeSkip}, `{"name": "Soup"}`},
+		{"truncated JSON", ImportOptions{Format: FormatJSON, Mode: ModeSkip}, `[{"name": "Soup"}, {"name":`},
+		{"CSV without name", ImportOptions{Format: FormatCSV, Mode: ModeSkip}, "id,tags\n1,soup\n"},
+		{"empty CSV", ImportOptions{Format: FormatCSV, Mode: ModeSkip}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Import(context.Background(), store.NewMemoryRecipeStore(), strings.NewReader(tt.input), tt.opts)
+			if err == nil {
+				t.Error("Import = nil, want an error")
+			}
+			var storeErr *StoreError
+			if errors.As(err, &storeErr) {
+				t.Errorf("Import = %v, want an input error", err)
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	input := "Name,Servings,Ingredients,Status\n" +
+		"Stew,4,\"1 onion\n2 carrots\",draft\n" +
+		"Broken,four,,\n" +
+		"Short\n"
+	recipes := store.NewMemoryRecipeStore()
+	report, err := Import(context.Background(), recipes, strings.NewReader(input), ImportOptions{Format: FormatCSV, Mode: ModeSkip})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if report.Created != 2 || report.Failed != 1 || report.Errors[0].Row != 2 {
+		t.Fatalf("report = %+v, want 2 created and row 2 failed", report)
+	}
+
+	list, err := recipes.List(context.Background(), store.ListOptions{Limit: 10, Sort: store.Sort{Field: store.SortByID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, recipe := range list {
+		if recipe.Name == "Stew" && (recipe.Servings != 4 || len(recipe.Ingredients) != 2 || recipe.Status != models.StatusDraft) {
+			t.Errorf("Stew = %+v", recipe)
+		}
+		if recipe.Name == "Short" && (recipe.Status != models.StatusPublished || recipe.PublishedAt.IsZero()) {
+			t.Errorf("Short = %+v, want published now", recipe)
+		}
+	}
+}
+
+func TestExportVisibility(t *testing.T) {
+	ctx := context.Background()
+	recipes := store.NewMemoryRecipeStore()
+	for _, recipe := range sampleRecipes() {
+		if err := recipes.Create(ctx, &recipe); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		visible *store.Visibility
+		want    int
+	}{
+		{"every status", nil, 2},
+		{"public", &store.Visibility{}, 1},
+		{"author of the draft", &store.Visibility{Author: "bob"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer, _ := NewWriter(FormatNDJSON, &buf)
+			if err := Export(ctx, recipes, writer, tt.visible); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+			if got := strings.Count(buf.String(), "\n"); got != tt.want {
+				t.Errorf("exported %d recipes, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportEmpty(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatJSON, "[]\n"},
+		{FormatNDJSON, ""},
+		{FormatCSV, strings.Join(csvColumns, ",") + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writer, err := NewWriter(tt.format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Export(context.Background(), store.NewMemoryRecipeStore(), writer, nil); err != nil {
+			t.Fatalf("Export %s: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("empty %s export = %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
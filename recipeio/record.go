@@ -0,0 +1,214 @@
+package recipeio
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"recipes-api/models"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// legacyID bounds the identifiers accepted besides ObjectIDs.
+var legacyID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// record is a recipe as read from an import file, before validation.
+type record struct {
+	ID           string
+	Name         string
+	Tags         []string
+	Ingredients  []string
+	Instructions []string
+	PublishedAt  string
+	Author       string
+	Servings     int
+	PrepTime     int
+	CookTime     int
+	TotalTime    int
+	Difficulty   string
+	Cuisine      string
+	Course       string
+	Nutrition    *models.Nutrition
+	Status       string
+}
+
+// parseJSONRecord decodes one JSON object, matching field names
+// case-insensitively.
+func parseJSONRecord(data []byte) (record, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return record{}, errors.New("record is not a JSON object")
+	}
+
+	var rec record
+	var err error
+	decode := func(name string, dst interface{}) {
+		if err != nil {
+			return
+		}
+		for key, value := range fields {
+			if strings.EqualFold(key, name) {
+				if string(value) == "null" {
+					return
+				}
+				if decodeErr := json.Unmarshal(value, dst); decodeErr != nil {
+					err = fmt.Errorf("field %s: %v", name, decodeErr)
+				}
+				return
+			}
+		}
+	}
+	decode("id", &rec.ID)
+	decode("name", &rec.Name)
+	decode("tags", &rec.Tags)
+	decode("ingredients", &rec.Ingredients)
+	decode("instructions", &rec.Instructions)
+	decode("publishedAt", &rec.PublishedAt)
+	decode("author", &rec.Author)
+	decode("servings", &rec.Servings)
+	decode("prepTime", &rec.PrepTime)
+	decode("cookTime", &rec.CookTime)
+	decode("totalTime", &rec.TotalTime)
+	decode("difficulty", &rec.Difficulty)
+	decode("cuisine", &rec.Cuisine)
+	decode("course", &rec.Course)
+	decode("nutrition", &rec.Nutrition)
+	decode("status", &rec.Status)
+	return rec, err
+}
+
+// ParseID accepts a hex ObjectID. Any other identifier, such as the xid
+// style ids of recipes.json, is mapped to an ObjectID derived from its hash
+// so that importing the same file twice addresses the same recipes. The
+// ids are hashed rather than decoded because hand-written xids in the
+// sample data differ only in bits that do not survive decoding.
+func ParseID(value string) (primitive.ObjectID, error) {
+	if id, err := primitive.ObjectIDFromHex(value); err == nil {
+		return id, nil
+	}
+	if !legacyID.MatchString(value) {
+		return primitive.NilObjectID, fmt.Errorf("invalid id %q", value)
+	}
+	sum := sha256.Sum256([]byte(value))
+	var id primitive.ObjectID
+	copy(id[:], sum[:])
+	return id, nil
+}
+
+// validate turns the record into a recipe. Missing IDs are generated, a
+// missing status defaults to published and a published recipe without a
+// publication date is taken to be published now.
+func (rec record) validate() (models.Recipe, error) {
+	recipe := models.Recipe{
+		Name:         strings.TrimSpace(rec.Name),
+		Tags:         cleanList(rec.Tags),
+		Ingredients:  cleanList(rec.Ingredients),
+		Instructions: cleanList(rec.Instructions),
+		Author:       strings.TrimSpace(rec.Author),
+		Servings:     rec.Servings,
+		PrepTime:     rec.PrepTime,
+		CookTime:     rec.CookTime,
+		TotalTime:    rec.TotalTime,
+		Difficulty:   strings.ToLower(strings.TrimSpace(rec.Difficulty)),
+		Cuisine:      rec.Cuisine,
+		Course:       rec.Course,
+		Nutrition:    rec.Nutrition,
+		Status:       strings.ToLower(strings.TrimSpace(rec.Status)),
+	}
+	recipe.NormalizeMetadata()
+
+	if rec.ID == "" {
+		recipe.ID = primitive.NewObjectID()
+	} else {
+		id, err := ParseID(strings.TrimSpace(rec.ID))
+		if err != nil {
+			return recipe, err
+		}
+		recipe.ID = id
+	}
+
+	if recipe.Name == "" {
+		return recipe, errors.New("name is required")
+	}
+	if recipe.Servings < 0 {
+		return recipe, errors.New("servings must not be negative")
+	}
+	if err := validateMetadata(recipe); err != nil {
+		return recipe, err
+	}
+	if recipe.Status == "" {
+		recipe.Status = models.StatusPublished
+	} else if !slices.Contains(models.Statuses, recipe.Status) {
+		return recipe, fmt.Errorf("status must be one of %s", strings.Join(models.Statuses, ", "))
+	}
+
+	if rec.PublishedAt == "" {
+		if recipe.Published() {
+			recipe.PublishedAt = time.Now()
+		}
+	} else {
+		publishedAt, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(rec.PublishedAt))
+		if err != nil {
+			return recipe, fmt.Errorf("publishedAt %q is not an RFC 3339 timestamp", rec.PublishedAt)
+		}
+		recipe.PublishedAt = publishedAt
+	}
+	return recipe, nil
+}
+
+// validateMetadata applies the checks the API makes on the times,
+// difficulty, cuisine, course and nutrition facts of a recipe.
+func validateMetadata(recipe models.Recipe) error {
+	for _, field := range []struct {
+		name    string
+		minutes int
+	}{{"prepTime", recipe.PrepTime}, {"cookTime", recipe.CookTime}, {"totalTime", recipe.TotalTime}} {
+		if field.minutes < 0 || field.minutes > models.MaxMinutes {
+			return fmt.Errorf("%s must be between 0 and %d minutes", field.name, models.MaxMinutes)
+		}
+	}
+	if recipe.TotalTime < recipe.PrepTime+recipe.CookTime {
+		return errors.New("totalTime must be at least prepTime + cookTime")
+	}
+	if recipe.Difficulty != "" && !slices.Contains(models.Difficulties, recipe.Difficulty) {
+		return fmt.Errorf("difficulty must be one of %s", strings.Join(models.Difficulties, ", "))
+	}
+	if len(recipe.Cuisine) > 50 {
+		return errors.New("cuisine must be at most 50 characters")
+	}
+	if recipe.Course != "" && !slices.Contains(models.Courses, recipe.Course) {
+		return fmt.Errorf("course must be one of %s", strings.Join(models.Courses, ", "))
+	}
+	if recipe.Nutrition != nil {
+		facts := recipe.Nutrition
+		for _, fact := range []struct {
+			name  string
+			value *float64
+		}{
+			{"calories", facts.Calories}, {"protein", facts.Protein}, {"fat", facts.Fat},
+			{"saturatedFat", facts.SaturatedFat}, {"carbohydrates", facts.Carbohydrates},
+			{"sugar", facts.Sugar}, {"fiber", facts.Fiber}, {"sodium", facts.Sodium},
+		} {
+			if fact.value != nil && *fact.value < 0 {
+				return fmt.Errorf("nutrition.%s must not be negative", fact.name)
+			}
+		}
+	}
+	return nil
+}
+
+// cleanList trims items and drops empty ones.
+func cleanList(values []string) []string {
+	cleaned := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			cleaned = append(cleaned, value)
+		}
+	}
+	return cleaned
+}
@@ -0,0 +1,156 @@
+package recipeio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"recipes-api/store"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// ModeSkip leaves existing recipes untouched.
+	ModeSkip = "skip"
+	// ModeUpsert replaces existing recipes with the imported version.
+	ModeUpsert = "upsert"
+)
+
+// maxReportedErrors bounds the size of a report; further failures are
+// still counted.
+const maxReportedErrors = 1000
+
+// exportBatchSize is the number of recipes read from the store at a time.
+const exportBatchSize = 500
+
+type ImportOptions struct {
+	Format string
+	Mode   string
+	// Author is recorded on imported recipes that do not name one.
+	Author string
+}
+
+type RowError struct {
+	Row   int    `json:"row"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type Report struct {
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Failed  int        `json:"failed"`
+	Errors  []RowError `json:"errors"`
+	// Changed lists the recipes that were created or replaced.
+	Changed []primitive.ObjectID `json:"-"`
+}
+
+func (r *Report) fail(row int, id string, err error) {
+	r.Failed++
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, RowError{Row: row, ID: id, Error: err.Error()})
+	}
+}
+
+// StoreError is a failure of the store while importing a row, as opposed
+// to a problem with the input.
+type StoreError struct {
+	Row int
+	Err error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// Import reads every record from r and stores the valid ones. Invalid rows
+// are reported and skipped. The returned error is non-nil only when the
+// stream could not be read or the store failed; the report then covers the
+// rows processed so far.
+func Import(ctx context.Context, recipes store.RecipeStore, r io.Reader, opts ImportOptions) (Report, error) {
+	report := Report{Errors: make([]RowError, 0)}
+	if opts.Mode != ModeSkip && opts.Mode != ModeUpsert {
+		return report, fmt.Errorf("unsupported mode %q, use skip or upsert", opts.Mode)
+	}
+
+	reader, err := newReader(opts.Format, r)
+	if err != nil {
+		return report, err
+	}
+
+	for {
+		row, rec, rowErr, fatal := reader.next()
+		if fatal == io.EOF {
+			return report, nil
+		}
+		if fatal != nil {
+			return report, fatal
+		}
+		if rowErr != nil {
+			report.fail(row, rec.ID, rowErr)
+			continue
+		}
+
+		recipe, err := rec.validate()
+		if err != nil {
+			report.fail(row, rec.ID, err)
+			continue
+		}
+		if recipe.Author == "" {
+			recipe.Author = opts.Author
+		}
+
+		if opts.Mode == ModeUpsert {
+			created, err := recipes.Upsert(ctx, recipe)
+			if err != nil {
+				return report, &StoreError{Row: row, Err: err}
+			}
+			if created {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+			report.Changed = append(report.Changed, recipe.ID)
+			continue
+		}
+
+		err = recipes.Create(ctx, &recipe)
+		if errors.Is(err, store.ErrDuplicate) {
+			report.Skipped++
+			continue
+		}
+		if err != nil {
+			return report, &StoreError{Row: row, Err: err}
+		}
+		report.Created++
+		report.Changed = append(report.Changed, recipe.ID)
+	}
+}
+
+// Export writes every recipe visible allows in ID order; a nil visible
+// exports recipes of every status.
+func Export(ctx context.Context, recipes store.RecipeStore, w Writer, visible *store.Visibility) error {
+	opts := store.ListOptions{Limit: exportBatchSize, Sort: store.Sort{Field: store.SortByID}, Visible: visible}
+	for {
+		batch, err := recipes.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for _, recipe := range batch {
+			if err := w.Write(recipe); err != nil {
+				return err
+			}
+		}
+		if len(batch) < exportBatchSize {
+			return w.Close()
+		}
+		cursor := store.CursorAfter(batch[len(batch)-1], opts.Sort)
+		opts.After = &cursor
+	}
+}
@@ -0,0 +1,108 @@
+package recipeio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"recipes-api/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer encodes recipes one at a time. Close must be called to complete
+// the document.
+type Writer interface {
+	Write(recipe models.Recipe) error
+	Close() error
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: writer}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonWriter) Write(recipe models.Recipe) error {
+	data, err := json.Marshal(recipe)
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if w.count == 0 {
+		separator = "[\n"
+	}
+	w.count++
+	if _, err := io.WriteString(w.w, separator); err != nil {
+		return err
+	}
+	_, err = w.w.Write(data)
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	closing := "\n]\n"
+	if w.count == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(w.w, closing)
+	return err
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(recipe models.Recipe) error {
+	return w.encoder.Encode(recipe)
+}
+
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(recipe models.Recipe) error {
+	return w.writer.Write([]string{
+		recipe.ID.Hex(),
+		recipe.Name,
+		strings.Join(recipe.Tags, "\n"),
+		strings.Join(recipe.Ingredients, "\n"),
+		strings.Join(recipe.Instructions, "\n"),
+		recipe.PublishedAt.Format(time.RFC3339Nano),
+		recipe.Author,
+		strconv.Itoa(recipe.Servings),
+		strconv.Itoa(recipe.PrepTime),
+		strconv.Itoa(recipe.CookTime),
+		strconv.Itoa(recipe.TotalTime),
+		recipe.Difficulty,
+		recipe.Cuisine,
+		recipe.Course,
+		recipe.Status,
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,181 @@
+// Package shopping combines the ingredients of several recipes into a
+// shopping list, merging lines for the same item and adding up their
+// quantities where the units allow it.
+package shopping
+
+import (
+	"cmp"
+	"math"
+	"recipes-api/ingredient"
+	"recipes-api/units"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Item is one line of a shopping list. Amounts holds one total per group
+// of compatible units; it is empty for items listed without a quantity,
+// such as "salt, to taste".
+type Item struct {
+	Item    string               `json:"item"`
+	Text    string               `json:"text"`
+	Amounts []ingredient.Amount  `json:"amounts,omitempty"`
+	Recipes []primitive.ObjectID `json:"recipes"`
+}
+
+// List accumulates ingredients. The zero value is not usable; call
+// NewList.
+type List struct {
+	system units.System
+	items  map[string]*Item
+}
+
+// NewList returns an empty list. A non-empty system converts every
+// quantity into it before adding, which also lets volumes and weights of
+// the same item be added up.
+func NewList(system units.System) *List {
+	return &List{system: system, items: make(map[string]*Item)}
+}
+
+// Add puts the ingredient lines of a recipe on the list, multiplying their
+// quantities by factor.
+func (l *List) Add(recipeID primitive.ObjectID, lines []string, factor float64) {
+	for _, parsed := range ingredient.ParseAll(lines) {
+		if factor != 1 {
+			parsed = parsed.Scale(factor)
+		}
+		if l.system != "" {
+			parsed = units.ConvertIngredient(parsed, l.system)
+		}
+		l.add(recipeID, parsed)
+	}
+}
+
+func (l *List) add(recipeID primitive.ObjectID, parsed ingredient.Ingredient) {
+	name := strings.Join(strings.Fields(parsed.Item), " ")
+	if name == "" {
+		return
+	}
+	key := singular(strings.ToLower(name))
+	item, ok := l.items[key]
+	if !ok {
+		item = &Item{Item: name, Recipes: make([]primitive.ObjectID, 0, 1)}
+		l.items[key] = item
+	}
+	if !slices.Contains(item.Recipes, recipeID) {
+		item.Recipes = append(item.Recipes, recipeID)
+	}
+	if parsed.Quantity != nil {
+		item.Amounts = addAmount(item.Amounts, ingredient.Amount{Quantity: *parsed.Quantity, Unit: parsed.Unit})
+	}
+}
+
+// singular strips the common English plural endings from the last word
+// of an item so that "2 eggs" and "1 egg" end up on one line.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "oes"), strings.HasSuffix(name, "ches"),
+		strings.HasSuffix(name, "shes"), strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") &&
+		!strings.HasSuffix(name, "us") && !strings.HasSuffix(name, "is"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// Items returns the list sorted by item, with Text filled in and the
+// rounding noise of unit conversions removed from the amounts.
+func (l *List) Items() []Item {
+	items := make([]Item, 0, len(l.items))
+	for _, item := range l.items {
+		amounts := make([]ingredient.Amount, len(item.Amounts))
+		for i, amount := range item.Amounts {
+			amount.Quantity = ingredient.Quantity{Value: round(amount.Quantity.Value), Max: round(amount.Quantity.Max)}
+			amounts[i] = amount
+		}
+		copied := *item
+		copied.Amounts = amounts
+		items = append(items, copied)
+	}
+	slices.SortFunc(items, func(a, b Item) int {
+		return cmp.Compare(strings.ToLower(a.Item), strings.ToLower(b.Item))
+	})
+	for i := range items {
+		items[i].Text = format(items[i])
+	}
+	return items
+}
+
+// addAmount adds amount to the total it is compatible with: one in the
+// same unit or, for known measures, in a unit of the same dimension. It is
+// kept separately otherwise, as cups and cloves cannot be added.
+func addAmount(totals []ingredient.Amount, amount ingredient.Amount) []ingredient.Amount {
+	for i, total := range totals {
+		if converted, ok := convert(amount, total.Unit); ok {
+			totals[i].Quantity = sum(total.Quantity, converted)
+			return totals
+		}
+	}
+	return append(totals, amount)
+}
+
+// convert expresses amount in unit, reporting false when the two units
+// are not comparable.
+func convert(amount ingredient.Amount, unit string) (ingredient.Quantity, bool) {
+	if amount.Unit == unit {
+		return amount.Quantity, true
+	}
+	from, _, known := units.Lookup(amount.Unit)
+	to, _, comparable := units.Lookup(unit)
+	if !known || !comparable || from != to {
+		return ingredient.Quantity{}, false
+	}
+	value, err := units.Convert(amount.Quantity.Value, amount.Unit, unit)
+	if err != nil {
+		return ingredient.Quantity{}, false
+	}
+	max, err := units.Convert(amount.Quantity.Max, amount.Unit, unit)
+	if err != nil {
+		return ingredient.Quantity{}, false
+	}
+	return ingredient.Quantity{Value: value, Max: max}, true
+}
+
+// sum adds two quantities. A range stays a range: the lower bounds and the
+// upper bounds are added, taking a single amount as both.
+func sum(a, b ingredient.Quantity) ingredient.Quantity {
+	total := ingredient.Quantity{Value: a.Value + b.Value}
+	if a.Max != 0 || b.Max != 0 {
+		total.Max = upper(a) + upper(b)
+	}
+	return total
+}
+
+// round keeps three decimals, more than any unit is measured in.
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+func upper(q ingredient.Quantity) float64 {
+	if q.Max != 0 {
+		return q.Max
+	}
+	return q.Value
+}
+
+// format writes an item as a line such as "1 1/8 cups + 2 cloves garlic".
+func format(item Item) string {
+	parts := make([]string, 0, len(item.Amounts)+1)
+	for _, amount := range item.Amounts {
+		quantity := amount.Quantity
+		parts = append(parts, ingredient.Ingredient{Quantity: &quantity, Unit: amount.Unit}.String())
+	}
+	if len(parts) == 0 {
+		return item.Item
+	}
+	return strings.Join(parts, " + ") + " " + item.Item
+}
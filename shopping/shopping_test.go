@@ -0,0 +1,134 @@
+package shopping
+
+import (
+	"recipes-api/units"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recipe is the ingredient lines of one recipe and the factor they are
+// added with.
+type recipe struct {
+	lines  []string
+	factor float64
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  units.System
+		recipes []recipe
+		want    []string
+	}{
+		{
+			name:    "same unit adds up",
+			recipes: []recipe{{[]string{"1 cup flour"}, 1}, {[]string{"1/2 cup flour"}, 1}},
+			want:    []string{"1 1/2 cups flour"},
+		},
+		{
+			name:    "plurals share a line named as first added",
+			recipes: []recipe{{[]string{"2 eggs"}, 1}, {[]string{"1 egg"}, 1}},
+			want:    []string{"3 eggs"},
+		},
+		{
+			name:    "compatible units are converted",
+			recipes: []recipe{{[]string{"1 cup milk"}, 1}, {[]string{"2 tbsp milk"}, 1}},
+			want:    []string{"1 1/8 cups milk"},
+		},
+		{
+			name:    "incompatible units are kept apart",
+			recipes: []recipe{{[]string{"1 cup garlic"}, 1}, {[]string{"2 cloves garlic"}, 1}},
+			want:    []string{"1 cup + 2 cloves garlic"},
+		},
+		{
+			name:    "ranges add both bounds",
+			recipes: []recipe{{[]string{"1-2 onions"}, 1}, {[]string{"1 onion"}, 1}},
+			want:    []string{"2-3 onions"},
+		},
+		{
+			name:    "factor scales",
+			recipes: []recipe{{[]string{"2 tbsp butter"}, 1.5}},
+			want:    []string{"3 tablespoons butter"},
+		},
+		{
+			name:    "items without quantity",
+			recipes: []recipe{{[]string{"salt, to taste"}, 1}, {[]string{"Salt, to taste"}, 2}},
+			want:    []string{"salt"},
+		},
+		{
+			name:    "sorted by item",
+			recipes: []recipe{{[]string{"1 tsp sugar", "2 apples", "1 Banana"}, 1}},
+			want:    []string{"2 apples", "1 Banana", "1 teaspoon sugar"},
+		},
+		{
+			name:    "converted into a system",
+			system:  units.Metric,
+			recipes: []recipe{{[]string{"1 cup milk"}, 1}, {[]string{"100 ml milk"}, 1}},
+			want:    []string{"337 ml milk"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := NewList(tt.system)
+			for _, r := range tt.recipes {
+				list.Add(primitive.NewObjectID(), r.lines, r.factor)
+			}
+			got := make([]string, 0)
+			for _, item := range list.Items() {
+				got = append(got, item.Text)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Items = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListRecipes(t *testing.T) {
+	list := NewList("")
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+	list.Add(first, []string{"1 egg", "2 eggs"}, 1)
+	list.Add(second, []string{"1 egg", "1 cup milk"}, 1)
+
+	items := list.Items()
+	if len(items) != 2 {
+		t.Fatalf("Items = %+v, want egg and milk", items)
+	}
+	// Each recipe is listed once per item, in the order it was added.
+	if got := items[0].Recipes; !slices.Equal(got, []primitive.ObjectID{first, second}) {
+		t.Errorf("egg recipes = %v, want %v", got, []primitive.ObjectID{first, second})
+	}
+	if got := items[1].Recipes; !slices.Equal(got, []primitive.ObjectID{second}) {
+		t.Errorf("milk recipes = %v, want %v", got, []primitive.ObjectID{second})
+	}
+	// Items returns copies: rounding and formatting leave the list as is.
+	items[0].Amounts[0].Quantity.Value = 100
+	if again := list.Items(); again[0].Amounts[0].Quantity.Value != 4 {
+		t.Errorf("egg quantity = %v after changing a returned item, want 4", again[0].Amounts[0].Quantity.Value)
+	}
+}
+
+func TestSingular(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"berries", "berry"},
+		{"tomatoes", "tomato"},
+		{"peaches", "peach"},
+		{"radishes", "radish"},
+		{"boxes", "box"},
+		{"eggs", "egg"},
+		{"glass", "glass"},
+		{"asparagus", "asparagus"},
+		{"couscous", "couscous"},
+		{"egg", "egg"},
+	}
+	for _, tt := range tests {
+		if got := singular(tt.name); got != tt.want {
+			t.Errorf("singular(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
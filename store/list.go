@@ -0,0 +1,155 @@
+package store
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"recipes-api/models"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SortByID          = "id"
+	SortByPublishedAt = "publishedAt"
+	SortByName        = "name"
+	// SortByRating orders by average rating.
+	SortByRating = "rating"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort describes the order of a recipe listing. Ties are always broken by
+// ID in the same direction so that keyset pagination is stable.
+type Sort struct {
+	Field      string
+	Descending bool
+}
+
+// ParseSort parses "field" or "-field". The empty string sorts by ID.
+func ParseSort(value string) (Sort, error) {
+	sort := Sort{Field: SortByID}
+	if strings.HasPrefix(value, "-") {
+		sort.Descending = true
+		value = value[1:]
+	}
+	switch value {
+	case "", SortByID:
+	case SortByPublishedAt, SortByName, SortByRating:
+		sort.Field = value
+	default:
+		return sort, fmt.Errorf("unsupported sort field %q", value)
+	}
+	return sort, nil
+}
+
+func (s Sort) String() string {
+	if s.Descending {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Cursor marks the position after which the next page starts. It carries
+// the sort key of the last recipe returned.
+type Cursor struct {
+	Sort        string             `json:"s"`
+	ID          primitive.ObjectID `json:"id"`
+	Name        string             `json:"n,omitempty"`
+	PublishedAt time.Time          `json:"p,omitempty"`
+	Rating      float64            `json:"r,omitempty"`
+}
+
+// CursorAfter returns the cursor that continues a listing after recipe.
+func CursorAfter(recipe models.Recipe, sort Sort) Cursor {
+	cursor := Cursor{Sort: sort.String(), ID: recipe.ID}
+	switch sort.Field {
+	case SortByName:
+		cursor.Name = recipe.Name
+	case SortByPublishedAt:
+		cursor.PublishedAt = recipe.PublishedAt
+	case SortByRating:
+		cursor.Rating = recipe.Rating.Average
+	}
+	return cursor
+}
+
+// Encode returns the opaque token handed out to clients.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode and checks that it
+// was issued for the same sort order.
+func DecodeCursor(token string, sort Sort) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort.String() || cursor.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// ListOptions controls paging and projection of a recipe listing. A zero
+// Limit means no limit. Fields lists JSON field names to load; an empty
+// list loads every field. A nil Visible lists recipes of every status.
+type ListOptions struct {
+	Limit   int
+	Sort    Sort
+	After   *Cursor
+	Fields  []string
+	Visible *Visibility
+}
+
+// Visibility limits a listing or search to published recipes and, when
+// Author is set, the other recipes of that user.
+type Visibility struct {
+	Author string
+}
+
+// allows reports whether recipe passes the visibility limit v.
+func (v *Visibility) allows(recipe models.Recipe) bool {
+	return v == nil || recipe.Published() || v.Author != "" && recipe.Author == v.Author
+}
+
+// compareRecipes orders a and b according to sort, returning a negative
+// number when a comes first.
+func compareRecipes(a, b models.Recipe, sort Sort) int {
+	result := 0
+	switch sort.Field {
+	case SortByName:
+		result = strings.Compare(a.Name, b.Name)
+	case SortByPublishedAt:
+		result = a.PublishedAt.Compare(b.PublishedAt)
+	case SortByRating:
+		result = cmp.Compare(a.Rating.Average, b.Rating.Average)
+	}
+	if result == 0 {
+		result = strings.Compare(a.ID.Hex(), b.ID.Hex())
+	}
+	if sort.Descending {
+		result = -result
+	}
+	return result
+}
+
+// afterCursor reports whether recipe sorts strictly after the cursor.
+func afterCursor(recipe models.Recipe, cursor *Cursor, sort Sort) bool {
+	if cursor == nil {
+		return true
+	}
+	pivot := models.Recipe{ID: cursor.ID, Name: cursor.Name, PublishedAt: cursor.PublishedAt,
+		Rating: models.Rating{Average: cursor.Rating}}
+	return compareRecipes(recipe, pivot, sort) > 0
+}
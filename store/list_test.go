@@ -0,0 +1,119 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"recipes-api/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Sort
+		wantErr bool
+	}{
+		{"", Sort{Field: SortByID}, false},
+		{"id", Sort{Field: SortByID}, false},
+		{"-id", Sort{Field: SortByID, Descending: true}, false},
+		{"name", Sort{Field: SortByName}, false},
+		{"-publishedAt", Sort{Field: SortByPublishedAt, Descending: true}, false},
+		{"-rating", Sort{Field: SortByRating, Descending: true}, false},
+		{"calories", Sort{}, true},
+		{"--name", Sort{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSort(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSort(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseSort(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	byName := Sort{Field: SortByName}
+	recipe := models.Recipe{ID: primitive.NewObjectID(), Name: "Omelette"}
+	token := CursorAfter(recipe, byName).Encode()
+
+	tests := []struct {
+		name  string
+		token string
+		sort  Sort
+		ok    bool
+	}{
+		{"round trip", token, byName, true},
+		{"other sort", token, Sort{Field: SortByName, Descending: true}, false},
+		{"not base64", "%%%", byName, false},
+		{"not json", "bm90IGpzb24", byName, false},
+		{"no id", Cursor{Sort: "name"}.Encode(), byName, false},
+	}
+	for _, tt := range tests {
+		cursor, err := DecodeCursor(tt.token, tt.sort)
+		if !tt.ok {
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("%s: error = %v, want ErrInvalidCursor", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cursor.ID != recipe.ID || cursor.Name != recipe.Name {
+			t.Errorf("%s: cursor = %+v", tt.name, cursor)
+		}
+	}
+}
+
+func TestMemoryRecipeStoreListPages(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	for _, name := range []string{"Crêpes", "Apple pie", "Bagels", "Dal", "Eclairs"} {
+		recipe := newRecipe(name)
+		if err := s.Create(ctx, &recipe); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []Sort{{Field: SortByName}, {Field: SortByName, Descending: true}, {Field: SortByID}} {
+		var names []string
+		opts := ListOptions{Limit: 2, Sort: sort}
+		for {
+			page, err := s.List(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, recipe := range page {
+				names = append(names, recipe.Name)
+			}
+			if len(page) < opts.Limit {
+				break
+			}
+			cursor := CursorAfter(page[len(page)-1], sort)
+			opts.After = &cursor
+		}
+		if len(names) != 5 {
+			t.Errorf("sort %s: paged through %v, want 5 recipes", sort, names)
+			continue
+		}
+		if sort.Field == SortByName {
+			want := []string{"Apple pie", "Bagels", "Crêpes", "Dal", "Eclairs"}
+			for i := range want {
+				j := i
+				if sort.Descending {
+					j = len(want) - 1 - i
+				}
+				if names[i] != want[j] {
+					t.Errorf("sort %s: got %v", sort, names)
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,925 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"recipes-api/models"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRecipeStore keeps recipes in process memory. It is intended for
+// local development where MongoDB is not available.
+type MemoryRecipeStore struct {
+	mu      sync.RWMutex
+	order   []primitive.ObjectID
+	recipes map[primitive.ObjectID]models.Recipe
+}
+
+func NewMemoryRecipeStore() *MemoryRecipeStore {
+	return &MemoryRecipeStore{
+		recipes: make(map[primitive.ObjectID]models.Recipe),
+	}
+}
+
+func (s *MemoryRecipeStore) Create(ctx context.Context, recipe *models.Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recipes[recipe.ID]; ok {
+		return ErrDuplicate
+	}
+	s.order = append(s.order, recipe.ID)
+	s.recipes[recipe.ID] = cloneRecipe(*recipe)
+	return nil
+}
+
+func (s *MemoryRecipeStore) Upsert(ctx context.Context, recipe models.Recipe) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, exists := s.recipes[recipe.ID]
+	if !exists {
+		s.order = append(s.order, recipe.ID)
+	}
+	carryOver(&recipe, existing)
+	s.recipes[recipe.ID] = cloneRecipe(recipe)
+	return !exists, nil
+}
+
+// carryOver copies the state Upsert keeps from the existing recipe: its
+// rating, images, trash state and, while it stays in review, its scheduled
+// publication.
+func carryOver(recipe *models.Recipe, existing models.Recipe) {
+	recipe.Rating = existing.Rating
+	recipe.Images = existing.Images
+	recipe.DeletedAt = existing.DeletedAt
+	recipe.PublishAt = nil
+	if recipe.Status == models.StatusInReview {
+		recipe.PublishAt = existing.PublishAt
+	}
+}
+
+func (s *MemoryRecipeStore) Get(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt != nil {
+		return models.Recipe{}, ErrNotFound
+	}
+	return cloneRecipe(recipe), nil
+}
+
+func (s *MemoryRecipeStore) List(ctx context.Context, opts ListOptions) ([]models.Recipe, error) {
+	recipes := s.filter(func(recipe models.Recipe) bool {
+		return recipe.DeletedAt == nil && opts.Visible.allows(recipe) && afterCursor(recipe, opts.After, opts.Sort)
+	})
+	slices.SortFunc(recipes, func(a, b models.Recipe) int {
+		return compareRecipes(a, b, opts.Sort)
+	})
+	if opts.Limit > 0 && len(recipes) > opts.Limit {
+		recipes = recipes[:opts.Limit]
+	}
+	return recipes, nil
+}
+
+func (s *MemoryRecipeStore) Update(ctx context.Context, id primitive.ObjectID, recipe models.Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.recipes[id]
+	if !ok || existing.DeletedAt != nil {
+		return ErrNotFound
+	}
+	existing.SetEditable(recipe)
+	s.recipes[id] = cloneRecipe(existing)
+	return nil
+}
+
+func (s *MemoryRecipeStore) UpdateIfUnchanged(ctx context.Context, id primitive.ObjectID, previous, recipe models.Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.recipes[id]
+	if !ok || existing.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if changed(existing, previous) {
+		return ErrConflict
+	}
+	existing.SetEditable(recipe)
+	existing.Status = recipe.Status
+	s.recipes[id] = cloneRecipe(existing)
+	return nil
+}
+
+// changed reports whether the editable fields or status of a stored recipe
+// differ from those of previous.
+func changed(stored, previous models.Recipe) bool {
+	return stored.Status != previous.Status || len(models.DiffRecipes(stored, previous)) > 0
+}
+
+func (s *MemoryRecipeStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt != nil {
+		return ErrNotFound
+	}
+	deletedAt := time.Now()
+	recipe.DeletedAt = &deletedAt
+	s.recipes[id] = recipe
+	return nil
+}
+
+func (s *MemoryRecipeStore) DeleteIfUnchanged(ctx context.Context, id primitive.ObjectID, previous models.Recipe, deletedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if changed(recipe, previous) {
+		return ErrConflict
+	}
+	recipe.DeletedAt = &deletedAt
+	s.recipes[id] = recipe
+	return nil
+}
+
+func (s *MemoryRecipeStore) Restore(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt == nil {
+		return ErrNotFound
+	}
+	recipe.DeletedAt = nil
+	s.recipes[id] = recipe
+	return nil
+}
+
+func (s *MemoryRecipeStore) GetDeleted(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt == nil {
+		return models.Recipe{}, ErrNotFound
+	}
+	return cloneRecipe(recipe), nil
+}
+
+func (s *MemoryRecipeStore) ListDeleted(ctx context.Context, author string, limit, offset int) ([]models.Recipe, int64, error) {
+	recipes := s.filter(func(recipe models.Recipe) bool {
+		return recipe.DeletedAt != nil && (author == "" || recipe.Author == author)
+	})
+	slices.SortStableFunc(recipes, func(a, b models.Recipe) int {
+		return b.DeletedAt.Compare(*a.DeletedAt)
+	})
+	return paginate(recipes, offset, limit), int64(len(recipes)), nil
+}
+
+func (s *MemoryRecipeStore) Purge(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt == nil {
+		return models.Recipe{}, ErrNotFound
+	}
+	s.remove(id)
+	return recipe, nil
+}
+
+func (s *MemoryRecipeStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]models.Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	purged := make([]models.Recipe, 0)
+	for _, recipe := range s.recipes {
+		if recipe.DeletedAt != nil && recipe.DeletedAt.Before(cutoff) {
+			purged = append(purged, recipe)
+		}
+	}
+	for _, recipe := range purged {
+		s.remove(recipe.ID)
+	}
+	return purged, nil
+}
+
+func (s *MemoryRecipeStore) AddImage(ctx context.Context, id primitive.ObjectID, image models.Image, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if len(recipe.Images) >= limit {
+		return ErrLimitReached
+	}
+	recipe.Images = append(cloneImages(recipe.Images), image)
+	s.recipes[id] = recipe
+	return nil
+}
+
+func (s *MemoryRecipeStore) RemoveImage(ctx context.Context, id, imageID primitive.ObjectID) (models.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe, ok := s.recipes[id]
+	if !ok || recipe.DeletedAt != nil {
+		return models.Image{}, ErrNotFound
+	}
+	for i, image := range recipe.Images {
+		if image.ID == imageID {
+			images := cloneImages(recipe.Images)
+			recipe.Images = append(images[:i], images[i+1:]...)
+			s.recipes[id] = recipe
+			return image, nil
+		}
+	}
+	return models.Image{}, ErrNotFound
+}
+
+func (s *MemoryRecipeStore) GetByImage(ctx context.Context, imageID primitive.ObjectID) (models.Recipe, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, recipe := range s.recipes {
+		if recipe.DeletedAt != nil {
+			continue
+		}
+		for _, image := range recipe.Images {
+			if image.ID == imageID {
+				return cloneRecipe(recipe), nil
+			}
+		}
+	}
+	return models.Recipe{}, ErrNotFound
+}
+
+// setRating replaces the rating of a recipe, trashed or not, and does
+// nothing when there is no such recipe.
+func (s *MemoryRecipeStore) setRating(id primitive.ObjectID, rating models.Rating) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if recipe, ok := s.recipes[id]; ok {
+		recipe.Rating = rating
+		s.recipes[id] = recipe
+	}
+}
+
+func (s *MemoryRecipeStore) SetStatus(ctx context.Context, id primitive.ObjectID, from string, recipe models.Recipe) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.recipes[id]
+	if !ok || existing.DeletedAt != nil {
+		return ErrNotFound
+	}
+	if existing.Status != from {
+		return ErrConflict
+	}
+	existing.Status = recipe.Status
+	existing.PublishAt = recipe.PublishAt
+	existing.PublishedAt = recipe.PublishedAt
+	s.recipes[id] = cloneRecipe(existing)
+	return nil
+}
+
+func (s *MemoryRecipeStore) PublishDue(ctx context.Context, now time.Time) ([]models.Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	published := make([]models.Recipe, 0)
+	for _, id := range s.order {
+		recipe := s.recipes[id]
+		if recipe.DeletedAt != nil || recipe.Status != models.StatusInReview ||
+			recipe.PublishAt == nil || recipe.PublishAt.After(now) {
+			continue
+		}
+		recipe.Status = models.StatusPublished
+		recipe.PublishedAt = *recipe.PublishAt
+		recipe.PublishAt = nil
+		s.recipes[id] = recipe
+		published = append(published, cloneRecipe(recipe))
+	}
+	return published, nil
+}
+
+// remove drops a recipe for good. The caller must hold the write lock.
+func (s *MemoryRecipeStore) remove(id primitive.ObjectID) {
+	delete(s.recipes, id)
+	for i, existing := range s.order {
+		if existing == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *MemoryRecipeStore) Search(ctx context.Context, query SearchQuery) ([]models.Recipe, int64, error) {
+	scores := make(map[primitive.ObjectID]float64)
+	recipes := s.filter(func(recipe models.Recipe) bool {
+		if recipe.DeletedAt != nil || !query.matchesFilters(recipe) {
+			return false
+		}
+		if query.Text == "" {
+			return true
+		}
+		score := textScore(recipe, query.Text)
+		scores[recipe.ID] = score
+		return score > 0
+	})
+	slices.SortStableFunc(recipes, func(a, b models.Recipe) int {
+		if byScore := cmp.Compare(scores[b.ID], scores[a.ID]); byScore != 0 {
+			return byScore
+		}
+		return compareRecipes(a, b, Sort{Field: SortByID})
+	})
+	return paginate(recipes, query.Offset, query.Limit), int64(len(recipes)), nil
+}
+
+func (s *MemoryRecipeStore) filter(match func(models.Recipe) bool) []models.Recipe {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	recipes := make([]models.Recipe, 0)
+	for _, id := range s.order {
+		recipe := s.recipes[id]
+		if match(recipe) {
+			recipes = append(recipes, cloneRecipe(recipe))
+		}
+	}
+	return recipes
+}
+
+func cloneRecipe(recipe models.Recipe) models.Recipe {
+	recipe.Tags = cloneStrings(recipe.Tags)
+	recipe.Ingredients = cloneStrings(recipe.Ingredients)
+	recipe.Instructions = cloneStrings(recipe.Instructions)
+	recipe.Images = cloneImages(recipe.Images)
+	if recipe.Nutrition != nil {
+		nutrition := *recipe.Nutrition
+		recipe.Nutrition = &nutrition
+	}
+	if recipe.DeletedAt != nil {
+		deletedAt := *recipe.DeletedAt
+		recipe.DeletedAt = &deletedAt
+	}
+	if recipe.PublishAt != nil {
+		publishAt := *recipe.PublishAt
+		recipe.PublishAt = &publishAt
+	}
+	return recipe
+}
+
+func cloneImages(images []models.Image) []models.Image {
+	if images == nil {
+		return nil
+	}
+	return append(make([]models.Image, 0, len(images)), images...)
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(values)), values...)
+}
+
+type MemoryUserStore struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users: make(map[string]models.User),
+	}
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.Username]; ok {
+		return ErrDuplicate
+	}
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	s.users[user.Username] = cloneUser(user)
+	return nil
+}
+
+func (s *MemoryUserStore) GetUser(ctx context.Context, username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[username]
+	if !ok || user.DeletedAt != nil {
+		return models.User{}, ErrNotFound
+	}
+	return cloneUser(user), nil
+}
+
+func (s *MemoryUserStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]models.User, 0, len(s.users))
+	for _, user := range s.users {
+		if user.DeletedAt == nil {
+			users = append(users, cloneUser(user))
+		}
+	}
+	slices.SortFunc(users, func(a, b models.User) int {
+		return cmp.Compare(a.Username, b.Username)
+	})
+	return users, nil
+}
+
+func (s *MemoryUserStore) UpdatePassword(ctx context.Context, username, passwordHash string) error {
+	return s.updateUser(username, func(user *models.User) {
+		user.Password = passwordHash
+	})
+}
+
+func (s *MemoryUserStore) ReplacePassword(ctx context.Context, username, from, to string) error {
+	replaced := false
+	err := s.updateUser(username, func(user *models.User) {
+		if replaced = user.Password == from; replaced {
+			user.Password = to
+		}
+	})
+	if err == nil && !replaced {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *MemoryUserStore) ChangePassword(ctx context.Context, username, passwordHash string) error {
+	return s.updateUser(username, func(user *models.User) {
+		user.Password = passwordHash
+		user.TokenVersion++
+	})
+}
+
+func (s *MemoryUserStore) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	return s.updateUser(username, func(user *models.User) {
+		user.Disabled = disabled
+	})
+}
+
+func (s *MemoryUserStore) SetRoles(ctx context.Context, username string, roles []string) error {
+	return s.updateUser(username, func(user *models.User) {
+		user.Roles = cloneStrings(roles)
+	})
+}
+
+func (s *MemoryUserStore) GrantMissingRoles(ctx context.Context, roles []string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var granted int64
+	for username, user := range s.users {
+		if user.DeletedAt == nil && len(user.Roles) == 0 {
+			user.Roles = cloneStrings(roles)
+			s.users[username] = user
+			granted++
+		}
+	}
+	return granted, nil
+}
+
+func (s *MemoryUserStore) DeleteUser(ctx context.Context, username string) error {
+	now := time.Now()
+	return s.updateUser(username, func(user *models.User) {
+		*user = tombstone(*user, now)
+	})
+}
+
+func (s *MemoryUserStore) updateUser(username string, update func(*models.User)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[username]
+	if !ok || user.DeletedAt != nil {
+		return ErrNotFound
+	}
+	update(&user)
+	s.users[username] = user
+	return nil
+}
+
+// tombstone returns what is kept of user once the account is deleted.
+func tombstone(user models.User, now time.Time) models.User {
+	return models.User{
+		ID:           user.ID,
+		Username:     user.Username,
+		CreatedAt:    user.CreatedAt,
+		Disabled:     true,
+		TokenVersion: user.TokenVersion + 1,
+		DeletedAt:    &now,
+	}
+}
+
+func cloneUser(user models.User) models.User {
+	user.Roles = cloneStrings(user.Roles)
+	return user
+}
+
+// MemoryRevisionStore keeps recipe history in process memory, ordered by
+// revision number per recipe.
+type MemoryRevisionStore struct {
+	mu        sync.RWMutex
+	revisions map[primitive.ObjectID][]models.Revision
+}
+
+func NewMemoryRevisionStore() *MemoryRevisionStore {
+	return &MemoryRevisionStore{
+		revisions: make(map[primitive.ObjectID][]models.Revision),
+	}
+}
+
+func (s *MemoryRevisionStore) Append(ctx context.Context, revision models.Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history := s.revisions[revision.RecipeID]
+	if len(history) > 0 && history[len(history)-1].Number >= revision.Number {
+		return ErrDuplicate
+	}
+	revision.ID = primitive.NewObjectID()
+	s.revisions[revision.RecipeID] = append(history, cloneRevision(revision))
+	return nil
+}
+
+func (s *MemoryRevisionStore) Latest(ctx context.Context, recipeID primitive.ObjectID) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := s.revisions[recipeID]
+	if len(history) == 0 {
+		return models.Revision{}, ErrNotFound
+	}
+	return cloneRevision(history[len(history)-1]), nil
+}
+
+func (s *MemoryRevisionStore) Get(ctx context.Context, recipeID primitive.ObjectID, number int) (models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, revision := range s.revisions[recipeID] {
+		if revision.Number == number {
+			return cloneRevision(revision), nil
+		}
+	}
+	return models.Revision{}, ErrNotFound
+}
+
+func (s *MemoryRevisionStore) List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Revision, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history := s.revisions[recipeID]
+	revisions := make([]models.Revision, 0)
+	for i := len(history) - 1 - offset; i >= 0 && (limit <= 0 || len(revisions) < limit); i-- {
+		revision := cloneRevision(history[i])
+		revision.Recipe = nil
+		revisions = append(revisions, revision)
+	}
+	return revisions, int64(len(history)), nil
+}
+
+func (s *MemoryRevisionStore) DeleteHistory(ctx context.Context, recipeID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.revisions, recipeID)
+	return nil
+}
+
+func cloneRevision(revision models.Revision) models.Revision {
+	if revision.Recipe != nil {
+		recipe := cloneRecipe(*revision.Recipe)
+		revision.Recipe = &recipe
+	}
+	revision.Changes = slices.Clone(revision.Changes)
+	return revision
+}
+
+// MemoryReviewStore keeps reviews in process memory, in the order they
+// were first written per recipe, and writes the ratings they add up to
+// into recipes.
+type MemoryReviewStore struct {
+	mu      sync.RWMutex
+	reviews map[primitive.ObjectID][]models.Review
+	recipes *MemoryRecipeStore
+}
+
+func NewMemoryReviewStore(recipes *MemoryRecipeStore) *MemoryReviewStore {
+	return &MemoryReviewStore{
+		reviews: make(map[primitive.ObjectID][]models.Review),
+		recipes: recipes,
+	}
+}
+
+func (s *MemoryReviewStore) Put(ctx context.Context, review models.Review) (*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var previous *models.Review
+	reviews := s.reviews[review.RecipeID]
+	for i, existing := range reviews {
+		if existing.Author == review.Author {
+			review.ID = existing.ID
+			review.CreatedAt = existing.CreatedAt
+			reviews[i] = review
+			previous = &existing
+			break
+		}
+	}
+	if previous == nil {
+		reviews = append(reviews, review)
+		s.reviews[review.RecipeID] = reviews
+	}
+	// The rating is written while the reviews are still locked, so that it
+	// always reflects the latest Put.
+	s.recipes.setRating(review.RecipeID, models.RatingOf(reviews))
+	return previous, nil
+}
+
+func (s *MemoryReviewStore) List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Review, int64, error) {
+	s.mu.RLock()
+	reviews := slices.Clone(s.reviews[recipeID])
+	s.mu.RUnlock()
+	slices.SortStableFunc(reviews, func(a, b models.Review) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	total := int64(len(reviews))
+	if offset >= len(reviews) {
+		return make([]models.Review, 0), total, nil
+	}
+	reviews = reviews[offset:]
+	if limit > 0 && len(reviews) > limit {
+		reviews = reviews[:limit]
+	}
+	return reviews, total, nil
+}
+
+func (s *MemoryReviewStore) DeleteReviews(ctx context.Context, recipeID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reviews, recipeID)
+	return nil
+}
+
+// MemoryCollectionStore keeps collections in process memory.
+type MemoryCollectionStore struct {
+	mu          sync.RWMutex
+	order       []primitive.ObjectID
+	collections map[primitive.ObjectID]models.Collection
+}
+
+func NewMemoryCollectionStore() *MemoryCollectionStore {
+	return &MemoryCollectionStore{
+		collections: make(map[primitive.ObjectID]models.Collection),
+	}
+}
+
+func (s *MemoryCollectionStore) Create(ctx context.Context, collection models.Collection, limit int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[collection.ID]; ok {
+		return ErrDuplicate
+	}
+	owned := 0
+	for _, existing := range s.collections {
+		if existing.Owner == collection.Owner {
+			owned++
+		}
+	}
+	if owned >= limit {
+		return ErrLimitReached
+	}
+	s.order = append(s.order, collection.ID)
+	s.collections[collection.ID] = cloneCollection(collection)
+	return nil
+}
+
+func (s *MemoryCollectionStore) Get(ctx context.Context, id primitive.ObjectID) (models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collection, ok := s.collections[id]
+	if !ok {
+		return models.Collection{}, ErrNotFound
+	}
+	return cloneCollection(collection), nil
+}
+
+func (s *MemoryCollectionStore) Favourites(ctx context.Context, owner string) (models.Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, collection := range s.collections {
+		if collection.Owner == owner && collection.Favourites {
+			return cloneCollection(collection), nil
+		}
+	}
+	now := time.Now()
+	collection := models.Collection{
+		ID:         primitive.NewObjectID(),
+		Owner:      owner,
+		Name:       models.FavouritesName,
+		Favourites: true,
+		RecipeIDs:  make([]primitive.ObjectID, 0),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	s.order = append(s.order, collection.ID)
+	s.collections[collection.ID] = collection
+	return cloneCollection(collection), nil
+}
+
+func (s *MemoryCollectionStore) ListByOwner(ctx context.Context, owner string) ([]models.Collection, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	collections := make([]models.Collection, 0)
+	for _, id := range s.order {
+		if collection := s.collections[id]; collection.Owner == owner {
+			collections = append(collections, cloneCollection(collection))
+		}
+	}
+	slices.SortStableFunc(collections, func(a, b models.Collection) int {
+		if a.Favourites != b.Favourites {
+			if a.Favourites {
+				return -1
+			}
+			return 1
+		}
+		return 0
+	})
+	return collections, nil
+}
+
+func (s *MemoryCollectionStore) Update(ctx context.Context, collection models.Collection) error {
+	return s.modify(collection.ID, func(existing *models.Collection) error {
+		existing.Name = collection.Name
+		existing.Description = collection.Description
+		existing.Shared = collection.Shared
+		existing.UpdatedAt = collection.UpdatedAt
+		return nil
+	})
+}
+
+func (s *MemoryCollectionStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.collections[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.collections, id)
+	s.order = slices.DeleteFunc(s.order, func(existing primitive.ObjectID) bool {
+		return existing == id
+	})
+	return nil
+}
+
+func (s *MemoryCollectionStore) AddRecipe(ctx context.Context, id, recipeID primitive.ObjectID, position, limit int) error {
+	return s.modify(id, func(collection *models.Collection) error {
+		if slices.Contains(collection.RecipeIDs, recipeID) {
+			return ErrDuplicate
+		}
+		if len(collection.RecipeIDs) >= limit {
+			return ErrLimitReached
+		}
+		if position < 0 || position > len(collection.RecipeIDs) {
+			position = len(collection.RecipeIDs)
+		}
+		collection.RecipeIDs = slices.Insert(collection.RecipeIDs, position, recipeID)
+		collection.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (s *MemoryCollectionStore) RemoveRecipe(ctx context.Context, id, recipeID primitive.ObjectID) error {
+	return s.modify(id, func(collection *models.Collection) error {
+		i := slices.Index(collection.RecipeIDs, recipeID)
+		if i < 0 {
+			return ErrNotFound
+		}
+		collection.RecipeIDs = slices.Delete(collection.RecipeIDs, i, i+1)
+		collection.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (s *MemoryCollectionStore) ReorderRecipes(ctx context.Context, id primitive.ObjectID, previous, order []primitive.ObjectID) error {
+	return s.modify(id, func(collection *models.Collection) error {
+		if !slices.Equal(collection.RecipeIDs, previous) {
+			return ErrConflict
+		}
+		collection.RecipeIDs = slices.Clone(order)
+		collection.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (s *MemoryCollectionStore) RemoveRecipeEverywhere(ctx context.Context, recipeID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, collection := range s.collections {
+		if i := slices.Index(collection.RecipeIDs, recipeID); i >= 0 {
+			collection.RecipeIDs = slices.Delete(slices.Clone(collection.RecipeIDs), i, i+1)
+			s.collections[id] = collection
+		}
+	}
+	return nil
+}
+
+// modify applies change to a copy of the stored collection and keeps the
+// copy unless change fails.
+func (s *MemoryCollectionStore) modify(id primitive.ObjectID, change func(*models.Collection) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	collection, ok := s.collections[id]
+	if !ok {
+		return ErrNotFound
+	}
+	collection = cloneCollection(collection)
+	if err := change(&collection); err != nil {
+		return err
+	}
+	s.collections[id] = collection
+	return nil
+}
+
+func cloneCollection(collection models.Collection) models.Collection {
+	collection.RecipeIDs = slices.Clone(collection.RecipeIDs)
+	if collection.RecipeIDs == nil {
+		collection.RecipeIDs = make([]primitive.ObjectID, 0)
+	}
+	return collection
+}
+
+// MemoryMealPlanStore keeps meal plans in process memory.
+type MemoryMealPlanStore struct {
+	mu      sync.RWMutex
+	order   []primitive.ObjectID
+	entries map[primitive.ObjectID]models.MealPlanEntry
+}
+
+func NewMemoryMealPlanStore() *MemoryMealPlanStore {
+	return &MemoryMealPlanStore{
+		entries: make(map[primitive.ObjectID]models.MealPlanEntry),
+	}
+}
+
+func (s *MemoryMealPlanStore) Create(ctx context.Context, entry models.MealPlanEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[entry.ID]; ok {
+		return ErrDuplicate
+	}
+	s.order = append(s.order, entry.ID)
+	s.entries[entry.ID] = entry
+	return nil
+}
+
+func (s *MemoryMealPlanStore) Get(ctx context.Context, id primitive.ObjectID) (models.MealPlanEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return models.MealPlanEntry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+func (s *MemoryMealPlanStore) List(ctx context.Context, owner, from, to string) ([]models.MealPlanEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries := make([]models.MealPlanEntry, 0)
+	for _, id := range s.order {
+		entry := s.entries[id]
+		if entry.Owner == owner && entry.Date >= from && entry.Date <= to {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b models.MealPlanEntry) int {
+		return cmp.Compare(a.Date, b.Date)
+	})
+	return entries, nil
+}
+
+func (s *MemoryMealPlanStore) Update(ctx context.Context, entry models.MealPlanEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, ok := s.entries[entry.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	existing.Date = entry.Date
+	existing.Slot = entry.Slot
+	existing.RecipeID = entry.RecipeID
+	existing.Servings = entry.Servings
+	existing.Note = entry.Note
+	existing.UpdatedAt = entry.UpdatedAt
+	s.entries[entry.ID] = existing
+	return nil
+}
+
+func (s *MemoryMealPlanStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	s.order = slices.DeleteFunc(s.order, func(existing primitive.ObjectID) bool {
+		return existing == id
+	})
+	return nil
+}
@@ -0,0 +1,377 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"recipes-api/models"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newRecipe(name string) models.Recipe {
+	return models.Recipe{
+		ID:          primitive.NewObjectID(),
+		Name:        name,
+		Ingredients: []string{"1 egg"},
+		Status:      models.StatusPublished,
+	}
+}
+
+func TestMemoryRecipeStoreCreateGet(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	recipe := newRecipe("Omelette")
+	if err := s.Create(ctx, &recipe); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.Create(ctx, &recipe); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second Create = %v, want ErrDuplicate", err)
+	}
+
+	got, err := s.Get(ctx, recipe.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Name != "Omelette" {
+		t.Errorf("Name = %q, want Omelette", got.Name)
+	}
+	// The stored recipe must not share memory with what callers hold.
+	got.Ingredients[0] = "changed"
+	if again, _ := s.Get(ctx, recipe.ID); again.Ingredients[0] != "1 egg" {
+		t.Errorf("stored ingredients changed through a returned recipe")
+	}
+
+	if _, err := s.Get(ctx, primitive.NewObjectID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of unknown id = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRecipeStoreUpdateIfUnchanged(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	recipe := newRecipe("Pancakes")
+	if err := s.Create(ctx, &recipe); err != nil {
+		t.Fatal(err)
+	}
+
+	edited := recipe
+	edited.Name = "Crêpes"
+	if err := s.UpdateIfUnchanged(ctx, recipe.ID, recipe, edited); err != nil {
+		t.Fatalf("UpdateIfUnchanged: %v", err)
+	}
+	// recipe is no longer the stored state.
+	stale := recipe
+	stale.Name = "Waffles"
+	if err := s.UpdateIfUnchanged(ctx, recipe.ID, recipe, stale); !errors.Is(err, ErrConflict) {
+		t.Fatalf("UpdateIfUnchanged with stale previous = %v, want ErrConflict", err)
+	}
+	if got, _ := s.Get(ctx, recipe.ID); got.Name != "Crêpes" {
+		t.Errorf("Name = %q, want Crêpes", got.Name)
+	}
+	if err := s.UpdateIfUnchanged(ctx, primitive.NewObjectID(), recipe, edited); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateIfUnchanged of unknown id = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRecipeStoreTrash(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	recipe := newRecipe("Soup")
+	if err := s.Create(ctx, &recipe); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, recipe.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"Get", func() error { _, err := s.Get(ctx, recipe.ID); return err }, ErrNotFound},
+		{"GetDeleted", func() error { _, err := s.GetDeleted(ctx, recipe.ID); return err }, nil},
+		{"Delete again", func() error { return s.Delete(ctx, recipe.ID) }, ErrNotFound},
+		{"Update", func() error { return s.Update(ctx, recipe.ID, recipe) }, ErrNotFound},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, tt.want) {
+			t.Errorf("%s in trash = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if recipes, _ := s.List(ctx, ListOptions{}); len(recipes) != 0 {
+		t.Errorf("List returned %d recipes from the trash", len(recipes))
+	}
+
+	if err := s.Restore(ctx, recipe.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := s.Get(ctx, recipe.ID); err != nil {
+		t.Errorf("Get after Restore: %v", err)
+	}
+}
+
+func TestMemoryUserStoreDeleteKeepsUsername(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore()
+	user := models.User{Username: "alice", Password: "hash", Roles: []string{models.RoleAuthor}, TokenVersion: 3}
+	if err := s.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUser(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want error
+	}{
+		{"GetUser", func() error { _, err := s.GetUser(ctx, "alice"); return err }, ErrNotFound},
+		{"DeleteUser again", func() error { return s.DeleteUser(ctx, "alice") }, ErrNotFound},
+		{"SetRoles", func() error { return s.SetRoles(ctx, "alice", []string{models.RoleAdmin}) }, ErrNotFound},
+		{"ChangePassword", func() error { return s.ChangePassword(ctx, "alice", "new") }, ErrNotFound},
+		{"CreateUser with the same name", func() error { return s.CreateUser(ctx, models.User{Username: "alice"}) }, ErrDuplicate},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); !errors.Is(err, tt.want) {
+			t.Errorf("%s after delete = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if users, _ := s.ListUsers(ctx); len(users) != 0 {
+		t.Errorf("ListUsers returned %d users, want none", len(users))
+	}
+
+	s.mu.RLock()
+	tomb := s.users["alice"]
+	s.mu.RUnlock()
+	if tomb.TokenVersion != 4 || tomb.Password != "" || !tomb.Disabled {
+		t.Errorf("tombstone = %+v, want disabled, no password and token version 4", tomb)
+	}
+}
+
+func TestMemoryUserStoreReplacePassword(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore()
+	if err := s.CreateUser(ctx, models.User{Username: "alice", Password: "old", TokenVersion: 2}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		username string
+		from     string
+		want     error
+		password string
+	}{
+		{"stale hash", "alice", "other", ErrConflict, "old"},
+		{"current hash", "alice", "old", nil, "new"},
+		{"missing user", "bob", "old", ErrNotFound, "new"},
+	}
+	for _, tt := range tests {
+		if err := s.ReplacePassword(ctx, tt.username, tt.from, "new"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: ReplacePassword = %v, want %v", tt.name, err, tt.want)
+		}
+		user, _ := s.GetUser(ctx, "alice")
+		if user.Password != tt.password || user.TokenVersion != 2 {
+			t.Errorf("%s: password %q, token version %d; want %q, 2", tt.name, user.Password, user.TokenVersion, tt.password)
+		}
+	}
+}
+
+func TestMemoryUserStoreGrantMissingRoles(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryUserStore()
+	users := []models.User{
+		{Username: "legacy"},
+		{Username: "empty", Roles: []string{}},
+		{Username: "editor", Roles: []string{models.RoleEditor}},
+		{Username: "deleted"},
+	}
+	for _, user := range users {
+		if err := s.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.DeleteUser(ctx, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+
+	granted, err := s.GrantMissingRoles(ctx, []string{models.RoleAuthor})
+	if err != nil || granted != 2 {
+		t.Fatalf("GrantMissingRoles = %d, %v, want 2", granted, err)
+	}
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"legacy", models.RoleAuthor},
+		{"empty", models.RoleAuthor},
+		{"editor", models.RoleEditor},
+	}
+	for _, tt := range tests {
+		user, err := s.GetUser(ctx, tt.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(user.Roles) != 1 || user.Roles[0] != tt.want {
+			t.Errorf("%s has roles %v, want [%s]", tt.username, user.Roles, tt.want)
+		}
+	}
+	if granted, _ := s.GrantMissingRoles(ctx, []string{models.RoleAuthor}); granted != 0 {
+		t.Errorf("second GrantMissingRoles = %d, want 0", granted)
+	}
+}
+
+func TestMemoryRecipeStoreAddImageLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	recipe := newRecipe("Tart")
+	if err := s.Create(ctx, &recipe); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.AddImage(ctx, recipe.ID, models.Image{ID: primitive.NewObjectID()}, 2); err != nil {
+			t.Fatalf("AddImage %d: %v", i, err)
+		}
+	}
+	if err := s.AddImage(ctx, recipe.ID, models.Image{ID: primitive.NewObjectID()}, 2); !errors.Is(err, ErrLimitReached) {
+		t.Errorf("AddImage beyond the limit = %v, want ErrLimitReached", err)
+	}
+	if err := s.AddImage(ctx, primitive.NewObjectID(), models.Image{}, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddImage to unknown recipe = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryRecipeStoreUpsertKeepsState(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	publishAt := time.Now().Add(time.Hour)
+	recipe := newRecipe("Tart")
+	recipe.Status = models.StatusInReview
+	recipe.PublishAt = &publishAt
+	recipe.Rating = models.Rating{Count: 2, Sum: 9, Average: 4.5}
+	if err := s.Create(ctx, &recipe); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddImage(ctx, recipe.ID, models.Image{ID: primitive.NewObjectID()}, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	imported := newRecipe("Apple tart")
+	imported.ID = recipe.ID
+	imported.Status = models.StatusInReview
+	if created, err := s.Upsert(ctx, imported); err != nil || created {
+		t.Fatalf("Upsert = %v, %v, want an update", created, err)
+	}
+	got, _ := s.Get(ctx, recipe.ID)
+	if got.Name != "Apple tart" || got.Rating.Count != 2 || len(got.Images) != 1 ||
+		got.PublishAt == nil || !got.PublishAt.Equal(publishAt) {
+		t.Errorf("after Upsert = %+v, want the new name with rating, image and schedule kept", got)
+	}
+
+	// A recipe imported as published is no longer scheduled.
+	imported.Status = models.StatusPublished
+	s.Upsert(ctx, imported)
+	if got, _ := s.Get(ctx, recipe.ID); got.PublishAt != nil {
+		t.Errorf("published recipe kept its schedule %v", got.PublishAt)
+	}
+
+	// A recipe in the trash stays there.
+	if err := s.Delete(ctx, recipe.ID); err != nil {
+		t.Fatal(err)
+	}
+	s.Upsert(ctx, imported)
+	if _, err := s.Get(ctx, recipe.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after upserting a trashed recipe = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryReviewStorePutRecomputesRating(t *testing.T) {
+	ctx := context.Background()
+	recipes := NewMemoryRecipeStore()
+	reviews := NewMemoryReviewStore(recipes)
+	recipe := newRecipe("Omelette")
+	if err := recipes.Create(ctx, &recipe); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Every author posts a first review and then edits it, all at once.
+	const authors = 20
+	var wg sync.WaitGroup
+	for i := 0; i < authors; i++ {
+		wg.Add(1)
+		go func(author string) {
+			defer wg.Done()
+			for _, stars := range []int{1, 4} {
+				_, err := reviews.Put(ctx, models.Review{ID: primitive.NewObjectID(), RecipeID: recipe.ID,
+					Author: author, Rating: stars, UpdatedAt: time.Now()})
+				if err != nil {
+					t.Errorf("Put: %v", err)
+				}
+			}
+		}(fmt.Sprintf("user%d", i))
+	}
+	wg.Wait()
+
+	got, err := recipes.Get(ctx, recipe.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Rating.Count != authors || got.Rating.Sum != 4*authors || got.Rating.Average != 4 {
+		t.Errorf("Rating = %+v, want %d reviews of 4 stars", got.Rating, authors)
+	}
+	_, total, err := reviews.List(ctx, recipe.ID, 0, 0)
+	if err != nil || total != authors {
+		t.Errorf("List total = %d, %v, want %d", total, err, authors)
+	}
+}
+
+func TestMemoryCollectionStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCollectionStore()
+	newCollection := func(owner string) models.Collection {
+		return models.Collection{ID: primitive.NewObjectID(), Owner: owner, CreatedAt: time.Now()}
+	}
+
+	first := newCollection("alice")
+	tests := []struct {
+		name       string
+		collection models.Collection
+		want       error
+	}{
+		{"below the limit", first, nil},
+		{"same ID", first, ErrDuplicate},
+		{"at the limit", newCollection("alice"), nil},
+		{"over the limit", newCollection("alice"), ErrLimitReached},
+		{"other owner", newCollection("bob"), nil},
+	}
+	for _, tt := range tests {
+		if err := s.Create(ctx, tt.collection, 2); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Create = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	recipeIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+	adds := []struct {
+		name     string
+		recipeID primitive.ObjectID
+		want     error
+	}{
+		{"first recipe", recipeIDs[0], nil},
+		{"same recipe", recipeIDs[0], ErrDuplicate},
+		{"second recipe", recipeIDs[1], nil},
+		{"over the limit", recipeIDs[2], ErrLimitReached},
+	}
+	for _, tt := range adds {
+		if err := s.AddRecipe(ctx, first.ID, tt.recipeID, -1, 2); !errors.Is(err, tt.want) {
+			t.Errorf("%s: AddRecipe = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if err := s.AddRecipe(ctx, primitive.NewObjectID(), recipeIDs[2], -1, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("AddRecipe to a missing collection = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,1200 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"recipes-api/models"
+	"regexp"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoRecipeStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRecipeStore(collection *mongo.Collection) *MongoRecipeStore {
+	return &MongoRecipeStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes creates the indexes backing the sort orders offered by List,
+// the search filters, the publication schedule and the trash. Recipes
+// stored before ratings were kept are given an empty rating first, so that
+// sorting by rating pages through them too, and recipes stored before
+// statuses were kept are marked published, as they were public already.
+func (s *MongoRecipeStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.UpdateMany(ctx, bson.M{"rating": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"rating": models.Rating{}}})
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.UpdateMany(ctx, bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": models.StatusPublished}})
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "publishedAt", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "rating.average", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "author", Value: 1}}},
+		{Keys: bson.D{{Key: "publishAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "totalTime", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "difficulty", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "cuisine", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "course", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "deletedAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "images._id", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{
+			Keys: bson.D{
+				{Key: "name", Value: "text"},
+				{Key: "ingredients", Value: "text"},
+				{Key: "instructions", Value: "text"},
+			},
+			Options: options.Index().SetName("recipes_text").SetWeights(bson.D{
+				{Key: "name", Value: nameWeight},
+				{Key: "ingredients", Value: ingredientsWeight},
+				{Key: "instructions", Value: instructionsWeight},
+			}),
+		},
+	})
+	return err
+}
+
+func (s *MongoRecipeStore) Create(ctx context.Context, recipe *models.Recipe) error {
+	_, err := s.collection.InsertOne(ctx, recipe)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoRecipeStore) Upsert(ctx context.Context, recipe models.Recipe) (bool, error) {
+	// The replacement is a pipeline so that the stored rating, images,
+	// trash state and, while the recipe stays in review, scheduled
+	// publication can be carried over in the same step, as the memory
+	// store's carryOver does. Fields missing from the stored recipe stay
+	// missing. $literal keeps values starting with $ from being read as
+	// field paths.
+	recipe.Images, recipe.DeletedAt, recipe.PublishAt = nil, nil, nil
+	kept := bson.M{
+		"rating":    bson.M{"$ifNull": bson.A{"$rating", bson.M{"$literal": models.Rating{}}}},
+		"images":    "$images",
+		"deletedAt": "$deletedAt",
+	}
+	if recipe.Status == models.StatusInReview {
+		kept["publishAt"] = "$publishAt"
+	}
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": recipe.ID}, mongo.Pipeline{
+		{{Key: "$replaceWith", Value: bson.M{"$mergeObjects": bson.A{bson.M{"$literal": recipe}, kept}}}},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount > 0, nil
+}
+
+func (s *MongoRecipeStore) Get(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := s.collection.FindOne(ctx, live(bson.M{"_id": id})).Decode(&recipe)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return recipe, ErrNotFound
+	}
+	return recipe, err
+}
+
+// live restricts a filter to documents that are not deleted: recipes
+// outside the trash, or accounts that are not tombstones.
+func live(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// trashed restricts a filter to recipes in the trash.
+func trashed(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": true}
+	return filter
+}
+
+func (s *MongoRecipeStore) List(ctx context.Context, opts ListOptions) ([]models.Recipe, error) {
+	findOptions := options.Find().SetSort(mongoSort(opts.Sort))
+	if opts.Limit > 0 {
+		findOptions.SetLimit(int64(opts.Limit))
+	}
+	if len(opts.Fields) > 0 {
+		findOptions.SetProjection(mongoProjection(opts.Fields, opts.Sort))
+	}
+	return s.find(ctx, live(mongoVisible(mongoAfter(opts.After, opts.Sort), opts.Visible)), findOptions)
+}
+
+// mongoVisible restricts a filter to the recipes visibility v allows.
+func mongoVisible(filter bson.M, v *Visibility) bson.M {
+	if v == nil {
+		return filter
+	}
+	allowed := bson.M{"status": models.StatusPublished}
+	if v.Author != "" {
+		allowed = bson.M{"$or": bson.A{allowed, bson.M{"author": v.Author}}}
+	}
+	return bson.M{"$and": bson.A{filter, allowed}}
+}
+
+func (s *MongoRecipeStore) Update(ctx context.Context, id primitive.ObjectID, recipe models.Recipe) error {
+	res, err := s.collection.UpdateOne(ctx, live(bson.M{
+		"_id": id,
+	}), bson.D{{Key: "$set", Value: editableFields(recipe)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoRecipeStore) UpdateIfUnchanged(ctx context.Context, id primitive.ObjectID, previous, recipe models.Recipe) error {
+	set := append(editableFields(recipe), bson.E{Key: "status", Value: recipe.Status})
+	res, err := s.collection.UpdateOne(ctx, unchanged(id, previous), bson.D{{Key: "$set", Value: set}})
+	if err != nil {
+		return err
+	}
+	return s.conditionalResult(ctx, id, res.MatchedCount)
+}
+
+func (s *MongoRecipeStore) DeleteIfUnchanged(ctx context.Context, id primitive.ObjectID, previous models.Recipe, deletedAt time.Time) error {
+	res, err := s.collection.UpdateOne(ctx, unchanged(id, previous), bson.M{"$set": bson.M{"deletedAt": deletedAt}})
+	if err != nil {
+		return err
+	}
+	return s.conditionalResult(ctx, id, res.MatchedCount)
+}
+
+// unchanged matches the live recipe id while its editable fields and
+// status equal those of previous.
+func unchanged(id primitive.ObjectID, previous models.Recipe) bson.M {
+	filter := live(bson.M{"_id": id})
+	for _, field := range append(editableFields(previous), bson.E{Key: "status", Value: previous.Status}) {
+		filter[field.Key] = field.Value
+		// Fields omitted from documents when empty match either their
+		// zero value or nothing.
+		switch value := field.Value.(type) {
+		case int:
+			if value == 0 {
+				filter[field.Key] = bson.M{"$in": bson.A{0, nil}}
+			}
+		case string:
+			if value == "" {
+				filter[field.Key] = bson.M{"$in": bson.A{"", nil}}
+			}
+		}
+	}
+	return filter
+}
+
+// conditionalResult tells apart the reasons a conditional write matched
+// nothing: the recipe is gone, or it has changed.
+func (s *MongoRecipeStore) conditionalResult(ctx context.Context, id primitive.ObjectID, matched int64) error {
+	if matched > 0 {
+		return nil
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+// editableFields are the fields Update writes.
+func editableFields(recipe models.Recipe) bson.D {
+	return bson.D{
+		{Key: "name", Value: recipe.Name},
+		{Key: "instructions", Value: recipe.Instructions},
+		{Key: "ingredients", Value: recipe.Ingredients},
+		{Key: "tags", Value: recipe.Tags},
+		{Key: "servings", Value: recipe.Servings},
+		{Key: "prepTime", Value: recipe.PrepTime},
+		{Key: "cookTime", Value: recipe.CookTime},
+		{Key: "totalTime", Value: recipe.TotalTime},
+		{Key: "difficulty", Value: recipe.Difficulty},
+		{Key: "cuisine", Value: recipe.Cuisine},
+		{Key: "course", Value: recipe.Course},
+		{Key: "nutrition", Value: recipe.Nutrition},
+	}
+}
+
+func (s *MongoRecipeStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.UpdateOne(ctx, live(bson.M{"_id": id}),
+		bson.M{"$set": bson.M{"deletedAt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoRecipeStore) Restore(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.UpdateOne(ctx, trashed(bson.M{"_id": id}),
+		bson.M{"$unset": bson.M{"deletedAt": ""}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoRecipeStore) GetDeleted(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := s.collection.FindOne(ctx, trashed(bson.M{"_id": id})).Decode(&recipe)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return recipe, ErrNotFound
+	}
+	return recipe, err
+}
+
+func (s *MongoRecipeStore) ListDeleted(ctx context.Context, author string, limit, offset int) ([]models.Recipe, int64, error) {
+	filter := trashed(bson.M{})
+	if author != "" {
+		filter["author"] = author
+	}
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	recipes, err := s.find(ctx, filter, findOptions)
+	return recipes, total, err
+}
+
+func (s *MongoRecipeStore) Purge(ctx context.Context, id primitive.ObjectID) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := s.collection.FindOneAndDelete(ctx, trashed(bson.M{"_id": id})).Decode(&recipe)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return recipe, ErrNotFound
+	}
+	return recipe, err
+}
+
+func (s *MongoRecipeStore) PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]models.Recipe, error) {
+	filter := bson.M{"deletedAt": bson.M{"$lt": cutoff}}
+	cur, err := s.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var expired []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &expired); err != nil {
+		return nil, err
+	}
+
+	// Each recipe is removed on its own, matching on deletedAt again, so
+	// that one restored since the lookup is neither removed nor reported.
+	purged := make([]models.Recipe, 0, len(expired))
+	for _, candidate := range expired {
+		var recipe models.Recipe
+		err := s.collection.FindOneAndDelete(ctx, bson.M{"_id": candidate.ID, "deletedAt": bson.M{"$lt": cutoff}}).Decode(&recipe)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, recipe)
+	}
+	return purged, nil
+}
+
+func (s *MongoRecipeStore) AddImage(ctx context.Context, id primitive.ObjectID, image models.Image, limit int) error {
+	// The image is only pushed while the array has no element at index
+	// limit-1, which checks the count in the same atomic step.
+	filter := live(bson.M{"_id": id, fmt.Sprintf("images.%d", limit-1): bson.M{"$exists": false}})
+	res, err := s.collection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"images": image}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrLimitReached
+	}
+	return nil
+}
+
+func (s *MongoRecipeStore) RemoveImage(ctx context.Context, id, imageID primitive.ObjectID) (models.Image, error) {
+	var recipe models.Recipe
+	err := s.collection.FindOneAndUpdate(ctx,
+		live(bson.M{"_id": id, "images._id": imageID}),
+		bson.M{"$pull": bson.M{"images": bson.M{"_id": imageID}}},
+		options.FindOneAndUpdate().SetProjection(bson.M{"images": 1}),
+	).Decode(&recipe)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.Image{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Image{}, err
+	}
+	// The document returned is the one from before the update.
+	for _, image := range recipe.Images {
+		if image.ID == imageID {
+			return image, nil
+		}
+	}
+	return models.Image{}, ErrNotFound
+}
+
+func (s *MongoRecipeStore) GetByImage(ctx context.Context, imageID primitive.ObjectID) (models.Recipe, error) {
+	var recipe models.Recipe
+	err := s.collection.FindOne(ctx, live(bson.M{"images._id": imageID})).Decode(&recipe)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return recipe, ErrNotFound
+	}
+	return recipe, err
+}
+
+func (s *MongoRecipeStore) SetStatus(ctx context.Context, id primitive.ObjectID, from string, recipe models.Recipe) error {
+	set := bson.M{"status": recipe.Status, "publishedAt": recipe.PublishedAt}
+	update := bson.M{"$set": set}
+	if recipe.PublishAt != nil {
+		set["publishAt"] = recipe.PublishAt
+	} else {
+		update["$unset"] = bson.M{"publishAt": ""}
+	}
+	res, err := s.collection.UpdateOne(ctx, live(bson.M{"_id": id, "status": from}), update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount > 0 {
+		return nil
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrConflict
+}
+
+func (s *MongoRecipeStore) PublishDue(ctx context.Context, now time.Time) ([]models.Recipe, error) {
+	due := live(bson.M{"status": models.StatusInReview, "publishAt": bson.M{"$lte": now}})
+	cur, err := s.collection.Find(ctx, due, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var candidates []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cur.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	// Each recipe is published on its own, matching on its status and
+	// schedule again, so that one withdrawn since the lookup is left alone.
+	published := make([]models.Recipe, 0, len(candidates))
+	for _, candidate := range candidates {
+		var recipe models.Recipe
+		err := s.collection.FindOneAndUpdate(ctx,
+			live(bson.M{"_id": candidate.ID, "status": models.StatusInReview, "publishAt": bson.M{"$lte": now}}),
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"status": models.StatusPublished, "publishedAt": "$publishAt"}}},
+				{{Key: "$unset", Value: "publishAt"}},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&recipe)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return published, err
+		}
+		published = append(published, recipe)
+	}
+	return published, nil
+}
+
+func (s *MongoRecipeStore) Search(ctx context.Context, query SearchQuery) ([]models.Recipe, int64, error) {
+	filter := mongoSearchFilter(query)
+
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().SetSkip(int64(query.Offset))
+	if query.Limit > 0 {
+		findOptions.SetLimit(int64(query.Limit))
+	}
+	if query.Text != "" {
+		findOptions.SetSort(bson.D{
+			{Key: "score", Value: bson.M{"$meta": "textScore"}},
+			{Key: "_id", Value: 1},
+		})
+	} else {
+		findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	}
+
+	recipes, err := s.find(ctx, filter, findOptions)
+	return recipes, total, err
+}
+
+func mongoSearchFilter(query SearchQuery) bson.M {
+	conditions := bson.A{}
+	if query.Text != "" {
+		conditions = append(conditions, bson.M{"$text": bson.M{"$search": query.Text}})
+	}
+	if len(query.Tags) > 0 {
+		op := "$all"
+		if query.TagMatch == TagMatchAny {
+			op = "$in"
+		}
+		conditions = append(conditions, bson.M{"tags": bson.M{op: query.Tags}})
+	}
+	if len(query.ExcludeTags) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$nin": query.ExcludeTags}})
+	}
+	for _, ingredient := range query.Ingredients {
+		conditions = append(conditions, bson.M{"ingredients": ingredientPattern(ingredient)})
+	}
+	for _, ingredient := range query.ExcludeIngredients {
+		conditions = append(conditions, bson.M{"ingredients": bson.M{"$not": ingredientPattern(ingredient)}})
+	}
+	if query.MaxTotalTime > 0 {
+		conditions = append(conditions, bson.M{"totalTime": bson.M{"$gt": 0, "$lte": query.MaxTotalTime}})
+	}
+	if query.MaxPrepTime > 0 {
+		conditions = append(conditions, bson.M{"prepTime": bson.M{"$gt": 0, "$lte": query.MaxPrepTime}})
+	}
+	if len(query.Difficulties) > 0 {
+		conditions = append(conditions, bson.M{"difficulty": bson.M{"$in": query.Difficulties}})
+	}
+	if len(query.Cuisines) > 0 {
+		conditions = append(conditions, bson.M{"cuisine": bson.M{"$in": query.Cuisines}})
+	}
+	if len(query.Courses) > 0 {
+		conditions = append(conditions, bson.M{"course": bson.M{"$in": query.Courses}})
+	}
+	if query.MaxCalories > 0 {
+		conditions = append(conditions, bson.M{"nutrition.calories": bson.M{"$lte": query.MaxCalories}})
+	}
+	if query.Visible != nil {
+		conditions = append(conditions, mongoVisible(bson.M{}, query.Visible))
+	}
+	conditions = append(conditions, live(bson.M{}))
+	return bson.M{"$and": conditions}
+}
+
+func ingredientPattern(ingredient string) primitive.Regex {
+	return primitive.Regex{Pattern: regexp.QuoteMeta(ingredient), Options: "i"}
+}
+
+func (s *MongoRecipeStore) find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) ([]models.Recipe, error) {
+	cur, err := s.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	recipes := make([]models.Recipe, 0)
+	for cur.Next(ctx) {
+		var recipe models.Recipe
+		if err := cur.Decode(&recipe); err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, recipe)
+	}
+	return recipes, cur.Err()
+}
+
+// bsonField maps a JSON field name of models.Recipe to its BSON name.
+func bsonField(field string) string {
+	if field == SortByID {
+		return "_id"
+	}
+	return field
+}
+
+// sortKey returns the document field a sort order is applied to.
+func sortKey(field string) string {
+	if field == SortByRating {
+		return "rating.average"
+	}
+	return bsonField(field)
+}
+
+func mongoSort(sort Sort) bson.D {
+	direction := 1
+	if sort.Descending {
+		direction = -1
+	}
+	if sort.Field == SortByID {
+		return bson.D{{Key: "_id", Value: direction}}
+	}
+	return bson.D{
+		{Key: sortKey(sort.Field), Value: direction},
+		{Key: "_id", Value: direction},
+	}
+}
+
+// mongoAfter builds the keyset filter selecting documents after cursor.
+func mongoAfter(cursor *Cursor, sort Sort) bson.M {
+	if cursor == nil {
+		return bson.M{}
+	}
+	op := "$gt"
+	if sort.Descending {
+		op = "$lt"
+	}
+	if sort.Field == SortByID {
+		return bson.M{"_id": bson.M{op: cursor.ID}}
+	}
+
+	var value interface{} = cursor.Name
+	switch sort.Field {
+	case SortByPublishedAt:
+		value = cursor.PublishedAt
+	case SortByRating:
+		value = cursor.Rating
+	}
+	field := sortKey(sort.Field)
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: value}},
+		bson.M{field: value, "_id": bson.M{op: cursor.ID}},
+	}}
+}
+
+// mongoProjection always keeps the sort key so that a cursor can be built
+// from the last document of a page. A key inside a projected field is
+// covered by it; projecting both would be a path collision.
+func mongoProjection(fields []string, sort Sort) bson.M {
+	projection := bson.M{}
+	for _, field := range fields {
+		projection[bsonField(field)] = 1
+	}
+	if !slices.Contains(fields, sort.Field) {
+		projection[sortKey(sort.Field)] = 1
+	}
+	return projection
+}
+
+type MongoUserStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserStore(collection *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes enforces unique usernames. Earlier releases inserted the
+// seed users on every boot; databases still holding such duplicates must
+// be cleaned up with RemoveDuplicateUsers before the index can be built.
+func (s *MongoUserStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: duplicate usernames found, run the dedupe-users command first", err)
+	}
+	return err
+}
+
+// RemoveDuplicateUsers deletes every user document that repeats the
+// username of an older one and returns how many were deleted. It is a
+// one-off migration for databases written by releases that seeded users
+// on every boot.
+func (s *MongoUserStore) RemoveDuplicateUsers(ctx context.Context) (int64, error) {
+	cur, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$username",
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+	var removed int64
+	for cur.Next(ctx) {
+		var group struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cur.Decode(&group); err != nil {
+			return removed, err
+		}
+		res, err := s.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": group.IDs[1:]}})
+		if err != nil {
+			return removed, err
+		}
+		removed += res.DeletedCount
+	}
+	return removed, cur.Err()
+}
+
+func (s *MongoUserStore) CreateUser(ctx context.Context, user models.User) error {
+	_, err := s.collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoUserStore) GetUser(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := s.collection.FindOne(ctx, live(bson.M{"username": username})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func (s *MongoUserStore) ListUsers(ctx context.Context) ([]models.User, error) {
+	cur, err := s.collection.Find(ctx, live(bson.M{}), options.Find().SetSort(bson.D{{Key: "username", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	users := make([]models.User, 0)
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *MongoUserStore) UpdatePassword(ctx context.Context, username, passwordHash string) error {
+	return s.updateUser(ctx, username, bson.M{"$set": bson.M{"password": passwordHash}})
+}
+
+func (s *MongoUserStore) ReplacePassword(ctx context.Context, username, from, to string) error {
+	res, err := s.collection.UpdateOne(ctx, live(bson.M{"username": username, "password": from}),
+		bson.M{"$set": bson.M{"password": to}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		if _, err := s.GetUser(ctx, username); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *MongoUserStore) ChangePassword(ctx context.Context, username, passwordHash string) error {
+	return s.updateUser(ctx, username, bson.M{
+		"$set": bson.M{"password": passwordHash},
+		"$inc": bson.M{"tokenVersion": 1},
+	})
+}
+
+func (s *MongoUserStore) SetDisabled(ctx context.Context, username string, disabled bool) error {
+	return s.updateUser(ctx, username, bson.M{"$set": bson.M{"disabled": disabled}})
+}
+
+func (s *MongoUserStore) SetRoles(ctx context.Context, username string, roles []string) error {
+	return s.updateUser(ctx, username, bson.M{"$set": bson.M{"roles": roles}})
+}
+
+func (s *MongoUserStore) GrantMissingRoles(ctx context.Context, roles []string) (int64, error) {
+	// null also matches documents without the field.
+	res, err := s.collection.UpdateMany(ctx, live(bson.M{"roles": bson.M{"$in": bson.A{nil, bson.A{}}}}),
+		bson.M{"$set": bson.M{"roles": roles}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (s *MongoUserStore) DeleteUser(ctx context.Context, username string) error {
+	return s.updateUser(ctx, username, bson.M{
+		"$set":   bson.M{"disabled": true, "deletedAt": time.Now()},
+		"$unset": bson.M{"password": "", "roles": ""},
+		"$inc":   bson.M{"tokenVersion": 1},
+	})
+}
+
+func (s *MongoUserStore) updateUser(ctx context.Context, username string, update bson.M) error {
+	res, err := s.collection.UpdateOne(ctx, live(bson.M{"username": username}), update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type MongoRevisionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRevisionStore(collection *mongo.Collection) *MongoRevisionStore {
+	return &MongoRevisionStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes makes revision numbers unique per recipe, which Append
+// relies on to detect concurrent writers.
+func (s *MongoRevisionStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "recipeId", Value: 1}, {Key: "rev", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (s *MongoRevisionStore) Append(ctx context.Context, revision models.Revision) error {
+	_, err := s.collection.InsertOne(ctx, revision)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoRevisionStore) Latest(ctx context.Context, recipeID primitive.ObjectID) (models.Revision, error) {
+	return s.findOne(ctx, bson.M{"recipeId": recipeID},
+		options.FindOne().SetSort(bson.D{{Key: "rev", Value: -1}}))
+}
+
+func (s *MongoRevisionStore) Get(ctx context.Context, recipeID primitive.ObjectID, number int) (models.Revision, error) {
+	return s.findOne(ctx, bson.M{"recipeId": recipeID, "rev": number})
+}
+
+func (s *MongoRevisionStore) findOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) (models.Revision, error) {
+	var revision models.Revision
+	err := s.collection.FindOne(ctx, filter, opts...).Decode(&revision)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return revision, ErrNotFound
+	}
+	return revision, err
+}
+
+func (s *MongoRevisionStore) List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Revision, int64, error) {
+	filter := bson.M{"recipeId": recipeID}
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "rev", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetProjection(bson.M{"recipe": 0})
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cur, err := s.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	revisions := make([]models.Revision, 0)
+	if err := cur.All(ctx, &revisions); err != nil {
+		return nil, 0, err
+	}
+	return revisions, total, nil
+}
+
+func (s *MongoRevisionStore) DeleteHistory(ctx context.Context, recipeID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"recipeId": recipeID})
+	return err
+}
+
+// MongoReviewStore keeps reviews in collection and writes the ratings
+// they add up to into the recipes collection of the same database.
+type MongoReviewStore struct {
+	collection *mongo.Collection
+	recipes    *mongo.Collection
+}
+
+func NewMongoReviewStore(collection, recipes *mongo.Collection) *MongoReviewStore {
+	return &MongoReviewStore{
+		collection: collection,
+		recipes:    recipes,
+	}
+}
+
+// EnsureIndexes allows one review per user and recipe and backs the
+// listing of a recipe's reviews.
+func (s *MongoReviewStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "recipeId", Value: 1}, {Key: "author", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "recipeId", Value: 1}, {Key: "updatedAt", Value: -1}}},
+	})
+	return err
+}
+
+func (s *MongoReviewStore) Put(ctx context.Context, review models.Review) (*models.Review, error) {
+	filter := bson.M{"recipeId": review.RecipeID, "author": review.Author}
+	update := bson.M{
+		"$set":         bson.M{"rating": review.Rating, "text": review.Text, "updatedAt": review.UpdatedAt},
+		"$setOnInsert": bson.M{"_id": review.ID, "createdAt": review.CreatedAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	var previous models.Review
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent first review by the same user won the insert; this
+		// one now replaces it.
+		err = s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	}
+	var replaced *models.Review
+	if err == nil {
+		replaced = &previous
+	} else if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return replaced, s.updateRating(ctx, review.RecipeID)
+}
+
+// updateRating recomputes the rating of a recipe from its reviews in one
+// aggregation that merges the result into the recipe. Of two concurrent
+// updates the one that saw fewer reviews may finish last, so a rating is
+// only replaced by one counting reviews at least as new as its own.
+func (s *MongoReviewStore) updateRating(ctx context.Context, recipeID primitive.ObjectID) error {
+	cur, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"recipeId": recipeID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        "$recipeId",
+			"sum":        bson.M{"$sum": "$rating"},
+			"count":      bson.M{"$sum": 1},
+			"reviewedAt": bson.M{"$max": "$updatedAt"},
+		}}},
+		{{Key: "$project", Value: bson.M{"rating": bson.M{
+			"sum":        "$sum",
+			"count":      "$count",
+			"average":    bson.M{"$round": bson.A{bson.M{"$divide": bson.A{"$sum", "$count"}}, 2}},
+			"reviewedAt": "$reviewedAt",
+		}}}},
+		{{Key: "$merge", Value: bson.M{
+			"into": bson.M{"db": s.recipes.Database().Name(), "coll": s.recipes.Name()},
+			"on":   "_id",
+			"whenMatched": mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"rating": bson.M{"$cond": bson.A{
+					bson.M{"$gte": bson.A{"$$new.rating.reviewedAt", "$rating.reviewedAt"}},
+					"$$new.rating",
+					"$rating",
+				}}}}},
+			},
+			"whenNotMatched": "discard",
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	return cur.Close(ctx)
+}
+
+func (s *MongoReviewStore) List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Review, int64, error) {
+	filter := bson.M{"recipeId": recipeID}
+	total, err := s.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "updatedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64(offset))
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
+	cur, err := s.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, 0, err
+	}
+	reviews := make([]models.Review, 0)
+	if err := cur.All(ctx, &reviews); err != nil {
+		return nil, 0, err
+	}
+	return reviews, total, nil
+}
+
+func (s *MongoReviewStore) DeleteReviews(ctx context.Context, recipeID primitive.ObjectID) error {
+	_, err := s.collection.DeleteMany(ctx, bson.M{"recipeId": recipeID})
+	return err
+}
+
+type MongoCollectionStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCollectionStore(collection *mongo.Collection) *MongoCollectionStore {
+	return &MongoCollectionStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes backs the listing of a user's collections and the cascade
+// on recipe deletion, and allows one Favourites collection per user, which
+// Favourites relies on when two requests create it at once.
+func (s *MongoCollectionStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "owner", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "recipeIds", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "owner", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("owner_favourites").
+				SetPartialFilterExpression(bson.M{"favourites": true}),
+		},
+	})
+	return err
+}
+
+// Create inserts the collection first and then counts the collections of
+// its owner created up to it. Of concurrent creators over the limit only
+// the ones created first keep theirs; the others take theirs out again.
+func (s *MongoCollectionStore) Create(ctx context.Context, collection models.Collection, limit int) error {
+	_, err := s.collection.InsertOne(ctx, collection)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	rank, err := s.collection.CountDocuments(ctx, bson.M{"owner": collection.Owner, "$or": bson.A{
+		bson.M{"createdAt": bson.M{"$lt": collection.CreatedAt}},
+		bson.M{"createdAt": collection.CreatedAt, "_id": bson.M{"$lte": collection.ID}},
+	}})
+	if err == nil && rank <= int64(limit) {
+		return nil
+	}
+	if _, deleteErr := s.collection.DeleteOne(ctx, bson.M{"_id": collection.ID}); deleteErr != nil {
+		return deleteErr
+	}
+	if err != nil {
+		return err
+	}
+	return ErrLimitReached
+}
+
+func (s *MongoCollectionStore) Get(ctx context.Context, id primitive.ObjectID) (models.Collection, error) {
+	var collection models.Collection
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&collection)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return collection, ErrNotFound
+	}
+	return collection, err
+}
+
+func (s *MongoCollectionStore) Favourites(ctx context.Context, owner string) (models.Collection, error) {
+	now := time.Now()
+	filter := bson.M{"owner": owner, "favourites": true}
+	update := bson.M{"$setOnInsert": bson.M{
+		"_id":       primitive.NewObjectID(),
+		"name":      models.FavouritesName,
+		"shared":    false,
+		"recipeIds": bson.A{},
+		"createdAt": now,
+		"updatedAt": now,
+	}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var collection models.Collection
+	err := s.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&collection)
+	if mongo.IsDuplicateKeyError(err) {
+		// Created by a concurrent request in the meantime.
+		err = s.collection.FindOne(ctx, filter).Decode(&collection)
+	}
+	return collection, err
+}
+
+func (s *MongoCollectionStore) ListByOwner(ctx context.Context, owner string) ([]models.Collection, error) {
+	cur, err := s.collection.Find(ctx, bson.M{"owner": owner}, options.Find().
+		SetSort(bson.D{{Key: "favourites", Value: -1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	collections := make([]models.Collection, 0)
+	if err := cur.All(ctx, &collections); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+func (s *MongoCollectionStore) Update(ctx context.Context, collection models.Collection) error {
+	return s.updateOne(ctx, bson.M{"_id": collection.ID}, bson.M{"$set": bson.M{
+		"name":        collection.Name,
+		"description": collection.Description,
+		"shared":      collection.Shared,
+		"updatedAt":   collection.UpdatedAt,
+	}})
+}
+
+func (s *MongoCollectionStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoCollectionStore) AddRecipe(ctx context.Context, id, recipeID primitive.ObjectID, position, limit int) error {
+	push := bson.M{"$each": bson.A{recipeID}}
+	if position >= 0 {
+		push["$position"] = position
+	}
+	// Like AddImage, the recipe is only pushed while the array has no
+	// element at index limit-1.
+	filter := bson.M{"_id": id, "recipeIds": bson.M{"$ne": recipeID}}
+	filter[fmt.Sprintf("recipeIds.%d", limit-1)] = bson.M{"$exists": false}
+	err := s.updateOne(ctx, filter, bson.M{
+		"$push": bson.M{"recipeIds": push},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+	if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	collection, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(collection.RecipeIDs, recipeID) {
+		return ErrDuplicate
+	}
+	return ErrLimitReached
+}
+
+func (s *MongoCollectionStore) RemoveRecipe(ctx context.Context, id, recipeID primitive.ObjectID) error {
+	return s.updateOne(ctx, bson.M{"_id": id, "recipeIds": recipeID}, bson.M{
+		"$pull": bson.M{"recipeIds": recipeID},
+		"$set":  bson.M{"updatedAt": time.Now()},
+	})
+}
+
+func (s *MongoCollectionStore) ReorderRecipes(ctx context.Context, id primitive.ObjectID, previous, order []primitive.ObjectID) error {
+	// An empty order is stored as an empty array, which a nil slice would
+	// not match.
+	if previous == nil {
+		previous = make([]primitive.ObjectID, 0)
+	}
+	err := s.updateOne(ctx, bson.M{"_id": id, "recipeIds": previous}, bson.M{"$set": bson.M{
+		"recipeIds": order,
+		"updatedAt": time.Now(),
+	}})
+	if errors.Is(err, ErrNotFound) {
+		if _, getErr := s.Get(ctx, id); getErr == nil {
+			return ErrConflict
+		}
+	}
+	return err
+}
+
+func (s *MongoCollectionStore) RemoveRecipeEverywhere(ctx context.Context, recipeID primitive.ObjectID) error {
+	_, err := s.collection.UpdateMany(ctx, bson.M{"recipeIds": recipeID},
+		bson.M{"$pull": bson.M{"recipeIds": recipeID}})
+	return err
+}
+
+// updateOne applies update to the document matching filter, returning
+// ErrNotFound when there is none.
+func (s *MongoCollectionStore) updateOne(ctx context.Context, filter, update bson.M) error {
+	res, err := s.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type MongoMealPlanStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoMealPlanStore(collection *mongo.Collection) *MongoMealPlanStore {
+	return &MongoMealPlanStore{
+		collection: collection,
+	}
+}
+
+// EnsureIndexes backs the listing of a user's plan over a date range.
+func (s *MongoMealPlanStore) EnsureIndexes(ctx context.Context) error {
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "owner", Value: 1}, {Key: "date", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	return err
+}
+
+func (s *MongoMealPlanStore) Create(ctx context.Context, entry models.MealPlanEntry) error {
+	_, err := s.collection.InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (s *MongoMealPlanStore) Get(ctx context.Context, id primitive.ObjectID) (models.MealPlanEntry, error) {
+	var entry models.MealPlanEntry
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return entry, ErrNotFound
+	}
+	return entry, err
+}
+
+func (s *MongoMealPlanStore) List(ctx context.Context, owner, from, to string) ([]models.MealPlanEntry, error) {
+	cur, err := s.collection.Find(ctx,
+		bson.M{"owner": owner, "date": bson.M{"$gte": from, "$lte": to}},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]models.MealPlanEntry, 0)
+	if err := cur.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (s *MongoMealPlanStore) Update(ctx context.Context, entry models.MealPlanEntry) error {
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": entry.ID}, bson.M{"$set": bson.M{
+		"date":      entry.Date,
+		"slot":      entry.Slot,
+		"recipeId":  entry.RecipeID,
+		"servings":  entry.Servings,
+		"note":      entry.Note,
+		"updatedAt": entry.UpdatedAt,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoMealPlanStore) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
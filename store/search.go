@@ -0,0 +1,164 @@
+package store
+
+import (
+	"recipes-api/models"
+	"strings"
+	"unicode"
+)
+
+const (
+	TagMatchAll = "all"
+	TagMatchAny = "any"
+)
+
+// SearchQuery combines every criterion accepted by /recipes/search. Empty
+// criteria are ignored. When Text is set, results are ranked by relevance;
+// otherwise they are returned in ID order.
+type SearchQuery struct {
+	Text               string
+	Tags               []string
+	TagMatch           string
+	ExcludeTags        []string
+	Ingredients        []string
+	ExcludeIngredients []string
+	// MaxTotalTime and MaxPrepTime are in minutes. Recipes that do not
+	// state the time are left out when they are set.
+	MaxTotalTime int
+	MaxPrepTime  int
+	Difficulties []string
+	Cuisines     []string
+	Courses      []string
+	// MaxCalories bounds the calories per serving. Recipes without
+	// nutrition facts are left out when it is set.
+	MaxCalories float64
+	// Visible limits the matches as in ListOptions.
+	Visible *Visibility
+	Limit   int
+	Offset  int
+}
+
+// Relevance weights of the text-indexed fields.
+const (
+	nameWeight         = 10
+	ingredientsWeight  = 5
+	instructionsWeight = 1
+)
+
+// matchesFilters applies every criterion except the free-text query.
+func (q SearchQuery) matchesFilters(recipe models.Recipe) bool {
+	if !q.Visible.allows(recipe) {
+		return false
+	}
+	if len(q.Tags) > 0 {
+		matched := 0
+		for _, tag := range q.Tags {
+			if containsString(recipe.Tags, tag) {
+				matched++
+			}
+		}
+		if q.TagMatch == TagMatchAny && matched == 0 {
+			return false
+		}
+		if q.TagMatch != TagMatchAny && matched < len(q.Tags) {
+			return false
+		}
+	}
+	for _, tag := range q.ExcludeTags {
+		if containsString(recipe.Tags, tag) {
+			return false
+		}
+	}
+	for _, ingredient := range q.Ingredients {
+		if !containsSubstring(recipe.Ingredients, ingredient) {
+			return false
+		}
+	}
+	for _, ingredient := range q.ExcludeIngredients {
+		if containsSubstring(recipe.Ingredients, ingredient) {
+			return false
+		}
+	}
+	if q.MaxTotalTime > 0 && (recipe.TotalTime == 0 || recipe.TotalTime > q.MaxTotalTime) {
+		return false
+	}
+	if q.MaxPrepTime > 0 && (recipe.PrepTime == 0 || recipe.PrepTime > q.MaxPrepTime) {
+		return false
+	}
+	if len(q.Difficulties) > 0 && !containsString(q.Difficulties, recipe.Difficulty) {
+		return false
+	}
+	if len(q.Cuisines) > 0 && !containsString(q.Cuisines, recipe.Cuisine) {
+		return false
+	}
+	if len(q.Courses) > 0 && !containsString(q.Courses, recipe.Course) {
+		return false
+	}
+	if q.MaxCalories > 0 && (recipe.Nutrition == nil || recipe.Nutrition.Calories == nil ||
+		*recipe.Nutrition.Calories > q.MaxCalories) {
+		return false
+	}
+	return true
+}
+
+// textScore approximates MongoDB's text search: the query is split into
+// terms, any matching term qualifies the recipe, and every occurrence adds
+// the weight of the field it was found in.
+func textScore(recipe models.Recipe, text string) float64 {
+	terms := tokenize(text)
+	score := 0.0
+	for _, term := range terms {
+		score += nameWeight * countTerm([]string{recipe.Name}, term)
+		score += ingredientsWeight * countTerm(recipe.Ingredients, term)
+		score += instructionsWeight * countTerm(recipe.Instructions, term)
+	}
+	return score
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func countTerm(values []string, term string) float64 {
+	count := 0.0
+	for _, value := range values {
+		for _, word := range tokenize(value) {
+			if word == term {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSubstring(values []string, target string) bool {
+	target = strings.ToLower(target)
+	for _, value := range values {
+		if strings.Contains(strings.ToLower(value), target) {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate applies offset and limit to an already ordered result set.
+func paginate(recipes []models.Recipe, offset, limit int) []models.Recipe {
+	if offset >= len(recipes) {
+		return make([]models.Recipe, 0)
+	}
+	recipes = recipes[offset:]
+	if limit > 0 && len(recipes) > limit {
+		recipes = recipes[:limit]
+	}
+	return recipes
+}
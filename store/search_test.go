@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"recipes-api/models"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryRecipeStoreSearch(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryRecipeStore()
+	calories := func(value float64) *models.Nutrition {
+		return &models.Nutrition{Calories: &value}
+	}
+	recipes := []models.Recipe{
+		{Name: "Tomato soup", Tags: []string{"soup", "vegetarian"}, Ingredients: []string{"1 kg tomatoes", "1 onion"},
+			Instructions: []string{"Simmer the tomatoes"}, TotalTime: 40, PrepTime: 10, Difficulty: "easy",
+			Cuisine: "italian", Course: "starter", Nutrition: calories(120)},
+		{Name: "Beef stew", Tags: []string{"stew"}, Ingredients: []string{"1 kg beef", "2 tomatoes"},
+			Instructions: []string{"Brown the beef"}, TotalTime: 180, PrepTime: 30, Difficulty: "medium",
+			Cuisine: "french", Course: "main", Nutrition: calories(650)},
+		{Name: "Onion soup", Tags: []string{"soup"}, Ingredients: []string{"1 kg onions", "1 l beef stock"},
+			Instructions: []string{"Caramelise the onions"}, Difficulty: "medium", Cuisine: "french", Course: "starter"},
+		{Name: "Secret soup", Tags: []string{"soup"}, Ingredients: []string{"1 tomato"}, Author: "alice",
+			Status: models.StatusDraft},
+		{Name: "Deleted soup", Tags: []string{"soup"}, Ingredients: []string{"1 tomato"}},
+	}
+	for i := range recipes {
+		recipes[i].ID = primitive.NewObjectID()
+		if recipes[i].Status == "" {
+			recipes[i].Status = models.StatusPublished
+		}
+		if err := s.Create(ctx, &recipes[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete(ctx, recipes[4].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	public := &Visibility{}
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string
+	}{
+		// A match in the name outweighs matches in the ingredients.
+		{"text by relevance", SearchQuery{Text: "tomato tomatoes", Visible: public},
+			[]string{"Tomato soup", "Beef stew"}},
+		{"text without match", SearchQuery{Text: "chocolate", Visible: public}, nil},
+		{"all tags", SearchQuery{Tags: []string{"soup", "vegetarian"}, Visible: public},
+			[]string{"Tomato soup"}},
+		{"any tag", SearchQuery{Tags: []string{"stew", "vegetarian"}, TagMatch: TagMatchAny, Visible: public},
+			[]string{"Tomato soup", "Beef stew"}},
+		{"excluded tag", SearchQuery{ExcludeTags: []string{"soup"}, Visible: public}, []string{"Beef stew"}},
+		{"ingredient substring", SearchQuery{Ingredients: []string{"Beef"}, Visible: public},
+			[]string{"Beef stew", "Onion soup"}},
+		{"excluded ingredient", SearchQuery{ExcludeIngredients: []string{"tomato"}, Visible: public},
+			[]string{"Onion soup"}},
+		// Recipes without a stated time are left out by a time limit.
+		{"total time", SearchQuery{MaxTotalTime: 60, Visible: public}, []string{"Tomato soup"}},
+		{"prep time", SearchQuery{MaxPrepTime: 30, Visible: public}, []string{"Tomato soup", "Beef stew"}},
+		{"difficulty", SearchQuery{Difficulties: []string{"medium"}, Visible: public},
+			[]string{"Beef stew", "Onion soup"}},
+		{"cuisine and course", SearchQuery{Cuisines: []string{"french"}, Courses: []string{"starter"}, Visible: public},
+			[]string{"Onion soup"}},
+		{"calories", SearchQuery{MaxCalories: 500, Visible: public}, []string{"Tomato soup"}},
+		{"own drafts", SearchQuery{Text: "soup", Visible: &Visibility{Author: "alice"}},
+			[]string{"Tomato soup", "Onion soup", "Secret soup"}},
+		{"unrestricted", SearchQuery{Tags: []string{"soup"}}, []string{"Tomato soup", "Onion soup", "Secret soup"}},
+		{"page", SearchQuery{Tags: []string{"soup"}, Offset: 1, Limit: 1}, []string{"Onion soup"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, total, err := s.Search(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, recipe := range found {
+				got = append(got, recipe.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Search = %q, want %q", got, tt.want)
+			}
+			if tt.query.Limit == 0 && total != int64(len(tt.want)) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
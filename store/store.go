@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"recipes-api/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("already exists")
+	ErrConflict  = errors.New("modified concurrently")
+	// ErrLimitReached is returned by writes that would grow a list beyond
+	// the limit passed to them.
+	ErrLimitReached = errors.New("limit reached")
+)
+
+// RecipeStore is the persistence boundary used by RecipesHandler.
+type RecipeStore interface {
+	// Create inserts a new recipe. It returns ErrDuplicate when a recipe
+	// with the same ID exists.
+	Create(ctx context.Context, recipe *models.Recipe) error
+	// Upsert stores the recipe as given, replacing any recipe with the same
+	// ID except for its rating, images, trash state and, while the recipe
+	// stays in review, its scheduled publication. It reports whether the
+	// recipe was newly created.
+	Upsert(ctx context.Context, recipe models.Recipe) (created bool, err error)
+	Get(ctx context.Context, id primitive.ObjectID) (models.Recipe, error)
+	List(ctx context.Context, opts ListOptions) ([]models.Recipe, error)
+	Update(ctx context.Context, id primitive.ObjectID, recipe models.Recipe) error
+	// UpdateIfUnchanged is Update for read-modify-write cycles: it returns
+	// ErrConflict, and changes nothing, unless the editable fields and
+	// status of the stored recipe still equal those of previous. It writes
+	// the status of recipe along with its editable fields, so that an edit
+	// can send a recipe back to review in the same step.
+	UpdateIfUnchanged(ctx context.Context, id primitive.ObjectID, previous, recipe models.Recipe) error
+	// Delete moves a recipe to the trash. Recipes in the trash are left
+	// out of Get, List, Search and Update until they are restored.
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// DeleteIfUnchanged is Delete for recipes read before: like
+	// UpdateIfUnchanged it returns ErrConflict, and changes nothing, unless
+	// the editable fields and status of the stored recipe still equal those
+	// of previous. The recipe is recorded as deleted at deletedAt.
+	DeleteIfUnchanged(ctx context.Context, id primitive.ObjectID, previous models.Recipe, deletedAt time.Time) error
+	// Restore takes a recipe out of the trash.
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// GetDeleted returns a recipe in the trash.
+	GetDeleted(ctx context.Context, id primitive.ObjectID) (models.Recipe, error)
+	// ListDeleted returns one page of the trash, most recently deleted
+	// first, and its total size. A non-empty author limits it to the
+	// recipes of that user.
+	ListDeleted(ctx context.Context, author string, limit, offset int) ([]models.Recipe, int64, error)
+	// Purge permanently removes a recipe in the trash and returns it.
+	Purge(ctx context.Context, id primitive.ObjectID) (models.Recipe, error)
+	// PurgeDeletedBefore permanently removes the recipes deleted before
+	// cutoff and returns them.
+	PurgeDeletedBefore(ctx context.Context, cutoff time.Time) ([]models.Recipe, error)
+	// AddImage appends an image to a recipe. It returns ErrLimitReached
+	// when the recipe already has limit images.
+	AddImage(ctx context.Context, id primitive.ObjectID, image models.Image, limit int) error
+	// RemoveImage removes an image from a recipe and returns it.
+	RemoveImage(ctx context.Context, id, imageID primitive.ObjectID) (models.Image, error)
+	// GetByImage returns the recipe outside the trash that has the image.
+	GetByImage(ctx context.Context, imageID primitive.ObjectID) (models.Recipe, error)
+	// SetStatus writes the Status, PublishAt and PublishedAt of recipe. It
+	// returns ErrConflict, and changes nothing, unless the stored recipe is
+	// still in status from.
+	SetStatus(ctx context.Context, id primitive.ObjectID, from string, recipe models.Recipe) error
+	// PublishDue publishes the recipes in review whose PublishAt is not
+	// after now and returns them as published.
+	PublishDue(ctx context.Context, now time.Time) ([]models.Recipe, error)
+	// Search returns one page of matching recipes and the total number of
+	// matches.
+	Search(ctx context.Context, query SearchQuery) ([]models.Recipe, int64, error)
+}
+
+// UserStore is the persistence boundary used by AuthHandler. Usernames are
+// unique; CreateUser returns ErrDuplicate for a taken username. Deleted
+// accounts keep their username: every other method treats them as not
+// found, but CreateUser still reports the name as taken.
+type UserStore interface {
+	CreateUser(ctx context.Context, user models.User) error
+	GetUser(ctx context.Context, username string) (models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	// UpdatePassword replaces the stored hash without affecting sessions.
+	UpdatePassword(ctx context.Context, username, passwordHash string) error
+	// ReplacePassword replaces the stored hash with to, without affecting
+	// sessions, if it still is from. It returns ErrConflict otherwise.
+	ReplacePassword(ctx context.Context, username, from, to string) error
+	// ChangePassword replaces the stored hash and bumps the token version,
+	// invalidating every existing session of the user.
+	ChangePassword(ctx context.Context, username, passwordHash string) error
+	SetDisabled(ctx context.Context, username string, disabled bool) error
+	SetRoles(ctx context.Context, username string, roles []string) error
+	// GrantMissingRoles gives roles to every account that has none, such
+	// as accounts created before roles existed, and returns how many there
+	// were.
+	GrantMissingRoles(ctx context.Context, roles []string) (int64, error)
+	// DeleteUser leaves a tombstone in place of the account and bumps its
+	// token version, so that its sessions and tokens stop working.
+	DeleteUser(ctx context.Context, username string) error
+}
+
+// RevisionStore keeps the history of every recipe.
+type RevisionStore interface {
+	// Append stores a revision. It returns ErrDuplicate when the recipe
+	// already has a revision with the same number, so that concurrent
+	// writers cannot both claim it.
+	Append(ctx context.Context, revision models.Revision) error
+	// Latest returns the newest revision of a recipe, or ErrNotFound for
+	// recipes without history.
+	Latest(ctx context.Context, recipeID primitive.ObjectID) (models.Revision, error)
+	Get(ctx context.Context, recipeID primitive.ObjectID, number int) (models.Revision, error)
+	// List returns one page of revisions, newest first and without their
+	// recipe snapshots, and the total number of revisions.
+	List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Revision, int64, error)
+	// DeleteHistory removes every revision of a recipe.
+	DeleteHistory(ctx context.Context, recipeID primitive.ObjectID) error
+}
+
+// ReviewStore keeps the reviews of recipes, at most one per user and
+// recipe.
+type ReviewStore interface {
+	// Put stores a review, replacing the one its author wrote earlier for
+	// the same recipe, and returns the replaced review or nil. The ID and
+	// CreatedAt of a replaced review are kept. The rating of the recipe,
+	// trashed or not, is then recomputed from all of its reviews, so that
+	// concurrent reviews cannot leave it counting some of them twice or not
+	// at all.
+	Put(ctx context.Context, review models.Review) (*models.Review, error)
+	// List returns one page of the reviews of a recipe, most recently
+	// updated first, and the total number of reviews.
+	List(ctx context.Context, recipeID primitive.ObjectID, limit, offset int) ([]models.Review, int64, error)
+	// DeleteReviews removes every review of a recipe.
+	DeleteReviews(ctx context.Context, recipeID primitive.ObjectID) error
+}
+
+// CollectionStore keeps the recipe collections of users.
+type CollectionStore interface {
+	// Create inserts a collection. It returns ErrLimitReached when its
+	// owner already has limit collections, Favourites included.
+	Create(ctx context.Context, collection models.Collection, limit int) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.Collection, error)
+	// Favourites returns the Favourites collection of owner, creating it
+	// when the user has none yet.
+	Favourites(ctx context.Context, owner string) (models.Collection, error)
+	// ListByOwner returns the collections of a user, Favourites first and
+	// the rest in the order they were created.
+	ListByOwner(ctx context.Context, owner string) ([]models.Collection, error)
+	// Update writes the name, description, sharing and update time of a
+	// collection.
+	Update(ctx context.Context, collection models.Collection) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// AddRecipe inserts a recipe at position, or at the end when position
+	// is negative or past the end. It returns ErrDuplicate when the recipe
+	// is in the collection already and ErrLimitReached when the collection
+	// already holds limit recipes.
+	AddRecipe(ctx context.Context, id, recipeID primitive.ObjectID, position, limit int) error
+	// RemoveRecipe takes a recipe out of a collection. It returns
+	// ErrNotFound when the recipe is not in it.
+	RemoveRecipe(ctx context.Context, id, recipeID primitive.ObjectID) error
+	// ReorderRecipes replaces the recipe order of a collection. It returns
+	// ErrConflict, and changes nothing, unless the stored order still
+	// equals previous.
+	ReorderRecipes(ctx context.Context, id primitive.ObjectID, previous, order []primitive.ObjectID) error
+	// RemoveRecipeEverywhere takes a recipe out of every collection.
+	RemoveRecipeEverywhere(ctx context.Context, recipeID primitive.ObjectID) error
+}
+
+// MealPlanStore keeps the meal plans of users.
+type MealPlanStore interface {
+	Create(ctx context.Context, entry models.MealPlanEntry) error
+	Get(ctx context.Context, id primitive.ObjectID) (models.MealPlanEntry, error)
+	// List returns the entries of owner dated from from to to, both
+	// inclusive, by date and then in the order they were created.
+	List(ctx context.Context, owner, from, to string) ([]models.MealPlanEntry, error)
+	// Update writes the date, slot, recipe, servings, note and update time
+	// of an entry.
+	Update(ctx context.Context, entry models.MealPlanEntry) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
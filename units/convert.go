@@ -0,0 +1,104 @@
+package units
+
+import (
+	"math"
+	"recipes-api/ingredient"
+)
+
+// ConvertIngredient expresses the quantities of an ingredient in system.
+// When the recipe already gives the amount in system as its alternate
+// measurement, the two are swapped rather than converted. Volumes and
+// masses of ingredients with a known density are converted into each
+// other, following the habit of metric recipes to weigh dry goods. Text is
+// rewritten when anything changed.
+func ConvertIngredient(ing ingredient.Ingredient, system System) ingredient.Ingredient {
+	converted := ing
+	converted.Note = ConvertTemperatures(ing.Note, system)
+	changed := converted.Note != ing.Note
+
+	if ing.Quantity != nil {
+		primary := ingredient.Amount{Quantity: *ing.Quantity, Unit: ing.Unit}
+		alternate := ing.Alternate
+
+		if alternate != nil && !inSystem(primary.Unit, system) && inSystem(alternate.Unit, system) {
+			original := primary
+			primary, alternate = *alternate, &original
+			changed = true
+		} else if amount, ok := convertAmount(primary, system, ing.Item); ok {
+			changed = changed || amount != primary
+			primary = amount
+		} else if alternate != nil {
+			// The primary measure cannot be converted, as in "1 package
+			// (2 1/4 teaspoons) yeast"; convert the alternate instead.
+			if amount, ok := convertAmount(*alternate, system, ing.Item); ok {
+				changed = changed || amount != *alternate
+				alternate = &amount
+			}
+		}
+
+		converted.Quantity = &primary.Quantity
+		converted.Unit = primary.Unit
+		converted.Alternate = alternate
+	}
+
+	if changed {
+		converted.Text = converted.String()
+	}
+	return converted
+}
+
+func inSystem(unit string, system System) bool {
+	m, ok := measures[unit]
+	return ok && m.system == system
+}
+
+// convertAmount expresses an amount in system, keeping amounts that are
+// already in it as they are. It reports false for units it does not know.
+func convertAmount(amount ingredient.Amount, system System, item string) (ingredient.Amount, bool) {
+	m, ok := measures[amount.Unit]
+	if !ok {
+		return amount, false
+	}
+	dimension := m.dimension
+	factor := m.base
+
+	density, known := Density(item)
+	switch {
+	case known && system == Metric && dimension == Volume && m.system == US:
+		dimension = Mass
+		factor *= density
+	case known && system == US && dimension == Mass && m.system == Metric:
+		dimension = Volume
+		factor /= density
+	case m.system == system:
+		return amount, true
+	}
+
+	unit := preferredUnit(dimension, system, amount.Quantity.Value*factor)
+	factor /= measures[unit].base
+	return ingredient.Amount{
+		Quantity: ingredient.Quantity{
+			Value: roundTo(unit, amount.Quantity.Value*factor),
+			Max:   roundTo(unit, amount.Quantity.Max*factor),
+		},
+		Unit: unit,
+	}, true
+}
+
+// roundTo rounds a converted value to a precision that suits its unit:
+// eighths of a cup, quarters of spoons, ounces and pounds. Metric values
+// are rounded when they are formatted.
+func roundTo(unit string, value float64) float64 {
+	step := 0.25
+	switch {
+	case unit == "cup":
+		step = 0.125
+	case measures[unit].system == Metric:
+		return value
+	}
+	rounded := math.Round(value/step) * step
+	if rounded == 0 && value > 0 {
+		return step
+	}
+	return rounded
+}
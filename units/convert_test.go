@@ -0,0 +1,73 @@
+package units
+
+import (
+	"recipes-api/ingredient"
+	"testing"
+)
+
+func TestConvertIngredient(t *testing.T) {
+	tests := []struct {
+		line   string
+		system System
+		want   string
+	}{
+		// The alternate is already metric, so the two are swapped.
+		{"3 3/4 cups (490 g) bread flour", Metric, "490 g (3 3/4 cups) bread flour"},
+		{"2 cups milk", Metric, "473 ml milk"},
+		{"1 lb ground beef", Metric, "454 g ground beef"},
+		{"500 g ground beef", US, "1 lb ground beef"},
+		{"1 l water", US, "4 1/4 cups water"},
+		// Amounts already in the system keep their text.
+		{"1 tsp salt", US, "1 tsp salt"},
+		{"salt, to taste", Metric, "salt, to taste"},
+		// Yeast has a known density, so it is weighed.
+		{"1 package (2 1/4 teaspoons) yeast", Metric, "1 package (7 g) yeast"},
+		{"2 eggs", Metric, "2 eggs"},
+	}
+	for _, tt := range tests {
+		if got := ConvertIngredient(ingredient.Parse(tt.line), tt.system).Text; got != tt.want {
+			t.Errorf("ConvertIngredient(%q, %s) = %q, want %q", tt.line, tt.system, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTemperatures(t *testing.T) {
+	tests := []struct {
+		text   string
+		system System
+		want   string
+	}{
+		{"Bake at 350°F for 20 minutes", Metric, "Bake at 175°C for 20 minutes"},
+		{"Preheat to 400 degrees F", Metric, "Preheat to 205°C"},
+		{"Heat to 180 °C", US, "Heat to 355°F"},
+		{"Proof at 105°F-115°F", Metric, "Proof at 41°C-46°C"},
+		{"Freeze at -4°F", Metric, "Freeze at -20°C"},
+		{"Chill to 32°F", Metric, "Chill to 0°C"},
+		{"Bake at 200°C", Metric, "Bake at 200°C"},
+		{"Add 2 eggs", US, "Add 2 eggs"},
+	}
+	for _, tt := range tests {
+		if got := ConvertTemperatures(tt.text, tt.system); got != tt.want {
+			t.Errorf("ConvertTemperatures(%q, %s) = %q, want %q", tt.text, tt.system, got, tt.want)
+		}
+	}
+}
+
+func TestParseSystem(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    System
+		wantErr bool
+	}{
+		{"metric", Metric, false},
+		{"US", US, false},
+		{"imperial", US, false},
+		{"kelvin", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSystem(tt.name)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseSystem(%q) = %q, %v", tt.name, got, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package units
+
+import (
+	"sort"
+	"strings"
+)
+
+// densities are rough weights in grams of one US cup of dry baking
+// ingredients, used to convert between volume and mass. Liquids are left
+// out on purpose: metric recipes measure them by volume too. Keys are
+// matched against the ingredient name; the longest matching key wins so
+// that "brown sugar" is not weighed as "sugar".
+var densities = map[string]float64{
+	"flour":             125,
+	"bread flour":       130,
+	"cake flour":        115,
+	"whole wheat flour": 120,
+	"sugar":             200,
+	"brown sugar":       220,
+	"powdered sugar":    120,
+	"butter":            227,
+	"cocoa":             85,
+	"oats":              90,
+	"rice":              185,
+	"salt":              288,
+	"baking soda":       220,
+	"baking powder":     192,
+	"yeast":             150,
+	"chocolate chips":   170,
+	"nuts":              120,
+	"raisins":           145,
+}
+
+// densityKeys lists the keys of densities, longest first.
+var densityKeys = func() []string {
+	keys := make([]string, 0, len(densities))
+	for key := range densities {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}()
+
+// Density returns the density of an ingredient in grams per millilitre.
+func Density(item string) (float64, bool) {
+	item = strings.ToLower(item)
+	for _, key := range densityKeys {
+		if strings.Contains(item, key) {
+			return densities[key] / measures["cup"].base, true
+		}
+	}
+	return 0, false
+}
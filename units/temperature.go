@@ -0,0 +1,68 @@
+package units
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// temperaturePattern matches temperatures such as "350°F", "180 °C" and
+// "400 degrees F".
+var temperaturePattern = regexp.MustCompile(`(-?)(\d+(?:\.\d+)?)\s*(?:°|º|degrees?\s+)\s*([FC])(?:ahrenheit|elsius)?\b`)
+
+// ConvertTemperatures rewrites the temperatures in text to Celsius for
+// metric and Fahrenheit for US customary. Oven temperatures are rounded to
+// the nearest 5 degrees.
+func ConvertTemperatures(text string, system System) string {
+	target := "C"
+	if system == US {
+		target = "F"
+	}
+
+	var converted strings.Builder
+	last := 0
+	for _, m := range temperaturePattern.FindAllStringSubmatchIndex(text, -1) {
+		if text[m[6]:m[7]] == target {
+			continue
+		}
+		value, err := strconv.ParseFloat(text[m[4]:m[5]], 64)
+		if err != nil {
+			continue
+		}
+		start := m[0]
+		// A dash right after another word is a range separator, as in
+		// "105°F-115°F", not a minus sign.
+		if m[2] != m[3] {
+			if m[2] > 0 && !unicode.IsSpace(rune(text[m[2]-1])) && text[m[2]-1] != '(' {
+				start = m[3]
+			} else {
+				value = -value
+			}
+		}
+
+		if target == "C" {
+			value = (value - 32) * 5 / 9
+		} else {
+			value = value*9/5 + 32
+		}
+		converted.WriteString(text[last:start])
+		converted.WriteString(formatTemperature(value, target))
+		last = m[1]
+	}
+	converted.WriteString(text[last:])
+	return converted.String()
+}
+
+func formatTemperature(value float64, scale string) string {
+	oven := 100.0
+	if scale == "F" {
+		oven = 212
+	}
+	if value >= oven {
+		value = math.Round(value/5) * 5
+	}
+	// Adding zero turns a rounded -0 into 0.
+	return strconv.FormatFloat(math.Round(value)+0, 'f', -1, 64) + "°" + scale
+}
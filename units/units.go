@@ -0,0 +1,117 @@
+// Package units converts ingredient quantities and temperatures between
+// metric and US customary measures.
+package units
+
+import (
+	"fmt"
+	"strings"
+)
+
+// System is a system of measurement to convert to.
+type System string
+
+const (
+	Metric System = "metric"
+	US     System = "us"
+)
+
+// ParseSystem validates the name of a system of measurement. "imperial" is
+// accepted for US customary, which is what American recipes mean by it.
+func ParseSystem(name string) (System, error) {
+	switch strings.ToLower(name) {
+	case "metric":
+		return Metric, nil
+	case "us", "imperial", "customary":
+		return US, nil
+	default:
+		return "", fmt.Errorf("unknown units %q, use metric or us", name)
+	}
+}
+
+// Dimension is what a unit measures.
+type Dimension int
+
+const (
+	Volume Dimension = iota + 1
+	Mass
+)
+
+// measure relates a unit to the base unit of its dimension: millilitres
+// for volume and grams for mass.
+type measure struct {
+	dimension Dimension
+	base      float64
+	system    System
+}
+
+// measures is keyed by the canonical unit names of package ingredient.
+// US customary volumes are the US liquid measures.
+var measures = map[string]measure{
+	"tsp":   {Volume, 4.92892, US},
+	"tbsp":  {Volume, 14.7868, US},
+	"fl oz": {Volume, 29.5735, US},
+	"cup":   {Volume, 236.588, US},
+	"pt":    {Volume, 473.176, US},
+	"qt":    {Volume, 946.353, US},
+	"gal":   {Volume, 3785.41, US},
+	"ml":    {Volume, 1, Metric},
+	"l":     {Volume, 1000, Metric},
+	"oz":    {Mass, 28.3495, US},
+	"lb":    {Mass, 453.592, US},
+	"g":     {Mass, 1, Metric},
+	"kg":    {Mass, 1000, Metric},
+}
+
+// Lookup returns the dimension and system of a unit.
+func Lookup(unit string) (Dimension, System, bool) {
+	m, ok := measures[unit]
+	return m.dimension, m.system, ok
+}
+
+// Convert expresses value in from as an amount of to. Both units must have
+// the same dimension.
+func Convert(value float64, from, to string) (float64, error) {
+	source, ok := measures[from]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", from)
+	}
+	target, ok := measures[to]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", to)
+	}
+	if source.dimension != target.dimension {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return value * source.base / target.base, nil
+}
+
+// preferredUnit picks the unit a cook would use for an amount, given in
+// base units, of a dimension in a system.
+func preferredUnit(dimension Dimension, system System, base float64) string {
+	switch {
+	case dimension == Volume && system == Metric:
+		if base >= 1000 {
+			return "l"
+		}
+		return "ml"
+	case dimension == Volume:
+		switch {
+		case base >= measures["cup"].base/4:
+			return "cup"
+		case base >= measures["tbsp"].base:
+			return "tbsp"
+		default:
+			return "tsp"
+		}
+	case system == Metric:
+		if base >= 1000 {
+			return "kg"
+		}
+		return "g"
+	default:
+		if base >= measures["lb"].base {
+			return "lb"
+		}
+		return "oz"
+	}
+}